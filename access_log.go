@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// _nearExpiredDeadline is how close to its deadline a request may arrive and still be served
+// when expired requests are rejected.
+const _nearExpiredDeadline = 50 * time.Millisecond
+
+// AccessLogMiddleware logs one entry per request once the response has been written,
+// correlated with the active span through its trace and span IDs. With followSampling,
+// requests whose trace isn't sampled are logged at debug instead of info. With
+// rejectExpired, requests whose deadline is less than 50ms away answer 503 right away, with
+// a warning, and are logged like the others: the client gave up or is about to. The
+// deadline is the one of the request context, or else the one stated by timeoutHeader, in
+// milliseconds left from the arrival. Entries go to the "access" logger.
+func AccessLogMiddleware(logger *zap.Logger, followSampling, rejectExpired bool, timeoutHeader string) func(http.Handler) http.Handler {
+	return accessLogMiddleware(logger, nil, followSampling, rejectExpired, timeoutHeader)
+}
+
+// accessLogMiddleware is AccessLogMiddleware also observing the request durations in
+// latencies, unless it's nil.
+func accessLogMiddleware(logger *zap.Logger, latencies *routeLatencies, followSampling, rejectExpired bool, timeoutHeader string) func(http.Handler) http.Handler {
+	logger = logger.Named("access")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newStatusRecorder(w)
+			deadline, ok := requestDeadline(r, start, timeoutHeader)
+			expired := rejectExpired && ok && deadline.Sub(start) < _nearExpiredDeadline
+			if expired {
+				WithTrace(r.Context(), logger).Warn("request arrived with near-expired deadline",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Duration("remaining", deadline.Sub(start)),
+					zap.String("remote_addr", r.RemoteAddr),
+				)
+				WriteJSON(rec, http.StatusServiceUnavailable, APIError{
+					Code:    http.StatusServiceUnavailable,
+					Message: "request deadline expired",
+				})
+			} else {
+				next.ServeHTTP(rec, r)
+			}
+
+			duration := time.Since(start)
+			if latencies != nil && !expired {
+				latencies.observe(routeOf(r), duration)
+			}
+
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("route", routeOf(r)),
+				zap.Int("status", rec.Status()),
+				zap.Int64("bytes", rec.written),
+				zap.Duration("duration", duration),
+				zap.String("remote_addr", r.RemoteAddr),
+			}
+			if tenant, ok := TenantFromContext(r.Context()); ok {
+				fields = append(fields, zap.String("tenant", tenant.ID))
+			}
+
+			// otelhttp wraps the chain, so the request span is already in the context
+			level := zapcore.InfoLevel
+			if followSampling {
+				level = requestLogLevel(r.Context())
+			}
+			WithTrace(r.Context(), logger).Log(level, "Request served", fields...)
+		})
+	}
+}
+
+// requestDeadline returns the deadline of the request context or, without one, the deadline
+// stated by its timeoutHeader in milliseconds left at arrival.
+func requestDeadline(r *http.Request, arrival time.Time, timeoutHeader string) (time.Time, bool) {
+	if deadline, ok := r.Context().Deadline(); ok {
+		return deadline, true
+	}
+	if timeoutHeader == "" {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(r.Header.Get(timeoutHeader), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return arrival.Add(time.Duration(ms) * time.Millisecond), true
+}
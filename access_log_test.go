@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _okHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestAccessLogCarriesTraceIDs(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := AccessLogMiddleware(zap.New(core), false, false, "")(_okHandler)
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(t.Context(), "request")
+	defer span.End()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	entries := logs.FilterMessage("Request served").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d access log entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if got, want := fields["trace_id"], span.SpanContext().TraceID().String(); got != want {
+		t.Errorf("trace_id = %v, want %s", got, want)
+	}
+	if got, want := fields["span_id"], span.SpanContext().SpanID().String(); got != want {
+		t.Errorf("span_id = %v, want %s", got, want)
+	}
+}
+
+func TestAccessLogLevelFollowsSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampler sdktrace.Sampler
+		want    zapcore.Level
+	}{
+		{"sampled", sdktrace.AlwaysSample(), zapcore.InfoLevel},
+		{"unsampled", sdktrace.NeverSample(), zapcore.DebugLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			handler := AccessLogMiddleware(zap.New(core), true, false, "")(_okHandler)
+
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(tt.sampler)).Tracer("test")
+			ctx, span := tracer.Start(t.Context(), "request")
+			defer span.End()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+			entries := logs.FilterMessage("Request served").All()
+			if len(entries) != 1 || entries[0].Level != tt.want {
+				t.Fatalf("access log entries = %v, want one at %v", entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogLevelWithoutSamplingIsInfo(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := AccessLogMiddleware(zap.New(core), false, false, "")(_okHandler)
+
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())).Tracer("test")
+	ctx, span := tracer.Start(t.Context(), "request")
+	defer span.End()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if entries := logs.All(); len(entries) != 1 || entries[0].Level != zapcore.InfoLevel {
+		t.Fatalf("access log entries = %v, want one at info", entries)
+	}
+}
+
+func TestMiddlewaresLogThroughNamedLoggers(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+	panicking := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+	AccessLogMiddleware(logger, false, false, "")(_okHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	RecoveryMiddleware(logger)(panicking).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	names := map[string]string{}
+	for _, entry := range logs.All() {
+		names[entry.Message] = entry.LoggerName
+	}
+	if len(names) < 2 {
+		t.Fatalf("log entries = %v, want the access log and the recovered panic", names)
+	}
+	if names["Request served"] != "access" {
+		t.Errorf("access log written by logger %q, want access", names["Request served"])
+	}
+	for msg, name := range names {
+		if msg != "Request served" && name != "recovery" {
+			t.Errorf("recovery entry %q written by logger %q, want recovery", msg, name)
+		}
+	}
+}
+
+func TestRejectExpiredRequests(t *testing.T) {
+	t.Setenv("GSD_REJECT_EXPIRED_REQUESTS", "true")
+	var served int
+	a := newUnstartedTestServer(t, withRoute("GET /orders", func(w http.ResponseWriter, r *http.Request) error {
+		served++
+		return WriteJSON(w, http.StatusOK, "ok")
+	}))
+
+	expiredCtx, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+	tests := []struct {
+		name    string
+		timeout string // X-Request-Timeout-Ms, when not empty
+		ctx     context.Context
+		want    int
+	}{
+		{name: "no deadline", want: http.StatusOK},
+		{name: "time left", timeout: "5000", want: http.StatusOK},
+		{name: "expired", timeout: "0", want: http.StatusServiceUnavailable},
+		{name: "near expired", timeout: "30", want: http.StatusServiceUnavailable},
+		{name: "invalid header", timeout: "soon", want: http.StatusOK},
+		{name: "expired context", ctx: expiredCtx, want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served = 0
+			a.Logs.TakeAll()
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.ctx != nil {
+				req = req.WithContext(tt.ctx)
+			}
+			if tt.timeout != "" {
+				req.Header.Set("X-Request-Timeout-Ms", tt.timeout)
+			}
+
+			rec := a.serve(req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			rejected := tt.want == http.StatusServiceUnavailable
+			if (served == 0) != rejected {
+				t.Fatalf("handler ran %d times with status %d", served, rec.Code)
+			}
+			if n := a.Logs.FilterMessage("request arrived with near-expired deadline").Len(); (n == 1) != rejected {
+				t.Fatalf("%d near-expired warnings with status %d", n, rec.Code)
+			}
+			// Rejected or not, the request has its access log entry
+			entries := a.Logs.FilterMessage("Request served").All()
+			if len(entries) != 1 || entries[0].ContextMap()["status"] != int64(tt.want) {
+				t.Fatalf("access log entries = %+v, want one with status %d", entries, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// HandleAdmin registers fn on the admin listener. Admin routes are never exposed on the public port.
+func (a *APIServer) HandleAdmin(pattern string, fn apiFunc) {
+	a.adminMux.HandleFunc(pattern, a.makeHTTPHandlerFunc(fn))
+}
+
+// AdminAuthMiddleware rejects requests that don't carry "Authorization: Bearer <token>".
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				WriteJSON(w, http.StatusUnauthorized, APIError{
+					Code:    http.StatusUnauthorized,
+					Message: "unauthorized",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
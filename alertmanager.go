@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const _alertmanagerTimeout = 3 * time.Second
+
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+}
+
+// AlertmanagerNotifier returns a drain hook firing a PodShuttingDown alert on the Alertmanager
+// at baseURL, so monitoring knows the instance is going away. It gives up after 3 seconds.
+func AlertmanagerNotifier(baseURL string, client *http.Client) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, _alertmanagerTimeout)
+		defer cancel()
+
+		pod := os.Getenv("POD_NAME")
+		if pod == "" {
+			pod, _ = os.Hostname()
+		}
+
+		body, err := json.Marshal([]alertmanagerAlert{{
+			Labels: map[string]string{
+				"alertname": "PodShuttingDown",
+				"service":   _serviceName,
+				"pod":       pod,
+			},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s pod %s is shutting down", _serviceName, pod),
+			},
+			StartsAt: time.Now().UTC(),
+		}})
+		if err != nil {
+			return err
+		}
+
+		url := strings.TrimSuffix(baseURL, "/") + "/api/v2/alerts"
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("alertmanager returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
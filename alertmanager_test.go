@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerNotifierPostsAlert(t *testing.T) {
+	t.Setenv("POD_NAME", "api-7d9f")
+
+	var alerts []alertmanagerAlert
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/alerts" {
+			t.Errorf("request = %s %s, want POST /api/v2/alerts", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Errorf("decode alerts: %v", err)
+		}
+	}))
+	defer am.Close()
+
+	if err := AlertmanagerNotifier(am.URL+"/", am.Client())(context.Background()); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	labels := alerts[0].Labels
+	if labels["alertname"] != "PodShuttingDown" || labels["service"] != _serviceName || labels["pod"] != "api-7d9f" {
+		t.Errorf("labels = %v", labels)
+	}
+	if alerts[0].Annotations["summary"] == "" || alerts[0].StartsAt.IsZero() {
+		t.Errorf("alert = %+v, want a summary and a start time", alerts[0])
+	}
+}
+
+func TestAlertmanagerNotifierGivesUp(t *testing.T) {
+	release := make(chan struct{})
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer am.Close()
+	defer close(release)
+
+	// The caller's budget applies when it's shorter than the notifier's own 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := AlertmanagerNotifier(am.URL, am.Client())(ctx); err == nil {
+		t.Fatal("notify succeeded against a hanging Alertmanager")
+	}
+	if elapsed := time.Since(start); elapsed > _alertmanagerTimeout {
+		t.Fatalf("notify took %s", elapsed)
+	}
+}
@@ -2,42 +2,87 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
+	"math/rand/v2"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	_shutdownRetryBackoff = 100 * time.Millisecond
+	_maxShutdownErrors    = 10
 )
 
 type GetReadinessResponse struct {
-	Message string `json:"message"`
+	Message         string            `json:"message"`
+	CircuitBreakers map[string]string `json:"circuit_breakers,omitempty"`
+	Warnings        map[string]string `json:"warnings,omitempty"`
 }
 
 type apiFunc func(http.ResponseWriter, *http.Request) error
 
+// ErrPartialWrite is returned when the status line was sent but the body couldn't be, usually
+// because the client went away. Nothing else can be written to the response at that point.
+var ErrPartialWrite = errors.New("response partially written")
+
+// WriteJSON encodes data before touching the response, so an encoding failure can still be
+// answered with an error status. A failure once the status is sent is reported as ErrPartialWrite.
 func WriteJSON(w http.ResponseWriter, status int, data any) error {
-	w.WriteHeader(status)
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(data)
+	w.WriteHeader(status)
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		return fmt.Errorf("%w: %w", ErrPartialWrite, err)
+	}
+	return nil
 }
 
-func makeHTTPHandlerFunc(fn apiFunc) http.HandlerFunc {
+func (a *APIServer) makeHTTPHandlerFunc(fn apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := fn(w, r)
+		if errors.Is(err, ErrPartialWrite) {
+			a.logPartialWrite(r, err)
+			return
+		}
 		if err != nil {
+			if isRetryable(err) {
+				MarkUpstreamFailure(r.Context())
+			}
 			var apiErr APIError
 			if errors.As(err, &apiErr) {
-				WriteJSON(w, apiErr.Code, apiErr)
+				a.writeError(w, r, apiErr)
+				return
+			}
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				a.writeError(w, r, validationErr.APIError())
 				return
 			}
 
-			WriteJSON(w, http.StatusInternalServerError, APIError{
+			a.logInternalError(r, err)
+			a.writeError(w, r, APIError{
 				Code:    http.StatusInternalServerError,
 				Message: "internal server error",
 			})
@@ -45,111 +90,757 @@ func makeHTTPHandlerFunc(fn apiFunc) http.HandlerFunc {
 	}
 }
 
+// logInternalError logs an error answered with a 500, sampled at Config.ErrorLogSampleRate
+// so sustained failures don't flood the logs. Development logs every error.
+func (a *APIServer) logInternalError(r *http.Request, err error) {
+	if !a.Config.IsDevelopment() && a.randFloat() >= a.Config.ErrorLogSampleRate {
+		return
+	}
+	WithTrace(r.Context(), a.Logger).Error("Request failed",
+		zap.String("path", r.URL.Path),
+		zap.Float64("sample_rate", a.Config.ErrorLogSampleRate),
+		zap.Error(err),
+	)
+}
+
+// writeError writes apiErr as JSON, or as an HTML status page when a browser asks for one.
+func (a *APIServer) writeError(w http.ResponseWriter, r *http.Request, apiErr APIError) {
+	if apiErr.Code == http.StatusServiceUnavailable {
+		a.countRejected()
+	}
+
+	if a.wantsStatusPage(r, apiErr.Code) {
+		err := a.writeStatusPage(w, r, apiErr)
+		if err == nil {
+			return
+		}
+		a.Logger.Warn("Failed to render status page, falling back to JSON", zap.Error(err))
+	}
+
+	if err := WriteJSON(w, apiErr.Code, apiErr); errors.Is(err, ErrPartialWrite) {
+		a.logPartialWrite(r, err)
+	}
+}
+
+func (a *APIServer) logPartialWrite(r *http.Request, err error) {
+	WithTrace(r.Context(), a.Logger).Warn("Response partially written, the client likely went away",
+		zap.String("path", r.URL.Path),
+		zap.Error(err),
+	)
+}
+
 type APIServer struct {
 	isShuttingDown atomic.Bool
+	drained        atomic.Bool // the drain strategy is done waiting, the HTTP servers are closing
+	rejected       atomic.Int64
+	lastHeartbeat  atomic.Int64
+	inFlight       atomic.Int64
+	served         atomic.Int64
+	lastProbe      atomic.Pointer[ProbeInfo]
+	notReadyAt     atomic.Int64 // unix nanoseconds
+	drainInFlight  atomic.Int64 // requests in flight when the readiness flipped
+	lastScrape     atomic.Int64 // unix nanoseconds
+	warmedUp       atomic.Bool
+	readOnly       atomic.Bool
 
 	Config Config
 	Logger *zap.Logger
+	// SignalContext returns a context cancelled when one of the signals is received.
+	// Defaults to signal.NotifyContext; tests replace it to simulate a signal.
+	SignalContext func(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc)
+	Spans         *SpanTracker
+	Events        *EventRing
 
-	server *http.Server
+	otel           *OTelProvider
+	metricsHandler http.Handler // serves /metrics, when Config.PrometheusEnabled
+	otelLogs       *BatchedOTelZapCore
+	outputLogger   *zap.Logger // Logger before the OTel tee, writing to the file or stderr only
+	backends       Backends
+	authenticator  Authenticator
+	rateLimiter    RateLimiter
+	watchdog       *Watchdog
 
-	shutdownFuncs []func(context.Context) error
+	shutdownDuration metric.Float64Histogram
+	startupParent    context.Context
+	workerCtx        context.Context
+	cancelWorkers    context.CancelFunc
+
+	serversMu     sync.Mutex
+	serversClosed bool
+	server        *http.Server
+	adminServer   *http.Server
+	adminMux      *http.ServeMux
+	handshakes    handshakeTracker
+	publicHandler http.Handler // the handler chain of the public listener, set by Run
+	statusPage    *template.Template
+	ready         chan struct{}
+
+	routes            []Route
+	middlewares       []func(http.Handler) http.Handler
+	otelHTTPOptions   []otelhttp.Option
+	healthChecks      []healthCheck
+	healthWarnings    []healthCheck
+	healthCache       healthCheckCache
+	healthHistory     *HealthCheckHistory
+	inFlightRequests  *inFlightRegistry
+	routeLatencies    *routeLatencies
+	readinessDebounce readinessDebounce
+	breakersMu        sync.Mutex
+	breakers          map[string]*CircuitBreaker
+	drainHooks        []shutdownHook
+	quiescers         []QuiescingConsumer
+	processes         processRegistry
+	shutdownHooks     []shutdownHook
+
+	// The once guards make the start of the shutdown idempotent: a concurrent caller blocks
+	// until the first one is done, so the readiness flip, the keep-alive disable and the drain
+	// hooks happen before any caller returns and starts waiting on a drain strategy.
+	initiateOnce   sync.Once
+	drainHooksOnce sync.Once
+	drainHooksErr  error
+
+	onShutdownComplete   []func()
+	shutdownCompleteOnce sync.Once
+	ownsLogger           bool
+	ownsOTel             bool
+	chaos                []ChaosFault
+	randFloat            func() float64
 }
 
-func NewAPIServer() (*APIServer, error) {
-	shutdownFuncs := []func(context.Context) error{}
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
+}
 
-	// load config from environment variables
+func NewAPIServer(opts ...Option) (*APIServer, error) {
+	// load config from environment variables, after reporting every missing required one
+	if err := ValidateRequiredEnvVars(requiredEnvVars()); err != nil {
+		return nil, err
+	}
 	var config Config
 	if err := envconfig.Process("gsd", &config); err != nil {
 		return nil, err
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
-	// initialize base logger
-	logger, err := NewBaseLogger()
+	statusPage, err := loadStatusPageTemplate(config.StatusPageTemplate)
 	if err != nil {
+		return nil, fmt.Errorf("load status page template: %w", err)
+	}
+
+	a := &APIServer{
+		Config:           config,
+		Events:           NewEventRing(config.EventBufferSize),
+		healthHistory:    NewHealthCheckHistory(config.HealthHistorySize),
+		inFlightRequests: newInFlightRegistry(config.InFlightRegistrySize),
+		routeLatencies:   newRouteLatencies(),
+		breakers:         make(map[string]*CircuitBreaker),
+		adminMux:         http.NewServeMux(),
+		statusPage:       statusPage,
+		ready:            make(chan struct{}),
+		randFloat:        rand.Float64,
+		SignalContext:    signal.NotifyContext,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	startupCtx, cancelStartup := a.startupContext()
+	defer cancelStartup()
+
+	// Each step names the steps it builds on; the order they run in is logged at the end.
+	// The steps registering hooks or middlewares build on chaos, which may wrap them
+	graph := NewInitGraph()
+	steps := []struct {
+		name string
+		deps []string
+		fn   func() error
+	}{
+		{"logger", nil, func() error { return a.initLogger(startupCtx) }},
+		{"chaos", []string{"logger"}, func() error {
+			a.loadChaos()
+			return nil
+		}},
+		{"routes", []string{"chaos"}, func() error {
+			a.registerRoutes()
+			return nil
+		}},
+		{"otel", []string{"logger"}, func() error { return a.initOTel(startupCtx) }},
+		{"dependencies", []string{"logger", "chaos", "otel"}, func() error {
+			a.registerDependencies()
+			return nil
+		}},
+		{"auth", nil, a.initAuthenticator},
+		{"watchdog", []string{"logger"}, func() error {
+			if config.WatchdogHeartbeat > 0 {
+				a.watchdog = NewWatchdog(config.WatchdogHeartbeat, config.WatchdogMaxMissed, watchdogExit(a.Logger))
+			}
+			return nil
+		}},
+		{"rate_limiter", []string{"logger", "chaos"}, func() error {
+			if !slices.Contains(config.Middleware, "rate_limit") {
+				return nil
+			}
+			var err error
+			a.rateLimiter, err = a.newRateLimiter()
+			return err
+		}},
+		{"middleware", []string{"logger", "chaos", "otel", "auth", "watchdog", "rate_limiter"}, func() error {
+			a.useConfiguredMiddleware()
+			if config.MaxResponseBodyBytes > 0 {
+				a.Use(ResponseBodyLimitMiddleware(config.MaxResponseBodyBytes, a.Logger))
+			}
+			return nil
+		}},
+	}
+	for _, step := range steps {
+		if err := graph.Step(step.name, step.deps, step.fn); err != nil {
+			return nil, err
+		}
+	}
+	if err := graph.Run(); err != nil {
 		return nil, err
 	}
-	shutdownLogger := func(ctx context.Context) error {
-		return logger.Sync()
+	a.Logger.Info("Server initialized", zap.Strings("init_order", graph.Order()))
+
+	return a, nil
+}
+
+// initLogger builds the logger, unless one was injected, and tees it to OTel when enabled.
+func (a *APIServer) initLogger(ctx context.Context) error {
+	config := a.Config
+	logger := a.backends.Logger
+	if logger == nil {
+		var err error
+		logger, err = NewLogger(config)
+		if err != nil {
+			return err
+		}
+		a.ownsLogger = true
 	}
-	shutdownFuncs = append(shutdownFuncs, shutdownLogger)
+	a.outputLogger = logger
+	if config.OTelLogsEnabled {
+		otelCore, err := initStep(ctx, "otel_logs", func(ctx context.Context) (*BatchedOTelZapCore, error) {
+			return newOTelLogCore(ctx, config, logger.Core())
+		})
+		if err != nil {
+			return err
+		}
+		logger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, otelCore)
+		}))
+		// Flushed with the rest of the telemetry, after the shutdown status was logged
+		a.otelLogs = otelCore
+	}
+	a.Logger = logger
+	return nil
+}
 
-	// initialize OpenTelemetry
-	otelProvider, err := NewOTelProvider(context.Background(), config)
-	if err != nil {
-		panic(err)
+// registerRoutes sets up the admin routes and the built-in public ones.
+func (a *APIServer) registerRoutes() {
+	a.HandleAdmin("GET /admin/selftest", a.handleSelfTest)
+	a.HandleAdmin("GET /admin/events", a.handleGetEvents)
+	a.HandleAdmin("GET /admin/openapi", a.handleGetOpenAPI)
+	a.HandleAdmin("GET /admin/health-history", a.handleGetHealthHistory)
+	a.HandleAdmin("GET /admin/in-flight", a.handleGetInFlight)
+	a.HandleAdmin("GET /admin/otel-stats", a.handleGetOTelStats)
+	a.HandleAdmin("GET /admin/read-only", a.handleGetReadOnly)
+	a.HandleAdmin("PUT /admin/read-only", a.handlePutReadOnly)
+
+	a.Handle("/livez", a.handleLiveness, WithSummary("Liveness probe"))     // Setup liveness endpoint
+	a.Handle("/healthz", a.handleReadiness, WithSummary("Readiness probe")) // Setup readiness endpoint
+	a.Handle("/{$}", a.handleHelloWorld, WithSummary("Hello world"))        // Setup hello world endpoint
+	a.Handle(_selfTestPath, a.handleSelfTestEcho, Undocumented())           // Loopback target of the admin self-test
+	a.Handle("/", a.handleNotFound, Undocumented())                         // Everything else is not found
+	if _, ok := a.chaosHangLimit(); ok {
+		a.Handle(_chaosHangPath, a.handleChaosHang, Undocumented())
+	}
+
+	a.OnShutdownComplete(a.dumpEvents)
+}
+
+// initOTel sets up OpenTelemetry, unless a provider was injected, and the server's own
+// instruments.
+func (a *APIServer) initOTel(ctx context.Context) error {
+	config := a.Config
+	otelProvider := a.backends.OTel
+	if otelProvider == nil {
+		var err error
+		otelProvider, err = initStep(ctx, "otel", func(ctx context.Context) (*OTelProvider, error) {
+			return NewOTelProvider(ctx, config)
+		})
+		if err != nil {
+			return err
+		}
+		if err := otelProvider.Setup(config.OTelGlobalPolicy, a.Logger); err != nil {
+			return err
+		}
+		a.ownsOTel = true
+	}
+	a.otel = otelProvider
+	if otelProvider.promRegistry != nil {
+		a.metricsHandler = promhttp.HandlerFor(otelProvider.promRegistry, promhttp.HandlerOpts{})
+		a.Handle("GET "+_metricsPath, a.handleMetrics, Undocumented())
+	}
+	a.registerNotReadyGauge()
+	a.registerDrainProgressGauge()
+	a.registerShutdownDuration()
+	if otelProvider.Stats != nil {
+		a.RegisterHealthWarning("otel_queue", a.otelQueueWarning)
+	}
+	a.Spans = NewSpanTracker(otelProvider.TracerProvider().Tracer(_instrumentationName))
+	a.workerCtx, a.cancelWorkers = context.WithCancel(context.Background())
+	return nil
+}
+
+// registerDependencies wires the configured dependencies: notifiers, health checks, cache
+// and reporters.
+func (a *APIServer) registerDependencies() {
+	config := a.Config
+	if config.AlertmanagerURL != "" {
+		a.OnDrain("alertmanager", AlertmanagerNotifier(config.AlertmanagerURL, a.OutboundClient(config.AlertmanagerURL)))
+	}
+
+	// A stuck log output fails readiness before its final flush blocks the shutdown
+	a.RegisterHealthCheck("logger", LoggerHealthCheck(a.outputLogger))
+
+	if len(config.CrossRegionEndpoints) > 0 {
+		// Another region being down must not pull this one out of the load balancer too
+		a.RegisterHealthWarning("cross_region", CrossRegionHealthCheck(config.CrossRegionEndpoints, a.OutboundClient))
+	}
+
+	if a.backends.Cache != nil {
+		a.RegisterCache("cache", a.backends.Cache)
 	}
-	otelProvider.Setup()
-	shutdownFuncs = append(shutdownFuncs, otelProvider.Shutdown)
+	if a.backends.DB != nil {
+		a.RegisterHealthCheck("db", a.backends.DB.PingContext)
+	}
+
+	a.RegisterShutdownHook("processes", a.terminateProcesses)
+
+	if config.SlowRouteReporting {
+		start, stop := a.TopSlowRoutesReporter(config.SlowRouteCount, config.SlowRouteInterval, a.Logger)
+		start()
+		a.RegisterShutdownHook("slow_routes", func(ctx context.Context) error {
+			stop()
+			return nil
+		})
+	}
+}
+
+// initAuthenticator falls back to the configured static tokens when the auth middleware is
+// used without an injected Authenticator.
+func (a *APIServer) initAuthenticator() error {
+	if !slices.Contains(a.Config.Middleware, "auth") || a.authenticator != nil {
+		return nil
+	}
+	if len(a.Config.AuthTokens) == 0 {
+		return errors.New("the auth middleware needs an Authenticator or GSD_AUTH_TOKENS")
+	}
+	a.authenticator = StaticTokenAuthenticator{Tokens: a.Config.AuthTokens}
+	return nil
+}
+
+type serverKey struct{}
+
+// contextWithServer makes a available to the handlers through ServerFromContext.
+func contextWithServer(ctx context.Context, a *APIServer) context.Context {
+	return context.WithValue(ctx, serverKey{}, a)
+}
 
-	return &APIServer{
-		Config: config,
-		Logger: logger,
-	}, nil
+// ServerFromContext returns the server handling the request, so handlers can reach it, e.g.
+// for ActiveRequests, without closing over it. Every request context of Run carries it.
+func ServerFromContext(ctx context.Context) (*APIServer, bool) {
+	a, ok := ctx.Value(serverKey{}).(*APIServer)
+	return a, ok
 }
 
 func (a *APIServer) Run(ctx context.Context) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", makeHTTPHandlerFunc(a.handleReadiness)) // Setup readiness endpoint
-	mux.HandleFunc("/", makeHTTPHandlerFunc(a.handleHelloWorld))       // Setup hello world endpoint
+	mux := a.newMux()
+	baseCtx := contextWithServer(ctx, a)
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", a.Config.Port),
-		Handler: otelhttp.NewHandler(mux, "http.server"),
+		Handler: a.buildHandler(mux),
+		// Every request context derives from ctx: otelhttp and the middlewares only add values
+		// to r.Context(), so cancelling ctx cancels the context of every in-flight handler.
 		BaseContext: func(_ net.Listener) context.Context {
-			return ctx
+			return baseCtx
 		},
+		ConnState:    a.handshakes.track(NewConnectionStateTracker(a.meter())),
+		ReadTimeout:  a.Config.ReadTimeout,
+		WriteTimeout: a.Config.WriteTimeout,
+	}
+	if a.Config.tlsEnabled() {
+		// net/http bounds the TLS handshake by the shortest of its timeouts, the header read
+		// one included, so a client can't hold a connection open by never finishing it
+		tlsConfig, err := newTLSConfig(a.Config)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+		server.ReadHeaderTimeout = a.Config.TLSHandshakeTimeout
+
+		timer := newTLSHandshakeTimer(a.meter(), a.Logger.Named("tls"))
+		timer.instrument(server.TLSConfig)
+
+		if a.Config.TLSClientCAFile != "" {
+			server.Handler = clientIdentityHandler(server.Handler, "/livez", "/healthz")
+		}
+	}
+	if a.Config.HTTP2Cleartext {
+		// Shutdown sends GOAWAY on every HTTP/2 connection, so clients stop opening streams
+		// while the streams already in flight are allowed to finish.
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	var adminServer *http.Server
+	if a.Config.AdminPort > 0 {
+		adminServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", a.Config.AdminPort),
+			Handler: AdminAuthMiddleware(a.Config.AdminToken)(a.adminMux),
+			BaseContext: func(_ net.Listener) context.Context {
+				return baseCtx
+			},
+		}
 	}
 
-	a.server = server
+	// A shutdown may come before Run got this far, e.g. on a signal received right away
+	a.serversMu.Lock()
+	if a.serversClosed {
+		a.serversMu.Unlock()
+		return http.ErrServerClosed
+	}
+	server.SetKeepAlivesEnabled(!a.isShuttingDown.Load())
+	a.server, a.adminServer = server, adminServer
+	a.serversMu.Unlock()
+	a.publicHandler = server.Handler // read by the internal requests, after a.ready is closed
 
-	return server.ListenAndServe()
+	if adminServer != nil {
+		go func() {
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				a.Logger.Error("Admin server failed", zap.Error(err))
+			}
+		}()
+	}
+
+	ln, err := a.listen(server.Addr)
+	if err != nil {
+		return err
+	}
+
+	go a.runHeartbeat(ctx.Done())
+	go a.recordRejected(ctx.Done())
+	go a.runWarmup(ctx)
+	go func() {
+		<-a.ready
+		a.startChaosRequests(ctx)
+	}()
+	if a.watchdog != nil {
+		a.watchdog.Start()
+	}
+	a.Events.Record(EventLifecycle, "server started", "port", fmt.Sprint(a.Config.Port))
+
+	// The listener is bound, connections queue up until Serve accepts them
+	close(a.ready)
+	if a.Config.tlsEnabled() {
+		// Serving the TLS listener rather than ServeTLS keeps server.TLSConfig the config of
+		// the handshakes, the one the handshake timer clones
+		return server.Serve(tls.NewListener(ln, server.TLSConfig))
+	}
+	return server.Serve(ln)
+}
+
+// servers returns the public and admin servers, nil until Run creates them.
+func (a *APIServer) servers() (server, admin *http.Server) {
+	a.serversMu.Lock()
+	defer a.serversMu.Unlock()
+	return a.server, a.adminServer
+}
+
+// meter returns the meter used by the server's own instruments.
+func (a *APIServer) meter() metric.Meter {
+	return a.otel.MeterProvider().Meter(_instrumentationName)
 }
 
-// Marks the server as shutting down.
+// Tracer returns the tracer of the server's own spans.
+func (a *APIServer) Tracer() trace.Tracer {
+	return a.otel.TracerProvider().Tracer(_instrumentationName)
+}
+
+// Marks the server as shutting down, disables keep-alives, quiesces the consumers and cancels
+// the worker context: all the ingress is stopped before any work is drained.
+// Only the first call has an effect; concurrent calls return once it has completed.
 func (a *APIServer) InitiateShutdown() {
-	a.isShuttingDown.Store(true)
+	a.initiateOnce.Do(func() {
+		a.isShuttingDown.Store(true)
+		a.drainInFlight.Store(a.inFlight.Load())
+		a.notReadyAt.Store(time.Now().UnixNano())
+		if server, _ := a.servers(); server != nil {
+			// Connections are closed after their current request instead of being reused
+			server.SetKeepAlivesEnabled(false)
+		}
+		a.Events.Record(EventState, "readiness flipped to not ready")
+		a.quiesceIngress()
+		a.Events.Record(EventState, "ingress quiesced")
+		a.cancelWorkers()
+		if a.watchdog != nil {
+			a.watchdog.Stop() // the traffic is expected to dry up
+		}
+	})
+}
+
+// WorkerContext returns the context background workers should run with.
+// It is cancelled as soon as the drain phase starts.
+func (a *APIServer) WorkerContext() context.Context {
+	return a.workerCtx
 }
 
-// Shutdown the HTTP server.
+// Shutdown the HTTP server, then the admin one. HTTP/2 clients receive a GOAWAY frame:
+// streams already opened complete, new ones are refused.
+// Connections still in the new state (stalled in the TLS handshake) are closed at the deadline.
 func (a *APIServer) Shutdown(ctx context.Context) error {
-	return a.server.Shutdown(ctx)
+	stop := a.closeNewConnsAtDeadline(ctx)
+	defer stop()
+
+	a.drained.Store(true)
+
+	// Without servers, Run hasn't started them and now never will
+	a.serversMu.Lock()
+	a.serversClosed = true
+	server, adminServer := a.server, a.adminServer
+	a.serversMu.Unlock()
+
+	var err error
+	if server != nil {
+		err = server.Shutdown(ctx)
+	}
+	if adminServer != nil {
+		err = errors.Join(err, adminServer.Shutdown(ctx))
+	}
+	a.recordOutcome(EventLifecycle, "http server closed", err)
+	return err
+}
+
+// RegisterShutdownHook adds fn to the functions run by ShutdownResources.
+// Hooks run in registration order.
+func (a *APIServer) RegisterShutdownHook(name string, fn func(context.Context) error) {
+	a.shutdownHooks = append(a.shutdownHooks, shutdownHook{name: name, fn: a.chaosHook(name, fn)})
+}
+
+// OnDrain registers fn to run when the drain phase starts, right after the readiness flip.
+// Drain hooks run in registration order and share the drain budget.
+func (a *APIServer) OnDrain(name string, fn func(context.Context) error) {
+	a.drainHooks = append(a.drainHooks, shutdownHook{name: name, fn: a.chaosHook(name, fn)})
+}
+
+// HookNames returns the names of the drain and shutdown hooks, in the order they run.
+func (a *APIServer) HookNames() (drain, shutdown []string) {
+	for _, hook := range a.drainHooks {
+		drain = append(drain, hook.name)
+	}
+	for _, hook := range a.shutdownHooks {
+		shutdown = append(shutdown, hook.name)
+	}
+	return drain, shutdown
+}
+
+// RunDrainHooks runs the OnDrain hooks and aggregates their errors.
+// The hooks run once; later and concurrent calls wait for them and return the same error.
+func (a *APIServer) RunDrainHooks(ctx context.Context) error {
+	a.drainHooksOnce.Do(func() {
+		errs := errorCollector{limit: _maxShutdownErrors}
+		for _, hook := range a.drainHooks {
+			hookErr := a.runHook(ctx, hook.name, hook.fn)
+			a.recordOutcome(EventHook, "drain hook "+hook.name, hookErr)
+			if hookErr != nil {
+				errs.add(fmt.Errorf("%s: %w", hook.name, hookErr))
+			}
+		}
+		a.drainHooksErr = errs.err()
+	})
+	return a.drainHooksErr
+}
+
+// OnShutdownComplete registers fn to run once the whole shutdown sequence has finished,
+// after every resource is closed and right before the process exits.
+func (a *APIServer) OnShutdownComplete(fn func()) {
+	a.onShutdownComplete = append(a.onShutdownComplete, fn)
+}
+
+// shutdownComplete runs the OnShutdownComplete hooks. Only the first call has any effect.
+func (a *APIServer) shutdownComplete() {
+	a.shutdownCompleteOnce.Do(func() {
+		for _, fn := range a.onShutdownComplete {
+			fn()
+		}
+	})
+}
+
+// syncLogger flushes the logger the server created, as the very last step before exit.
+// An injected logger is left to its owner.
+func (a *APIServer) syncLogger() error {
+	if !a.ownsLogger {
+		return nil
+	}
+	return a.Logger.Sync()
 }
 
 // Shutdown runs all registered shutdown functions and aggregates their errors.
 func (a *APIServer) ShutdownResources(ctx context.Context) error {
+	errs := errorCollector{limit: _maxShutdownErrors}
+	for _, hook := range a.shutdownHooks {
+		hookErr := a.runHook(ctx, hook.name, func(ctx context.Context) error {
+			return a.runShutdownHook(ctx, hook)
+		})
+		a.recordOutcome(EventHook, "shutdown hook "+hook.name, hookErr)
+		if hookErr != nil {
+			errs.add(fmt.Errorf("%s: %w", hook.name, hookErr))
+		}
+	}
+	return errs.err()
+}
+
+// runHook runs fn, the hook name, until it returns or its deadline passes: the end of ctx or
+// Config.ShutdownHookTimeout, whichever comes first. A hook deaf to the cancellation is left
+// running past its deadline, and fails with ErrHookTimeout, so it can't hold its phase.
+func (a *APIServer) runHook(ctx context.Context, name string, fn func(context.Context) error) error {
+	if timeout := a.Config.ShutdownHookTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		a.Logger.Error("Hook timed out, leaving it running", zap.String("hook", name), zap.Error(ctx.Err()))
+		return fmt.Errorf("%w: %w", ErrHookTimeout, ctx.Err())
+	}
+}
+
+// runShutdownHook runs hook, retrying it with exponential backoff while it fails with
+// a retryable error, up to Config.MaxShutdownRetries times.
+func (a *APIServer) runShutdownHook(ctx context.Context, hook shutdownHook) error {
+	backoff := _shutdownRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := hook.fn(ctx)
+		if err == nil || attempt >= a.Config.MaxShutdownRetries || !isRetryable(err) {
+			return err
+		}
+
+		a.Logger.Warn("Shutdown hook failed, retrying",
+			zap.String("hook", hook.name),
+			zap.Int("attempt", attempt+1),
+			zap.Error(err),
+		)
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// ShutdownTelemetry waits (bounded) for worker spans and for requests still in flight, the
+// ones cancelled past the shutdown deadline, to end, then flushes and shuts down the
+// OpenTelemetry providers the server created. An injected provider is left to its owner.
+// It must run last.
+func (a *APIServer) ShutdownTelemetry(ctx context.Context) error {
+	spansCtx, cancel := context.WithTimeout(ctx, _workerSpanGracePeriod)
+	defer cancel()
+
 	var err error
-	for _, fn := range a.shutdownFuncs {
-		err = errors.Join(err, fn(ctx))
+	if spansErr := a.Spans.Wait(spansCtx); spansErr != nil {
+		err = fmt.Errorf("wait for worker spans: %w", spansErr)
+	}
+	if reqErr := a.waitRequestsDone(spansCtx); reqErr != nil {
+		err = errors.Join(err, fmt.Errorf("wait for %d requests in flight: %w", a.InFlightRequests(), reqErr))
+	}
+	if a.ownsOTel {
+		err = errors.Join(err, a.otel.Shutdown(ctx))
+	}
+	if a.otelLogs != nil {
+		// Later entries only reach the base logger
+		err = errors.Join(err, a.otelLogs.Shutdown(ctx))
 	}
+	a.recordOutcome(EventLifecycle, "telemetry closed", err)
 	return err
 }
 
 func (a *APIServer) handleReadiness(w http.ResponseWriter, r *http.Request) error {
 	if r.Method == http.MethodGet {
+		if a.isProbeUserAgent(r) {
+			return a.handleProbeReadiness(w, r)
+		}
 		return a.handleGetReadiness(w, r)
 	}
 
 	return fmt.Errorf("method not allowed: %s", r.Method)
 }
 
-func (a *APIServer) handleGetReadiness(w http.ResponseWriter, _ *http.Request) error {
-	if !a.isShuttingDown.Load() {
-		WriteJSON(
-			w,
-			http.StatusOK,
-			GetReadinessResponse{
-				Message: "ok",
-			},
-		)
-		return nil
+func (a *APIServer) handleGetReadiness(w http.ResponseWriter, r *http.Request) error {
+	shuttingDown := a.isShuttingDown.Load()
+	a.lastProbe.Store(&ProbeInfo{Time: time.Now(), Ready: !shuttingDown})
+
+	if shuttingDown {
+		return APIError{
+			Code:    503,
+			Message: "the server is shutting down",
+		}
 	}
 
-	return APIError{
-		Code:    503,
-		Message: "the server is shutting down",
+	if !a.warmedUp.Load() {
+		return APIError{
+			Code:    http.StatusServiceUnavailable,
+			Message: "the server is warming up",
+		}
 	}
+
+	name, run, err := a.checkHealth(r.Context())
+	if a.debounce(err, run) {
+		if err == nil {
+			return APIError{
+				Code:    http.StatusServiceUnavailable,
+				Message: "health checks recovering",
+			}
+		}
+		a.Logger.Warn("Health check failed", zap.String("check", name), zap.Error(err))
+		return APIError{
+			Code:    http.StatusServiceUnavailable,
+			Message: fmt.Sprintf("health check %q failed", name),
+		}
+	}
+	if err != nil {
+		a.Logger.Warn("Health check failed within the readiness debounce window", zap.String("check", name), zap.Error(err))
+	}
+
+	// An open circuit degrades the service, it stays ready: 207 tells the two apart
+	status, message := http.StatusOK, "ok"
+	breakers, anyOpen := a.circuitBreakerStates()
+	if anyOpen {
+		status, message = http.StatusMultiStatus, "degraded"
+	}
+
+	return WriteJSON(
+		w,
+		status,
+		GetReadinessResponse{
+			Message:         message,
+			CircuitBreakers: breakers,
+			Warnings:        a.runHealthWarnings(r.Context()),
+		},
+	)
 }
 
 func (a *APIServer) handleHelloWorld(w http.ResponseWriter, r *http.Request) error {
@@ -167,9 +858,24 @@ func (a *APIServer) handleGetHelloWorld(w http.ResponseWriter, r *http.Request)
 		w.Write([]byte("Hello, World!"))
 		return nil
 	case <-r.Context().Done():
+		// The cause tells apart the server cancelling in-flight requests at the end of the
+		// drain from the client giving up on its own.
+		if errors.Is(context.Cause(r.Context()), ErrServerShuttingDown) {
+			return APIError{
+				Code:    http.StatusServiceUnavailable,
+				Message: "request canceled, the server is shutting down",
+			}
+		}
 		return APIError{
-			Code:    http.StatusServiceUnavailable,
-			Message: "request canceled",
+			Code:    _statusClientClosedRequest,
+			Message: "request canceled by the client",
 		}
 	}
 }
+
+func (a *APIServer) handleNotFound(_ http.ResponseWriter, r *http.Request) error {
+	return APIError{
+		Code:    http.StatusNotFound,
+		Message: fmt.Sprintf("resource not found: %s", r.URL.Path),
+	}
+}
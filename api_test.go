@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHelloWorldCancellationSources(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	handler := a.makeHTTPHandlerFunc(a.handleHelloWorld)
+
+	tests := []struct {
+		name       string
+		cause      error
+		wantStatus int
+	}{
+		{"server shutting down", ErrServerShuttingDown, http.StatusServiceUnavailable},
+		{"client gone", context.Canceled, _statusClientClosedRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(tt.cause)
+
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// failingWriter accepts the status and headers, then fails every write.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestWriteJSONPartialWrite(t *testing.T) {
+	w := failingWriter{httptest.NewRecorder()}
+
+	err := WriteJSON(w, http.StatusOK, map[string]string{"hello": "world"})
+	if !errors.Is(err, ErrPartialWrite) {
+		t.Fatalf("WriteJSON = %v, want ErrPartialWrite", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the one sent before the failure", w.Code)
+	}
+}
+
+func TestWriteJSONEncodingErrorLeavesResponseUntouched(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	err := WriteJSON(rec, http.StatusOK, make(chan int))
+	if err == nil || errors.Is(err, ErrPartialWrite) {
+		t.Fatalf("WriteJSON = %v, want the encoding error", err)
+	}
+	if rec.Flushed || rec.Body.Len() > 0 || len(rec.Header()) > 0 {
+		t.Fatal("response written despite the encoding error")
+	}
+}
+
+func TestPartialWriteLoggedWithoutPanic(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	handler := a.makeHTTPHandlerFunc(func(w http.ResponseWriter, _ *http.Request) error {
+		return WriteJSON(w, http.StatusOK, "payload")
+	})
+
+	handler(failingWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if n := a.Logs.FilterMessage("Response partially written, the client likely went away").Len(); n != 1 {
+		t.Fatalf("partial write logged %d times, want 1", n)
+	}
+}
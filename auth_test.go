@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// authenticatorFunc adapts a function to an Authenticator.
+type authenticatorFunc func(r *http.Request) (Principal, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (Principal, error) {
+	return f(r)
+}
+
+// denyAll fails every authentication.
+var denyAll = authenticatorFunc(func(*http.Request) (Principal, error) {
+	return Principal{}, ErrUnauthenticated
+})
+
+func TestAuthMiddleware(t *testing.T) {
+	allow := authenticatorFunc(func(*http.Request) (Principal, error) {
+		return Principal{Subject: "billing-service"}, nil
+	})
+
+	for _, tt := range []struct {
+		name   string
+		auth   Authenticator
+		status int
+	}{
+		{"passing", allow, http.StatusOK},
+		{"failing", denyAll, http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+			handler := AuthMiddleware(tt.auth, zap.NewNop(), "/healthz")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, _ := PrincipalFromContext(r.Context())
+				subject = principal.Subject
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && subject != "billing-service" {
+				t.Fatalf("principal subject = %q, want billing-service", subject)
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Fatal("401 without a WWW-Authenticate challenge")
+			}
+
+			// Exempt paths are let through either way
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("exempt path status = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthLetsTheInternalRoutesThrough(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "recovery,auth")
+	a := newUnstartedTestServer(t, WithAuthenticator(denyAll))
+
+	req := httptest.NewRequest(http.MethodGet, _selfTestPath, nil)
+	req.Header.Set(_selfTestHeader, "marker")
+	if rec := a.serve(req); rec.Code != http.StatusOK {
+		t.Fatalf("self-test echo = %d, want 200", rec.Code)
+	}
+
+	// The marker of the internal requests can't be set from the outside
+	if rec := a.serve(httptest.NewRequest(http.MethodGet, "/hello", nil)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("protected route = %d, want 401", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set(_warmupHeader, "true")
+	if rec := a.serve(req); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("protected route with the warmup header = %d, want 401", rec.Code)
+	}
+}
+
+func TestWarmupRequestsSkipAuthentication(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "recovery,auth")
+	t.Setenv("GSD_WARMUP_REQUESTS", `[{"method":"GET","path":"/orders"}]`)
+	warmed := make(chan struct{})
+	a := NewTestAPIServer(t, WithAuthenticator(denyAll), withRoute("GET /orders", func(w http.ResponseWriter, r *http.Request) error {
+		if isInternalRequest(r.Context()) {
+			close(warmed)
+		}
+		return nil
+	}))
+
+	select {
+	case <-warmed:
+	case <-time.After(_testServerStartTimeout):
+		t.Fatal("the warmup request never reached the protected route")
+	}
+	if code := getStatus(t, a.URL+"/orders"); code != http.StatusUnauthorized {
+		t.Fatalf("external request = %d, want 401", code)
+	}
+}
+
+func TestStaticTokenAuthenticator(t *testing.T) {
+	auth := StaticTokenAuthenticator{Tokens: map[string]string{"s3cret": "ops@example.com"}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	if p, err := auth.Authenticate(req); err != nil || p.Subject != "ops@example.com" {
+		t.Fatalf("Authenticate = %+v, %v", p, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer wrong")
+	if _, err := auth.Authenticate(req); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("Authenticate with a wrong token = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestSubjectInLogsAndSpans(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "recovery,auth,logging")
+	t.Setenv("GSD_AUTH_TOKENS", "svc-token:billing-service,user-token:ada@example.com")
+
+	tests := []struct {
+		token   string
+		subject string
+	}{
+		{"svc-token", "billing-service"},
+		{"user-token", hashIdentifier("ada@example.com")}, // emails are redacted
+	}
+	for _, tt := range tests {
+		a := newUnstartedTestServer(t, withRoute("GET /orders", func(w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		if rec := a.serve(req); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+
+		entries := a.Logs.FilterMessage("Request served").All()
+		if len(entries) != 1 || entries[0].ContextMap()["subject"] != tt.subject {
+			t.Fatalf("access log entries = %v, want subject %q", entries, tt.subject)
+		}
+		spans := a.Traces.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("got %d spans, want 1", len(spans))
+		}
+		found := false
+		for _, attr := range spans[0].Attributes() {
+			if attr.Key == "enduser.id" {
+				found = attr.Value.AsString() == tt.subject
+			}
+		}
+		if !found {
+			t.Fatalf("span attributes = %v, want enduser.id %q", spans[0].Attributes(), tt.subject)
+		}
+	}
+}
+
+func TestNoSubjectOnExemptPaths(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "recovery,auth,logging")
+	t.Setenv("GSD_AUTH_TOKENS", "svc-token:billing-service")
+	a := newUnstartedTestServer(t)
+	a.warmedUp.Store(true)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Authorization", "Bearer svc-token")
+	a.serve(req)
+
+	entries := a.Logs.FilterMessage("Request served").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d access log entries, want 1", len(entries))
+	}
+	if _, ok := entries[0].ContextMap()["subject"]; ok {
+		t.Fatalf("probe logged with a subject: %v", entries[0].ContextMap())
+	}
+}
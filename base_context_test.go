@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCancellingOngoingContextCancelsRequestContext(t *testing.T) {
+	ongoingCtx, stopOngoing := context.WithCancelCause(context.Background())
+	defer stopOngoing(nil)
+
+	started := make(chan struct{})
+	observed := make(chan error, 1)
+	a := startTestAPIServer(t, ongoingCtx, withRoute("GET /wait", func(w http.ResponseWriter, r *http.Request) error {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			observed <- context.Cause(r.Context())
+		case <-time.After(5 * time.Second):
+			observed <- errors.New("request context not cancelled")
+		}
+		return nil
+	}))
+
+	go func() {
+		if resp, err := http.Get(a.URL + "/wait"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	stopOngoing(ErrServerShuttingDown)
+	if err := <-observed; !errors.Is(err, ErrServerShuttingDown) {
+		t.Fatalf("handler observed %v, want ErrServerShuttingDown", err)
+	}
+}
+
+func TestServerFromContext(t *testing.T) {
+	found := make(chan *APIServer, 1)
+	a := NewTestAPIServer(t, withRoute("GET /server", func(w http.ResponseWriter, r *http.Request) error {
+		srv, _ := ServerFromContext(r.Context())
+		found <- srv
+		return nil
+	}))
+
+	resp, err := http.Get(a.URL + "/server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if srv := <-found; srv != a.APIServer {
+		t.Fatalf("ServerFromContext = %p, want the server handling the request %p", srv, a.APIServer)
+	}
+
+	if srv, ok := ServerFromContext(context.Background()); ok || srv != nil {
+		t.Fatalf("ServerFromContext outside a request = %p, %v, want nil, false", srv, ok)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCacheMiss   = errors.New("cache miss")
+	ErrCacheClosed = errors.New("cache closed")
+)
+
+// Cache is a key/value store the API depends on, such as Redis or Memcached.
+// Implementations are expected to honor context cancellation on every call.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// RegisterCache wires c into the server lifecycle: Ping becomes a readiness check
+// and Close runs together with the other shutdown hooks.
+func (a *APIServer) RegisterCache(name string, c Cache) {
+	a.RegisterHealthCheck(name, c.Ping)
+	a.RegisterShutdownHook(name, func(_ context.Context) error {
+		return c.Close()
+	})
+}
+
+type memoryCacheItem struct {
+	value  string
+	expiry time.Time
+}
+
+// MemoryCache is an in-process Cache, useful for local runs and as a reference implementation.
+type MemoryCache struct {
+	mu     sync.RWMutex
+	items  map[string]memoryCacheItem
+	closed bool
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		items: map[string]memoryCacheItem{},
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return "", ErrCacheClosed
+	}
+
+	item, ok := c.items[key]
+	if !ok || (!item.expiry.IsZero() && time.Now().After(item.expiry)) {
+		return "", ErrCacheMiss
+	}
+	return item.value, nil
+}
+
+// Set stores value under key. A zero ttl means the item never expires.
+func (c *MemoryCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrCacheClosed
+	}
+
+	item := memoryCacheItem{value: value}
+	if ttl > 0 {
+		item.expiry = time.Now().Add(ttl)
+	}
+	c.items[key] = item
+	return nil
+}
+
+func (c *MemoryCache) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return ErrCacheClosed
+	}
+	return nil
+}
+
+// Close releases the stored items. Calls after the first one are no-ops.
+func (c *MemoryCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.items = nil
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCache is a Cache whose Ping result is set by the test.
+type fakeCache struct {
+	pingErr atomic.Pointer[error]
+	closed  atomic.Int32
+}
+
+func (c *fakeCache) Get(context.Context, string) (string, error) { return "", ErrCacheMiss }
+
+func (c *fakeCache) Set(context.Context, string, string, time.Duration) error { return nil }
+
+func (c *fakeCache) Ping(context.Context) error {
+	if err := c.pingErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (c *fakeCache) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestInjectedCacheIsHealthCheckedAndClosed(t *testing.T) {
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "0")
+	cache := &fakeCache{}
+	a := newUnstartedTestServer(t, withBackends(func(b *Backends) { b.Cache = cache }))
+
+	if name, _, err := a.checkHealth(context.Background()); err != nil {
+		t.Fatalf("health check %q failed with a healthy cache: %v", name, err)
+	}
+
+	errDown := errors.New("connection refused")
+	cache.pingErr.Store(&errDown)
+	name, _, err := a.checkHealth(context.Background())
+	if name != "cache" || !errors.Is(err, errDown) {
+		t.Fatalf("checkHealth = %q, %v; want the cache ping error", name, err)
+	}
+
+	if got := cache.closed.Load(); got != 0 {
+		t.Fatalf("cache closed %d times before shutdown", got)
+	}
+	if err := a.ShutdownResources(context.Background()); err != nil {
+		t.Fatalf("ShutdownResources: %v", err)
+	}
+	if got := cache.closed.Load(); got != 1 {
+		t.Fatalf("cache closed %d times by shutdown, want 1", got)
+	}
+}
+
+func TestMemoryCacheClosed(t *testing.T) {
+	c := NewMemoryCache()
+	ctx := context.Background()
+	if err := c.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if v, err := c.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("Get = %q, %v; want v", v, err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("Get after Close = %v, want ErrCacheClosed", err)
+	}
+	if err := c.Ping(ctx); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("Ping after Close = %v, want ErrCacheClosed", err)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const _chaosHangPath = "/_chaos/hang"
+
+// ErrChaos is the error injected by the chaos faults.
+var ErrChaos = errors.New("chaos fault injected")
+
+// Targets of the chaos faults.
+const (
+	ChaosPhase      = "phase"      // phase:<drain|http_shutdown|resources|telemetry>:<action>
+	ChaosHook       = "hook"       // hook:<drain or shutdown hook name>:<action>
+	ChaosMiddleware = "middleware" // middleware:<name>:panic
+	ChaosRequests   = "requests"   // requests:<count>:hang:<duration>
+)
+
+// ChaosFault is a failure injected on purpose, written target:name:action[:arg]. Actions are
+// delay:<duration> (honors cancellation), hang:<duration> (ignores it), error and panic.
+type ChaosFault struct {
+	Target string
+	Name   string
+	Action string
+	Delay  time.Duration
+}
+
+func (f ChaosFault) String() string {
+	s := f.Target + ":" + f.Name + ":" + f.Action
+	if f.Delay > 0 {
+		s += ":" + f.Delay.String()
+	}
+	return s
+}
+
+// ParseChaosFault parses a fault of Config.ChaosFaults.
+func ParseChaosFault(s string) (ChaosFault, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return ChaosFault{}, fmt.Errorf("chaos fault %q: want target:name:action[:arg]", s)
+	}
+	f := ChaosFault{Target: parts[0], Name: parts[1], Action: parts[2]}
+
+	switch f.Target {
+	case ChaosPhase, ChaosHook:
+	case ChaosMiddleware:
+		if f.Action != "panic" {
+			return ChaosFault{}, fmt.Errorf("chaos fault %q: middlewares can only panic", s)
+		}
+	case ChaosRequests:
+		if n, err := strconv.Atoi(f.Name); err != nil || n <= 0 {
+			return ChaosFault{}, fmt.Errorf("chaos fault %q: invalid request count", s)
+		}
+		if f.Action != "hang" {
+			return ChaosFault{}, fmt.Errorf("chaos fault %q: requests can only hang", s)
+		}
+	default:
+		return ChaosFault{}, fmt.Errorf("chaos fault %q: unknown target %q", s, f.Target)
+	}
+
+	switch f.Action {
+	case "delay", "hang":
+		if len(parts) != 4 {
+			return ChaosFault{}, fmt.Errorf("chaos fault %q: %s needs a duration", s, f.Action)
+		}
+		d, err := time.ParseDuration(parts[3])
+		if err != nil {
+			return ChaosFault{}, fmt.Errorf("chaos fault %q: %w", s, err)
+		}
+		f.Delay = d
+	case "error", "panic":
+	default:
+		return ChaosFault{}, fmt.Errorf("chaos fault %q: unknown action %q", s, f.Action)
+	}
+	return f, nil
+}
+
+// inject applies the fault: it sleeps, returns ErrChaos or panics.
+func (f ChaosFault) inject(ctx context.Context) error {
+	switch f.Action {
+	case "delay":
+		select {
+		case <-time.After(f.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	case "hang":
+		time.Sleep(f.Delay)
+	case "error":
+		return fmt.Errorf("%w: %s", ErrChaos, f)
+	case "panic":
+		panic(fmt.Sprintf("%s: %s", ErrChaos, f))
+	}
+	return nil
+}
+
+// loadChaos enables the configured faults, which is only ever done outside production.
+func (a *APIServer) loadChaos() {
+	if !a.Config.ChaosEnabled {
+		return
+	}
+	if a.Config.IsProduction() {
+		a.Logger.Warn("Chaos faults are configured but never enabled in production")
+		return
+	}
+
+	for _, s := range a.Config.ChaosFaults {
+		f, _ := ParseChaosFault(s) // checked by Config.Validate
+		a.chaos = append(a.chaos, f)
+		a.Logger.Warn("CHAOS FAULT ENABLED", zap.Stringer("fault", f))
+	}
+}
+
+func (a *APIServer) chaosFaults(target, name string) []ChaosFault {
+	var faults []ChaosFault
+	for _, f := range a.chaos {
+		if f.Target == target && f.Name == name {
+			faults = append(faults, f)
+		}
+	}
+	return faults
+}
+
+// chaosHook wraps fn with the faults targeting the hook name.
+func (a *APIServer) chaosHook(name string, fn func(context.Context) error) func(context.Context) error {
+	faults := a.chaosFaults(ChaosHook, name)
+	if len(faults) == 0 {
+		return fn
+	}
+	return func(ctx context.Context) error {
+		for _, f := range faults {
+			if err := f.inject(ctx); err != nil {
+				return err
+			}
+		}
+		return fn(ctx)
+	}
+}
+
+// chaosMiddleware panics in the handler chain, right inside the middleware name.
+func (a *APIServer) chaosMiddleware(name string) func(http.Handler) http.Handler {
+	faults := a.chaosFaults(ChaosMiddleware, name)
+	if len(faults) == 0 {
+		return nil
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isInternalRequest(r.Context()) {
+				faults[0].inject(r.Context())
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// startChaosRequests sends requests that ignore cancellation, so there are always requests
+// in flight when the shutdown starts. They go through the public listener, over TLS when it
+// serves HTTPS; a listener requiring client certificates can't be reached and is skipped.
+func (a *APIServer) startChaosRequests(ctx context.Context) {
+	var faults []ChaosFault
+	for _, f := range a.chaos {
+		if f.Target == ChaosRequests {
+			faults = append(faults, f)
+		}
+	}
+	if len(faults) == 0 {
+		return
+	}
+
+	scheme, client := "http", http.DefaultClient
+	if a.Config.tlsEnabled() {
+		if a.Config.TLSClientCAFile != "" {
+			a.Logger.Warn("Chaos requests skipped, the public listener requires client certificates")
+			return
+		}
+		// The listener's own certificate, its name needn't match the loopback address
+		scheme = "https"
+		client = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+	}
+
+	for _, f := range faults {
+		n, _ := strconv.Atoi(f.Name)
+		url := fmt.Sprintf("%s://127.0.0.1:%d%s?d=%s", scheme, a.Config.Port, _chaosHangPath, f.Delay)
+		for range n {
+			go func() {
+				req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodGet, url, nil)
+				if err != nil {
+					return
+				}
+				if resp, err := client.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}()
+		}
+	}
+}
+
+// chaosHangLimit returns the longest hang of the requests faults, the cap of handleChaosHang.
+// ok is false without any requests fault.
+func (a *APIServer) chaosHangLimit() (limit time.Duration, ok bool) {
+	for _, f := range a.chaos {
+		if f.Target == ChaosRequests {
+			limit, ok = max(limit, f.Delay), true
+		}
+	}
+	return limit, ok
+}
+
+// handleChaosHang serves the requests of startChaosRequests. Only the server itself may call
+// it, from the loopback interface, and for no longer than the longest configured hang.
+func (a *APIServer) handleChaosHang(w http.ResponseWriter, r *http.Request) error {
+	if !isInternalRequest(r.Context()) && !isLoopback(r.RemoteAddr) {
+		return APIError{Code: http.StatusForbidden, Message: "forbidden"}
+	}
+	d, err := time.ParseDuration(r.URL.Query().Get("d"))
+	if err != nil {
+		return APIError{Code: http.StatusBadRequest, Message: "invalid duration"}
+	}
+	limit, _ := a.chaosHangLimit()
+	time.Sleep(min(d, limit)) // deliberately deaf to the request context
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// isLoopback reports whether addr, a host:port remote address, is on the loopback interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// chaosServer injects the phase faults around the phases of a Server.
+type chaosServer struct {
+	Server
+	faults func(target, name string) []ChaosFault
+}
+
+// WithChaos wraps app so the configured phase faults run before each shutdown phase.
+// Without any phase fault it returns app itself.
+func WithChaos(app *APIServer) Server {
+	for _, f := range app.chaos {
+		if f.Target == ChaosPhase {
+			return chaosServer{Server: app, faults: app.chaosFaults}
+		}
+	}
+	return app
+}
+
+func (s chaosServer) phase(ctx context.Context, name string, fn func(context.Context) error) error {
+	for _, f := range s.faults(ChaosPhase, name) {
+		if err := f.inject(ctx); err != nil {
+			return errors.Join(err, fn(ctx))
+		}
+	}
+	return fn(ctx)
+}
+
+func (s chaosServer) RunDrainHooks(ctx context.Context) error {
+	return s.phase(ctx, "drain", s.Server.RunDrainHooks)
+}
+
+func (s chaosServer) Shutdown(ctx context.Context) error {
+	return s.phase(ctx, "http_shutdown", s.Server.Shutdown)
+}
+
+func (s chaosServer) ShutdownResources(ctx context.Context) error {
+	return s.phase(ctx, "resources", s.Server.ShutdownResources)
+}
+
+func (s chaosServer) ShutdownTelemetry(ctx context.Context) error {
+	return s.phase(ctx, "telemetry", s.Server.ShutdownTelemetry)
+}
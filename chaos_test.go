@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaosHookErrorDoesNotStopOtherHooks(t *testing.T) {
+	t.Setenv("GSD_CHAOS_ENABLED", "true")
+	t.Setenv("GSD_CHAOS_FAULTS", "hook:cache:error,phase:resources:error")
+	a := newUnstartedTestServer(t)
+
+	var cacheClosed, dbClosed bool
+	a.RegisterShutdownHook("cache", func(context.Context) error {
+		cacheClosed = true
+		return nil
+	})
+	a.RegisterShutdownHook("db", func(context.Context) error {
+		dbClosed = true
+		return nil
+	})
+
+	err := WithChaos(a.APIServer).ShutdownResources(context.Background())
+	if !errors.Is(err, ErrChaos) {
+		t.Fatalf("ShutdownResources = %v, want the injected error", err)
+	}
+	// The phase fault doesn't skip the phase, and the hook fault only fails its hook
+	if cacheClosed || !dbClosed {
+		t.Fatalf("cache closed = %v, db closed = %v; want only the db closed", cacheClosed, dbClosed)
+	}
+}
+
+func TestChaosDrainOverrunBoundedByBudget(t *testing.T) {
+	t.Setenv("GSD_CHAOS_ENABLED", "true")
+	t.Setenv("GSD_CHAOS_FAULTS", "hook:mesh:delay:1m")
+	a := newUnstartedTestServer(t)
+	a.OnDrain("mesh", func(context.Context) error { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := a.RunDrainHooks(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunDrainHooks = %v, want the drain budget exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("drain hooks took %v, want them bounded by the 50ms budget", elapsed)
+	}
+}
+
+func TestChaosHangingHookTimesOut(t *testing.T) {
+	t.Setenv("GSD_CHAOS_ENABLED", "true")
+	t.Setenv("GSD_CHAOS_FAULTS", "hook:cache:hang:1m")
+	t.Setenv("GSD_SHUTDOWN_HOOK_TIMEOUT", "100ms")
+	t.Setenv("GSD_PORT", strconv.Itoa(freePort(t)))
+	a := newUnstartedTestServer(t, WithSignalContext(cancelledSignalContext))
+	a.RegisterShutdownHook("cache", func(context.Context) error { return nil })
+	var dbClosed bool
+	a.RegisterShutdownHook("db", func(context.Context) error {
+		dbClosed = true
+		return nil
+	})
+
+	rootCtx, stop := a.SignalContext(context.Background())
+	defer stop()
+	start := time.Now()
+	report := newTestRunner(WithChaos(a.APIServer)).Run(rootCtx)
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("shutdown took %v, want the hanging hook cut at its 100ms timeout", elapsed)
+	}
+	if !dbClosed {
+		t.Fatal("the hook after the hanging one did not run")
+	}
+	if !slices.ContainsFunc(report.Errors, func(msg string) bool {
+		return strings.Contains(msg, "cache") && strings.Contains(msg, ErrHookTimeout.Error())
+	}) {
+		t.Fatalf("report errors = %v, want the cache hook timed out", report.Errors)
+	}
+	if report.Success || report.ExitCode(true) != 1 {
+		t.Fatalf("report success = %v, exit code = %d; want the strict exit code 1", report.Success, report.ExitCode(true))
+	}
+}
+
+func TestChaosFailedPhaseFailsShutdownReport(t *testing.T) {
+	srv := NewMockAPIServer()
+	runner := newTestRunner(chaosServer{Server: srv, faults: func(target, name string) []ChaosFault {
+		if target == ChaosPhase && name == "telemetry" {
+			return []ChaosFault{{Target: target, Name: name, Action: "error"}}
+		}
+		return nil
+	}})
+	started := runUntilStarted(srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	// A failed report makes the process exit with 1 in strict mode
+	if report := runner.Run(ctx); report.Success {
+		t.Fatal("report successful despite the injected telemetry fault")
+	}
+}
+
+func TestChaosNeverEnabledInProduction(t *testing.T) {
+	t.Setenv("GSD_ENV", "prod")
+	t.Setenv("GSD_CHAOS_ENABLED", "true")
+	t.Setenv("GSD_CHAOS_FAULTS", "hook:cache:error")
+	a := newUnstartedTestServer(t)
+
+	if len(a.chaos) > 0 {
+		t.Fatalf("chaos faults %v enabled in production", a.chaos)
+	}
+}
+
+func TestChaosHangRoute(t *testing.T) {
+	t.Run("without requests fault", func(t *testing.T) {
+		t.Setenv("GSD_CHAOS_ENABLED", "true")
+		t.Setenv("GSD_CHAOS_FAULTS", "hook:cache:error")
+		a := newUnstartedTestServer(t)
+
+		req := httptest.NewRequest(http.MethodGet, _chaosHangPath+"?d=1h", nil)
+		if code := a.serve(req).Code; code != http.StatusNotFound {
+			t.Fatalf("hang route without a requests fault = %d, want 404", code)
+		}
+	})
+
+	t.Setenv("GSD_CHAOS_ENABLED", "true")
+	t.Setenv("GSD_CHAOS_FAULTS", "requests:1:hang:50ms")
+	a := newUnstartedTestServer(t)
+
+	t.Run("remote caller", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, _chaosHangPath+"?d=1h", nil)
+		req.RemoteAddr = "203.0.113.7:40000"
+		if code := a.serve(req).Code; code != http.StatusForbidden {
+			t.Fatalf("hang route from a remote caller = %d, want 403", code)
+		}
+	})
+
+	t.Run("loopback caller", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, _chaosHangPath+"?d=1h", nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		start := time.Now()
+		if code := a.serve(req).Code; code != http.StatusNoContent {
+			t.Fatalf("hang route from loopback = %d, want 204", code)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("hang took %v, want it capped at the 50ms of the fault", elapsed)
+		}
+	})
+}
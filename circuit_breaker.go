@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// States of a CircuitBreaker.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// ErrCircuitOpen is returned by CircuitBreaker.Do while the circuit is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+var (
+	// errCallPanicked is recorded when the call of CircuitBreaker.Do panics.
+	errCallPanicked = errors.New("call panicked")
+	// errServerError is recorded for the 5xx responses going through a breaker transport.
+	errServerError = errors.New("server error")
+)
+
+// CircuitBreaker stops calling a failing dependency: after threshold consecutive failures
+// the circuit opens for openTimeout, then lets a single trial call through (half open),
+// which closes it again on success.
+type CircuitBreaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func NewCircuitBreaker(threshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:   max(threshold, 1),
+		openTimeout: openTimeout,
+		state:       CircuitClosed,
+	}
+}
+
+// Do calls fn unless the circuit is open, and records its outcome. A panicking fn counts
+// as a failure, and doesn't leave a half-open circuit waiting for its trial call forever.
+func (b *CircuitBreaker) Do(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := errCallPanicked
+	defer func() { b.record(err) }()
+	err = fn()
+	return err
+}
+
+// Transport returns a RoundTripper sending the requests through next while the circuit is
+// closed. Transport errors and 5xx responses count as failures; the responses are still
+// returned as is.
+func (b *CircuitBreaker) Transport(next http.RoundTripper) http.RoundTripper {
+	return breakerTransport{breaker: b, next: next}
+}
+
+type breakerTransport struct {
+	breaker *CircuitBreaker
+	next    http.RoundTripper
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	err := t.breaker.Do(func() error {
+		var err error
+		resp, err = t.next.RoundTrip(req)
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			return errServerError
+		}
+		return err
+	})
+	if errors.Is(err, errServerError) {
+		return resp, nil
+	}
+	return resp, err
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	return b.state
+}
+
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	switch {
+	case b.state == CircuitOpen:
+		return ErrCircuitOpen
+	case b.state == CircuitHalfOpen && b.trial:
+		return ErrCircuitOpen // a trial call is already in progress
+	case b.state == CircuitHalfOpen:
+		b.trial = true
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if err == nil {
+		b.state, b.failures = CircuitClosed, 0
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt = CircuitOpen, time.Now()
+	}
+}
+
+// refresh moves an open circuit to half open once openTimeout has elapsed.
+func (b *CircuitBreaker) refresh() {
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.openTimeout {
+		b.state = CircuitHalfOpen
+	}
+}
+
+// RegisterCircuitBreaker reports the state of breaker in the readiness probe. An open
+// circuit marks the service as degraded without taking it out of rotation.
+func (a *APIServer) RegisterCircuitBreaker(name string, breaker *CircuitBreaker) {
+	a.breakersMu.Lock()
+	defer a.breakersMu.Unlock()
+
+	a.breakers[name] = breaker
+}
+
+// outboundBreaker returns the breaker of the calls to host, registering it on first use.
+func (a *APIServer) outboundBreaker(host string) *CircuitBreaker {
+	a.breakersMu.Lock()
+	defer a.breakersMu.Unlock()
+
+	name := "outbound:" + host
+	breaker, ok := a.breakers[name]
+	if !ok {
+		breaker = NewCircuitBreaker(a.Config.OutboundBreakerThreshold, a.Config.OutboundBreakerOpenTimeout)
+		a.breakers[name] = breaker
+	}
+	return breaker
+}
+
+// circuitBreakerStates returns the state of every registered breaker, and whether any is open.
+func (a *APIServer) circuitBreakerStates() (map[string]string, bool) {
+	a.breakersMu.Lock()
+	defer a.breakersMu.Unlock()
+
+	if len(a.breakers) == 0 {
+		return nil, false
+	}
+
+	states := make(map[string]string, len(a.breakers))
+	anyOpen := false
+	for name, breaker := range a.breakers {
+		states[name] = breaker.State()
+		anyOpen = anyOpen || states[name] == CircuitOpen
+	}
+	return states, anyOpen
+}
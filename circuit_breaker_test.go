@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errDependency = errors.New("dependency failed")
+
+func TestOpenCircuitDegradesReadiness(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	a.warmedUp.Store(true)
+	breaker := NewCircuitBreaker(2, time.Minute)
+	a.RegisterCircuitBreaker("payments", breaker)
+
+	readiness := func() (int, GetReadinessResponse) {
+		rec := a.serve(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var resp GetReadinessResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, resp
+	}
+
+	if code, resp := readiness(); code != http.StatusOK || resp.CircuitBreakers["payments"] != CircuitClosed {
+		t.Fatalf("readiness = %d %+v, want 200 with a closed circuit", code, resp)
+	}
+
+	for range 2 {
+		breaker.Do(func() error { return errDependency })
+	}
+	if err := breaker.Do(func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Do on an open circuit = %v, want ErrCircuitOpen", err)
+	}
+
+	if code, resp := readiness(); code != http.StatusMultiStatus || resp.CircuitBreakers["payments"] != CircuitOpen {
+		t.Fatalf("readiness = %d %+v, want 207 with an open circuit", code, resp)
+	}
+}
+
+func TestCircuitBreakerPanickingTrialFailsIt(t *testing.T) {
+	breaker := NewCircuitBreaker(1, 0)
+	breaker.Do(func() error { return errDependency })
+
+	func() {
+		defer func() { recover() }()
+		breaker.Do(func() error { panic("trial call panicked") })
+	}()
+
+	// The circuit opened again on the panicking trial, and lets the next trial through
+	if err := breaker.Do(func() error { return nil }); err != nil {
+		t.Fatalf("Do after a panicking trial = %v, want the next trial to run", err)
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Fatalf("state = %s, want closed after a successful trial", state)
+	}
+}
+
+func TestOutboundClientGoesThroughBreaker(t *testing.T) {
+	t.Setenv("GSD_OUTBOUND_BREAKER_THRESHOLD", "2")
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+	a := newUnstartedTestServer(t)
+	client := a.OutboundClient(upstream.URL)
+
+	for range 2 {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("GET while closed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Fatalf("status = %d, want the upstream 502", resp.StatusCode)
+		}
+	}
+	if _, err := client.Get(upstream.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GET with the circuit open = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Fatalf("upstream called %d times, want 2", calls)
+	}
+	if states, anyOpen := a.circuitBreakerStates(); !anyOpen || len(states) != 1 {
+		t.Fatalf("breaker states = %v, want the outbound breaker open", states)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content codings CompressionMiddleware can answer with.
+const (
+	_encodingBrotli = "br"
+	_encodingGzip   = "gzip"
+)
+
+// CompressionMiddleware compresses responses with the coding the client prefers in
+// Accept-Encoding, honoring the q values. Brotli is only offered when brotliEnabled; ties go to
+// brotli, which compresses better. Responses the handler already encoded are left alone.
+func CompressionMiddleware(brotliEnabled bool) func(http.Handler) http.Handler {
+	offered := []string{_encodingGzip}
+	if brotliEnabled {
+		offered = []string{_encodingBrotli, _encodingGzip}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), offered)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the offered coding with the highest quality in the
+// Accept-Encoding header, or "" when the client accepts none of them.
+func negotiateEncoding(header string, offered []string) string {
+	accept := parseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, encoding := range offered {
+		if q := encodingQuality(accept, encoding); q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// encodingQuality returns the quality assigned to encoding, by name or through "*".
+func encodingQuality(accept []acceptEntry, encoding string) float64 {
+	q, matched := 0.0, false
+	for _, e := range accept {
+		switch e.value {
+		case encoding:
+			return e.q
+		case "*":
+			if !matched {
+				q, matched = e.q, true
+			}
+		}
+	}
+	return q
+}
+
+// compressWriter compresses the body on its way out. The encoder is only created by the
+// first write, so bodiless responses (204, 304, redirects) are sent untouched.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	enc         io.WriteCloser
+	wroteHeader bool
+	passthrough bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.passthrough = true
+	} else {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.enc == nil {
+		switch w.encoding {
+		case _encodingBrotli:
+			w.enc = brotli.NewWriter(w.ResponseWriter)
+		default:
+			w.enc = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+	return w.enc.Write(b)
+}
+
+// Flush sends what was compressed so far, for streaming responses. Flushing before any
+// write sends the headers, as the handler would get without the middleware.
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes the trailer of the compressed stream.
+func (w *compressWriter) Close() error {
+	if w.enc == nil {
+		return nil
+	}
+	return w.enc.Close()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
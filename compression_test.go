@@ -0,0 +1,89 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+const _compressedBody = `{"message":"hello, compressed world"}`
+
+func compressedHandler(brotliEnabled bool) http.Handler {
+	return CompressionMiddleware(brotliEnabled)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, _compressedBody)
+	}))
+}
+
+func TestCompressionNegotiatesBrotli(t *testing.T) {
+	tests := []struct {
+		name           string
+		brotliEnabled  bool
+		acceptEncoding string
+		want           string
+	}{
+		{"brotli preferred", true, "gzip;q=0.5, br", _encodingBrotli},
+		{"tie goes to brotli", true, "gzip, br", _encodingBrotli},
+		{"gzip preferred", true, "br;q=0.2, gzip;q=0.8", _encodingGzip},
+		{"brotli disabled", false, "br, gzip;q=0.1", _encodingGzip},
+		{"nothing accepted", true, "identity", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			compressedHandler(tt.brotliEnabled).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.want)
+			}
+
+			var body io.Reader = rec.Body
+			switch tt.want {
+			case _encodingBrotli:
+				body = brotli.NewReader(rec.Body)
+			case _encodingGzip:
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			}
+			decoded, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("decode %s body: %v", tt.want, err)
+			}
+			if string(decoded) != _compressedBody {
+				t.Fatalf("body = %q, want %q", decoded, _compressedBody)
+			}
+		})
+	}
+}
+
+func TestCompressionFlushBeforeWriteSendsHeaders(t *testing.T) {
+	handler := CompressionMiddleware(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		io.WriteString(w, _compressedBody)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// The headers as they were sent with the flush, not as the map ends up
+	sent := rec.Result().Header.Get("Content-Encoding")
+	if rec.Code != http.StatusOK || sent != _encodingBrotli {
+		t.Fatalf("status %d, Content-Encoding %q: want 200 and br", rec.Code, sent)
+	}
+	decoded, err := io.ReadAll(brotli.NewReader(rec.Body))
+	if err != nil || !strings.Contains(string(decoded), "compressed world") {
+		t.Fatalf("body = %q, %v", decoded, err)
+	}
+}
@@ -1,8 +1,521 @@
 package main
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Config is loaded from GSD_* environment variables. The port defaults to 8080 so local runs
+// need no setup. The OTLP endpoints are optional: when unset, the exporters honor the
+// standard OTEL_EXPORTER_OTLP_* variables instead.
 type Config struct {
 	Env             string `envconfig:"ENV"`
-	Port            int    `required:"true"`
-	TracingEndpoint string `required:"true" split_words:"true"`
-	MetricsEndpoint string `required:"true" split_words:"true"`
+	Port            int    `default:"8080"`
+	TracingEndpoint string `split_words:"true"`
+	MetricsEndpoint string `split_words:"true"`
+	LogsEndpoint    string `split_words:"true"`
+
+	// StartupTimeout bounds the blocking initialization steps, such as the OTel dials.
+	// Zero waits until a signal.
+	StartupTimeout time.Duration `split_words:"true" default:"30s"`
+
+	// TCPListenBacklog overrides the OS default backlog of the public listener (somaxconn on
+	// Linux), within the kernel cap. Only supported on unix systems.
+	TCPListenBacklog int `split_words:"true"`
+
+	// ReusePort binds the public listener with SO_REUSEPORT, so a new process can listen on the
+	// same port while the old one drains. Linux only, kernel 3.9 or later.
+	ReusePort bool `split_words:"true"`
+
+	// ReadTimeout and WriteTimeout are the server-wide timeouts (zero means none). The per-route
+	// maps, keyed by route pattern ("GET /stream:0s"), override them; zero disables the deadline.
+	ReadTimeout        time.Duration            `split_words:"true"`
+	WriteTimeout       time.Duration            `split_words:"true"`
+	RouteReadTimeouts  map[string]time.Duration `split_words:"true"`
+	RouteWriteTimeouts map[string]time.Duration `split_words:"true"`
+
+	// TLSCertFile and TLSKeyFile serve HTTPS (HTTP/2 included) on the public listener.
+	// TLSHandshakeTimeout bounds the handshake and the read of the request headers.
+	TLSCertFile         string        `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile          string        `envconfig:"TLS_KEY_FILE"`
+	TLSHandshakeTimeout time.Duration `envconfig:"TLS_HANDSHAKE_TIMEOUT" default:"10s"`
+	// TLSClientCAFile requires every client of the public listener to present a certificate
+	// signed by one of the CAs of this PEM bundle (mTLS). The /livez and /healthz probes are
+	// served without one, so the kubelet keeps probing the pod.
+	TLSClientCAFile string `envconfig:"TLS_CLIENT_CA_FILE"`
+
+	// HTTP2Cleartext serves HTTP/2 without TLS (h2c) next to HTTP/1.1.
+	HTTP2Cleartext bool `envconfig:"HTTP2_CLEARTEXT"`
+
+	// WarmupRequests are sent to the server itself before the readiness probe succeeds,
+	// as a JSON array of {"method", "path", "body"} objects.
+	WarmupRequests WarmupRequests `split_words:"true"`
+
+	// ErrorLogSampleRate is the share of 500 responses whose error is logged, from 0 to 1.
+	// Every error is logged in development.
+	ErrorLogSampleRate float64 `split_words:"true" default:"1.0"`
+
+	// StatusPageTemplate is the path of an html/template overriding the embedded status page
+	// rendered for browsers during drain and maintenance.
+	StatusPageTemplate string `split_words:"true"`
+
+	// MaxShutdownRetries is how many times a shutdown hook failing with a RetryableError is retried.
+	MaxShutdownRetries int `split_words:"true" default:"3"`
+	// ShutdownHookTimeout bounds each drain and shutdown hook, retries included. A hook still
+	// running past it is left behind and reported as timed out; zero bounds it by its phase only.
+	ShutdownHookTimeout time.Duration `split_words:"true" default:"5s"`
+
+	// AdminPort enables the admin listener when set. AdminToken is the bearer token it requires.
+	AdminPort  int    `split_words:"true"`
+	AdminToken string `split_words:"true"`
+
+	// DeregisterWebhookURL is called during the drain phase to remove the instance from an external load balancer.
+	DeregisterWebhookURL string `split_words:"true"`
+
+	// AlertmanagerURL is the Alertmanager notified with a PodShuttingDown alert when the drain starts.
+	AlertmanagerURL string `split_words:"true"`
+
+	// ServiceDiscovery resolves the host of the Alertmanager and deregistration webhook URLs
+	// through DNS on every new connection, in round-robin over its addresses.
+	ServiceDiscovery bool `split_words:"true"`
+
+	// CrossRegionEndpoints are the health endpoints of the other regions. Readiness reports
+	// a warning when more than half of them can't be reached from here.
+	CrossRegionEndpoints []string `split_words:"true"`
+
+	// WatchdogHeartbeat enables the watchdog: when no request completes for WatchdogMaxMissed
+	// heartbeats while some are in flight, the goroutine stacks are logged and the process
+	// exits. An idle server is left alone. It only watches with the logging middleware.
+	WatchdogHeartbeat time.Duration `split_words:"true"`
+	WatchdogMaxMissed int           `split_words:"true" default:"3"`
+
+	// LivenessStaleThreshold is how old the heartbeat may get before the liveness probe fails.
+	LivenessStaleThreshold time.Duration `split_words:"true" default:"10s"`
+
+	// ShutdownDryRun simulates the shutdown plan on signal without closing anything, logging
+	// each phase against its budget, to check the timings of a configuration in staging.
+	// A second signal, or ShutdownDryRunTimeout (zero waits forever), then shuts down for real.
+	ShutdownDryRun        bool          `envconfig:"SHUTDOWN_DRYRUN"`
+	ShutdownDryRunTimeout time.Duration `envconfig:"SHUTDOWN_DRYRUN_TIMEOUT" default:"10m"`
+
+	// ShutdownGoroutineThreshold is how many goroutines may still run once the shutdown is
+	// done before a leak is logged (zero disables the check). ShutdownStrict fails the shutdown
+	// above it, and exits with status 1 whenever the shutdown didn't succeed.
+	ShutdownGoroutineThreshold int  `split_words:"true"`
+	ShutdownStrict             bool `split_words:"true"`
+
+	// PoolMonitorInterval is how often the queue of the registered worker pools is sampled
+	// for its high watermark; zero disables the monitoring.
+	PoolMonitorInterval time.Duration `split_words:"true" default:"10s"`
+
+	// ProcessGracePeriod is how long the subprocesses given to RegisterProcess have to exit
+	// after SIGTERM before they're killed.
+	ProcessGracePeriod time.Duration `split_words:"true" default:"5s"`
+
+	// DrainStrategy is the built-in drain strategy: fixed, probe, connections or scrape.
+	DrainStrategy string `split_words:"true" default:"fixed"`
+
+	// JSONMaxBytes, JSONMaxDepth and JSONMaxTokens bound the request bodies decoded by
+	// DecodeJSON: their size, their nesting, and the number of keys and values.
+	JSONMaxBytes  int64 `envconfig:"JSON_MAX_BYTES" default:"1048576"`
+	JSONMaxDepth  int   `envconfig:"JSON_MAX_DEPTH" default:"32"`
+	JSONMaxTokens int   `envconfig:"JSON_MAX_TOKENS" default:"10000"`
+
+	// HealthCheckCacheTTL is how long a health check result is reused by the readiness probe.
+	HealthCheckCacheTTL time.Duration `split_words:"true" default:"1s"`
+	// HealthChecksReadyAtStartup names the health checks whose failures are ignored until
+	// they first pass (see ReadyUntilFirstSuccess). The others keep the pod not ready.
+	HealthChecksReadyAtStartup []string `split_words:"true"`
+
+	// ReadinessDebounce is how long health checks have to keep failing before the readiness
+	// probe reports not ready. The shutdown flip is never delayed.
+	ReadinessDebounce time.Duration `split_words:"true"`
+	// ReadinessRecoverySuccesses is how many consecutive healthy checks it takes for a probe
+	// that failed on a health check to report ready again.
+	ReadinessRecoverySuccesses int `split_words:"true" default:"1"`
+	// ProbeUserAgents are the User-Agent prefixes of the probes answered by the readiness
+	// endpoint with an empty body, the status alone; other clients get the JSON details.
+	ProbeUserAgents []string `split_words:"true" default:"kube-probe/"`
+
+	// SlowRouteReporting logs the SlowRouteCount routes with the highest average latency every
+	// SlowRouteInterval. Latencies are observed by the logging middleware.
+	SlowRouteReporting bool          `split_words:"true"`
+	SlowRouteCount     int           `split_words:"true" default:"5"`
+	SlowRouteInterval  time.Duration `split_words:"true" default:"1m"`
+
+	// HealthHistorySize is the number of results kept per health check for /admin/health-history.
+	HealthHistorySize int `split_words:"true" default:"10"`
+
+	// InFlightRegistrySize is the number of requests in flight listed by /admin/in-flight;
+	// the others are only counted.
+	InFlightRegistrySize int `split_words:"true" default:"1000"`
+
+	// EventBufferSize is the number of lifecycle events kept in memory for /admin/events.
+	EventBufferSize int `split_words:"true" default:"256"`
+	// TerminationMessagePath is where the events are dumped on exit, e.g. /dev/termination-log.
+	TerminationMessagePath string `split_words:"true"`
+
+	// MaxResponseBodyBytes closes the connection of responses whose body grows past it.
+	// Zero means no limit.
+	MaxResponseBodyBytes int64 `split_words:"true"`
+
+	// BrotliEnabled lets the compression middleware answer with brotli when the client
+	// prefers it; gzip is always offered.
+	BrotliEnabled bool `split_words:"true"`
+
+	// LogLevel is the minimum level logged. LogFormat is json or console.
+	LogLevel  string `split_words:"true" default:"info"`
+	LogFormat string `split_words:"true" default:"json"`
+	// LogFilePath writes the logs to a file instead of stderr. LogBufferSize buffers the
+	// writes up to that many bytes, flushed every 30 seconds and at shutdown; zero writes
+	// every entry through.
+	LogFilePath   string `split_words:"true"`
+	LogBufferSize int    `split_words:"true"`
+	// LogSampling drops the repetitions of a message beyond 100 per second.
+	LogSampling bool `split_words:"true" default:"true"`
+	// EnablePIIRedaction masks the sensitive fields (password, token, email...) and hashes
+	// the email addresses of the log entries.
+	EnablePIIRedaction bool `envconfig:"ENABLE_PII_REDACTION"`
+	// LogTimeFormat is the encoding of the log timestamps: iso8601, rfc3339 or epoch.
+	LogTimeFormat string `split_words:"true" default:"iso8601"`
+	// LogCaller adds the caller to the log entries. LogStacktraceLevel is the level from which
+	// entries carry a stacktrace (debug to fatal, or none); by default error in production
+	// and the zap default elsewhere.
+	LogCaller          bool   `split_words:"true" default:"true"`
+	LogStacktraceLevel string `split_words:"true"`
+	// LogLevels overrides the level of named loggers, e.g. "access:warn,recovery:debug".
+	// The middlewares log through loggers named after them.
+	LogLevels map[string]string `split_words:"true"`
+
+	// AuthTokens are the bearer tokens accepted by the auth middleware, mapped to the subject
+	// they authenticate ("token:subject"), unless an Authenticator is injected.
+	AuthTokens map[string]string `split_words:"true"`
+
+	// RateLimitRPS and RateLimitBurst configure the rate_limit middleware, per subject or client
+	// IP. With DistributedRateLimit the limit is shared by every pod through Redis at RedisAddr,
+	// or through the injected Redis client.
+	RateLimitRPS         float64 `split_words:"true" default:"10"`
+	RateLimitBurst       int     `split_words:"true" default:"20"`
+	DistributedRateLimit bool    `split_words:"true"`
+	RedisAddr            string  `split_words:"true"`
+
+	// RetryMaxRetries, RetryBaseDelay and RetryMaxDelay configure the retry middleware, which
+	// retries the idempotent requests failing with 502, 503 or 504 on a transient upstream
+	// failure, see MarkUpstreamFailure, with a jittered exponential backoff. Request bodies
+	// above RetryMaxBodyBytes aren't retried, nor are responses above RetryMaxResponseBytes,
+	// streamed instead of buffered.
+	RetryMaxRetries       int           `split_words:"true" default:"2"`
+	RetryBaseDelay        time.Duration `split_words:"true" default:"100ms"`
+	RetryMaxDelay         time.Duration `split_words:"true" default:"2s"`
+	RetryMaxBodyBytes     int64         `split_words:"true" default:"65536"`
+	RetryMaxResponseBytes int64         `split_words:"true" default:"1048576"`
+
+	// AccessLogFollowsSampling logs the requests whose trace isn't sampled at debug, so at the
+	// info level the access log only has the requests with a trace.
+	AccessLogFollowsSampling bool `split_words:"true"`
+	// RejectExpiredRequests has the logging middleware answer 503 to the requests whose
+	// deadline is already past, or less than 50ms away, instead of serving them. Requests
+	// carry their deadline in RequestTimeoutHeader, as the milliseconds the client still
+	// waits for the response.
+	RejectExpiredRequests bool   `split_words:"true"`
+	RequestTimeoutHeader  string `split_words:"true" default:"X-Request-Timeout-Ms"`
+
+	// Middleware lists the middlewares wrapping every route, outermost first.
+	Middleware []string `default:"recovery,logging,metrics"`
+	// CORSAllowedOrigins are the origins allowed by the cors middleware ("*" allows any).
+	CORSAllowedOrigins []string `split_words:"true"`
+
+	// MaxHeaderCount and MaxHeaderValueBytes are enforced by the header_limits middleware.
+	MaxHeaderCount      int `split_words:"true" default:"100"`
+	MaxHeaderValueBytes int `split_words:"true" default:"8192"`
+
+	// TrailingSlash is the canonical form enforced by the trailing_slash middleware: strip or
+	// append. TrailingSlashRedirect redirects to it instead of rewriting the path.
+	TrailingSlash         string `split_words:"true" default:"strip"`
+	TrailingSlashRedirect bool   `split_words:"true" default:"true"`
+
+	// Tenant extraction: the header carrying the tenant ID, whether the first label of the host
+	// is used when the header is missing, and the tenants labeled by name on metrics (the
+	// others are hashed into a bounded number of buckets, or labeled "other" with an allowlist).
+	TenantHeader        string   `split_words:"true" default:"X-Tenant-ID"`
+	TenantFromSubdomain bool     `split_words:"true"`
+	TenantAllowlist     []string `split_words:"true"`
+
+	// The calls of the OutboundClient open the circuit of their host after
+	// OutboundBreakerThreshold consecutive failures, for OutboundBreakerOpenTimeout.
+	OutboundBreakerThreshold   int           `split_words:"true" default:"5"`
+	OutboundBreakerOpenTimeout time.Duration `split_words:"true" default:"30s"`
+
+	// Chaos faults are injected only when ChaosEnabled is set outside production; see ChaosFault
+	// for their target:name:action[:arg] syntax, e.g. "hook:cache:hang:30s".
+	ChaosEnabled bool     `split_words:"true"`
+	ChaosFaults  []string `split_words:"true"`
+
+	// Telemetry: paths served without otelhttp instrumentation, whether the service is a public
+	// endpoint (incoming trace contexts become links), the span name format (operation, method or
+	// method_path) and the request/response body events, which are never enabled in production.
+	OTelDisabledPaths  []string `envconfig:"OTEL_DISABLED_PATHS"`
+	OTelPublicEndpoint bool     `envconfig:"OTEL_PUBLIC_ENDPOINT"`
+	OTelSpanNameFormat string   `envconfig:"OTEL_SPAN_NAME_FORMAT" default:"operation"`
+	OTelMessageEvents  bool     `envconfig:"OTEL_MESSAGE_EVENTS"`
+	// OTelGlobalPolicy is applied when global OTel providers are already installed:
+	// overwrite, reuse or error. local never installs the globals.
+	OTelGlobalPolicy string `envconfig:"OTEL_GLOBAL_POLICY" default:"overwrite"`
+	// PrometheusEnabled serves the metrics on /metrics of the public listener, in addition
+	// to the OTLP export.
+	PrometheusEnabled bool `split_words:"true"`
+	// OTelLogsEnabled exports the logs over OTLP too, in batches of up to OTelLogBatchSize
+	// records sent at least every OTelLogFlushInterval.
+	OTelLogsEnabled      bool          `envconfig:"OTEL_LOGS_ENABLED"`
+	OTelLogBatchSize     int           `envconfig:"OTEL_LOG_BATCH_SIZE" default:"512"`
+	OTelLogFlushInterval time.Duration `envconfig:"OTEL_LOG_FLUSH_INTERVAL" default:"1s"`
+	// OTelQueueWarningThreshold is the span queue depth past which the readiness probe warns.
+	OTelQueueWarningThreshold int `envconfig:"OTEL_QUEUE_WARNING_THRESHOLD" default:"1000"`
+}
+
+// IsProduction reports whether the service runs in the production environment.
+func (c Config) IsProduction() bool {
+	return c.Env == "prod" || c.Env == "production"
+}
+
+// ConfigFieldError describes one invalid setting and the value that was rejected.
+type ConfigFieldError struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (e ConfigFieldError) String() string {
+	return fmt.Sprintf("%s=%q: %s", e.Field, e.Value, e.Reason)
+}
+
+// ConfigValidationError is returned by Config.Validate with every invalid setting.
+type ConfigValidationError struct {
+	Fields []ConfigFieldError `json:"fields"`
+}
+
+func (e *ConfigValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.String()
+	}
+	return fmt.Sprintf("invalid configuration (%d errors): %s", len(e.Fields), strings.Join(msgs, "; "))
+}
+
+func (e *ConfigValidationError) add(field, value, reason string) {
+	e.Fields = append(e.Fields, ConfigFieldError{Field: field, Value: value, Reason: reason})
+}
+
+// IsDevelopment reports whether the service runs in the development environment.
+func (c Config) IsDevelopment() bool {
+	return c.Env == "dev" || c.Env == "development"
+}
+
+// Validate reports every invalid setting at once as a *ConfigValidationError.
+func (c Config) Validate() error {
+	verr := &ConfigValidationError{}
+
+	for i, name := range c.Middleware {
+		if _, ok := _middlewares[name]; !ok {
+			verr.add("Middleware", name, "unknown middleware")
+		}
+		if slices.Contains(c.Middleware[:i], name) {
+			verr.add("Middleware", name, "middleware is listed more than once")
+		}
+	}
+
+	if _, err := NewDrainStrategy(c.DrainStrategy); err != nil {
+		verr.add("DrainStrategy", c.DrainStrategy, "unknown drain strategy")
+	}
+	if c.DrainStrategy == DrainScrape && !c.PrometheusEnabled {
+		verr.add("DrainStrategy", c.DrainStrategy, "requires PrometheusEnabled")
+	}
+
+	if c.ErrorLogSampleRate < 0 || c.ErrorLogSampleRate > 1 {
+		verr.add("ErrorLogSampleRate", strconv.FormatFloat(c.ErrorLogSampleRate, 'g', -1, 64), "must be between 0 and 1")
+	}
+
+	if c.SlowRouteReporting && c.SlowRouteInterval <= 0 {
+		verr.add("SlowRouteInterval", c.SlowRouteInterval.String(), "must be positive")
+	}
+
+	switch c.TrailingSlash {
+	case TrailingSlashStrip, TrailingSlashAppend:
+	default:
+		verr.add("TrailingSlash", c.TrailingSlash, "unknown trailing slash policy")
+	}
+
+	for _, fault := range c.ChaosFaults {
+		if _, err := ParseChaosFault(fault); err != nil {
+			verr.add("ChaosFaults", fault, err.Error())
+		}
+	}
+
+	switch c.OTelSpanNameFormat {
+	case "operation", "method", "method_path":
+	default:
+		verr.add("OTelSpanNameFormat", c.OTelSpanNameFormat, "unknown span name format")
+	}
+
+	switch c.OTelGlobalPolicy {
+	case OTelGlobalOverwrite, OTelGlobalReuse, OTelGlobalError, OTelGlobalLocal:
+	default:
+		verr.add("OTelGlobalPolicy", c.OTelGlobalPolicy, "unknown OTel global policy")
+	}
+
+	if c.AdminPort > 0 && c.AdminToken == "" {
+		verr.add("AdminToken", "", "required when the admin listener is enabled")
+	}
+
+	if c.OTelLogsEnabled {
+		if c.OTelLogBatchSize <= 0 {
+			verr.add("OTelLogBatchSize", strconv.Itoa(c.OTelLogBatchSize), "must be positive")
+		}
+		if c.OTelLogFlushInterval <= 0 {
+			verr.add("OTelLogFlushInterval", c.OTelLogFlushInterval.String(), "must be positive")
+		}
+	}
+
+	if _, ok := _logTimeEncoders[c.LogTimeFormat]; !ok {
+		verr.add("LogTimeFormat", c.LogTimeFormat, "unknown log time format")
+	}
+
+	if lvl := c.LogStacktraceLevel; lvl != "" && lvl != _logStacktraceNone {
+		if _, err := zapcore.ParseLevel(lvl); err != nil {
+			verr.add("LogStacktraceLevel", lvl, "unknown log level")
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		verr.add("TLSKeyFile", c.TLSKeyFile, "TLSCertFile and TLSKeyFile must be set together")
+	}
+	if c.tlsEnabled() && c.TLSHandshakeTimeout <= 0 {
+		verr.add("TLSHandshakeTimeout", c.TLSHandshakeTimeout.String(), "must be positive")
+	}
+	if c.TLSClientCAFile != "" && !c.tlsEnabled() {
+		verr.add("TLSClientCAFile", c.TLSClientCAFile, "requires TLSCertFile and TLSKeyFile")
+	}
+
+	if slices.Contains(c.Middleware, "rate_limit") {
+		if c.RateLimitRPS <= 0 {
+			verr.add("RateLimitRPS", strconv.FormatFloat(c.RateLimitRPS, 'g', -1, 64), "must be positive")
+		}
+		if c.RateLimitBurst <= 0 {
+			verr.add("RateLimitBurst", strconv.Itoa(c.RateLimitBurst), "must be positive")
+		}
+	}
+
+	if c.WatchdogHeartbeat > 0 {
+		if c.WatchdogMaxMissed <= 0 {
+			verr.add("WatchdogMaxMissed", strconv.Itoa(c.WatchdogMaxMissed), "must be positive")
+		}
+		if !slices.Contains(c.Middleware, "logging") {
+			verr.add("WatchdogHeartbeat", c.WatchdogHeartbeat.String(), "requires the logging middleware")
+		}
+	}
+
+	if c.ReadinessRecoverySuccesses <= 0 {
+		verr.add("ReadinessRecoverySuccesses", strconv.Itoa(c.ReadinessRecoverySuccesses), "must be positive")
+	}
+	if c.JSONMaxBytes <= 0 {
+		verr.add("JSONMaxBytes", strconv.FormatInt(c.JSONMaxBytes, 10), "must be positive")
+	}
+	if c.JSONMaxDepth <= 0 {
+		verr.add("JSONMaxDepth", strconv.Itoa(c.JSONMaxDepth), "must be positive")
+	}
+	if c.JSONMaxTokens <= 0 {
+		verr.add("JSONMaxTokens", strconv.Itoa(c.JSONMaxTokens), "must be positive")
+	}
+	if c.RejectExpiredRequests && !slices.Contains(c.Middleware, "logging") {
+		verr.add("RejectExpiredRequests", "true", "requires the logging middleware")
+	}
+	if slices.Contains(c.Middleware, "retry") {
+		if c.RetryMaxRetries < 0 {
+			verr.add("RetryMaxRetries", strconv.Itoa(c.RetryMaxRetries), "must not be negative")
+		}
+		if c.RetryBaseDelay < 0 || c.RetryMaxDelay < c.RetryBaseDelay {
+			verr.add("RetryMaxDelay", c.RetryMaxDelay.String(), "must be at least RetryBaseDelay, itself not negative")
+		}
+		if c.RetryMaxBodyBytes < 0 {
+			verr.add("RetryMaxBodyBytes", strconv.FormatInt(c.RetryMaxBodyBytes, 10), "must not be negative")
+		}
+	}
+	if c.ProcessGracePeriod < 0 {
+		verr.add("ProcessGracePeriod", c.ProcessGracePeriod.String(), "must not be negative")
+	}
+
+	if _, err := zapcore.ParseLevel(c.LogLevel); c.LogLevel != "" && err != nil {
+		verr.add("LogLevel", c.LogLevel, "unknown log level")
+	}
+	if c.LogFormat != "" && c.LogFormat != LogFormatJSON && c.LogFormat != LogFormatConsole {
+		verr.add("LogFormat", c.LogFormat, "must be json or console")
+	}
+	if c.LogBufferSize < 0 {
+		verr.add("LogBufferSize", strconv.Itoa(c.LogBufferSize), "must not be negative")
+	}
+
+	for name, lvl := range c.LogLevels {
+		if _, err := zapcore.ParseLevel(lvl); err != nil {
+			verr.add("LogLevels", name+":"+lvl, "unknown log level")
+		}
+	}
+
+	for _, endpoint := range c.CrossRegionEndpoints {
+		if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			verr.add("CrossRegionEndpoints", endpoint, "must be an absolute URL")
+		}
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// ValidateRequiredEnvVars checks that every variable in vars is set to a non-empty value.
+// The error joins one error per missing variable, so all of them are reported at once.
+func ValidateRequiredEnvVars(vars []string) error {
+	var errs []error
+	for _, name := range vars {
+		if os.Getenv(name) == "" {
+			errs = append(errs, fmt.Errorf("missing required environment variable %s", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// _requiredEnvVars are the variables a setting needs once its own variable is set: every
+// setting has a default or is optional on its own.
+var _requiredEnvVars = []struct {
+	set      string
+	requires []string
+}{
+	{"GSD_TLS_CERT_FILE", []string{"GSD_TLS_KEY_FILE"}},
+	{"GSD_TLS_KEY_FILE", []string{"GSD_TLS_CERT_FILE"}},
+	{"GSD_TLS_CLIENT_CA_FILE", []string{"GSD_TLS_CERT_FILE", "GSD_TLS_KEY_FILE"}},
+	{"GSD_ADMIN_PORT", []string{"GSD_ADMIN_TOKEN"}},
+}
+
+// requiredEnvVars returns the variables required by the settings of the environment.
+func requiredEnvVars() []string {
+	var vars []string
+	for _, r := range _requiredEnvVars {
+		if os.Getenv(r.set) == "" {
+			continue
+		}
+		for _, name := range r.requires {
+			if !slices.Contains(vars, name) {
+				vars = append(vars, name)
+			}
+		}
+	}
+	return vars
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// defaultConfig returns the configuration loaded from an empty environment.
+func defaultConfig(t *testing.T) Config {
+	t.Helper()
+	var c Config
+	if err := envconfig.Process("gsd", &c); err != nil {
+		t.Fatalf("load default config: %v", err)
+	}
+	return c
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	if err := defaultConfig(t).Validate(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+}
+
+func TestConfigValidationReportsEveryField(t *testing.T) {
+	c := defaultConfig(t)
+	c.DrainStrategy = "mesh"
+	c.ErrorLogSampleRate = 2
+	c.TrailingSlash = "keep"
+
+	err := fmt.Errorf("load config: %w", c.Validate())
+
+	var verr *ConfigValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate error %v is not a *ConfigValidationError", err)
+	}
+	want := map[string]string{
+		"DrainStrategy":      "mesh",
+		"ErrorLogSampleRate": "2",
+		"TrailingSlash":      "keep",
+	}
+	if len(verr.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want one per invalid setting", verr.Fields)
+	}
+	for _, f := range verr.Fields {
+		if value, ok := want[f.Field]; !ok || f.Value != value || f.Reason == "" {
+			t.Errorf("unexpected field error %+v", f)
+		}
+	}
+}
+
+func TestPortDefaultsAndOverride(t *testing.T) {
+	t.Setenv("GSD_PORT", "")
+	os.Unsetenv("GSD_PORT")
+	if port := defaultConfig(t).Port; port != 8080 {
+		t.Fatalf("port without GSD_PORT = %d, want 8080", port)
+	}
+
+	t.Setenv("GSD_PORT", "9090")
+	if port := defaultConfig(t).Port; port != 9090 {
+		t.Fatalf("port with GSD_PORT=9090 = %d, want 9090", port)
+	}
+}
+
+func TestValidateRequiredEnvVarsReportsEveryMissingVar(t *testing.T) {
+	t.Setenv("GSD_TLS_CERT_FILE", "/etc/tls/tls.crt")
+	t.Setenv("GSD_TLS_CLIENT_CA_FILE", "/etc/tls/ca.crt")
+	t.Setenv("GSD_ADMIN_PORT", "9090")
+	for _, name := range []string{"GSD_TLS_KEY_FILE", "GSD_ADMIN_TOKEN"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	required := requiredEnvVars()
+	if want := []string{"GSD_TLS_KEY_FILE", "GSD_TLS_CERT_FILE", "GSD_ADMIN_TOKEN"}; !slices.Equal(required, want) {
+		t.Fatalf("required env vars = %v, want %v", required, want)
+	}
+
+	_, err := NewAPIServer()
+	if err == nil {
+		t.Fatal("NewAPIServer succeeded with required variables missing")
+	}
+	for _, name := range []string{"GSD_TLS_KEY_FILE", "GSD_ADMIN_TOKEN"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q doesn't list %s", err, name)
+		}
+	}
+	if strings.Contains(err.Error(), "GSD_TLS_CERT_FILE") {
+		t.Errorf("error %q lists the set GSD_TLS_CERT_FILE", err)
+	}
+
+	t.Setenv("GSD_TLS_KEY_FILE", "/etc/tls/tls.key")
+	t.Setenv("GSD_ADMIN_TOKEN", "secret")
+	if err := ValidateRequiredEnvVars(requiredEnvVars()); err != nil {
+		t.Fatalf("ValidateRequiredEnvVars with every variable set: %v", err)
+	}
+}
+
+func TestNoEnvVarRequiredByDefault(t *testing.T) {
+	for _, r := range _requiredEnvVars {
+		t.Setenv(r.set, "")
+	}
+	if required := requiredEnvVars(); len(required) != 0 {
+		t.Fatalf("required env vars = %v, want none without any of their settings", required)
+	}
+}
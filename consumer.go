@@ -0,0 +1,37 @@
+package main
+
+import "context"
+
+// Consumer is an asynchronous ingress, such as a Kafka or NATS subscription, shut down with
+// the same care as the HTTP server.
+type Consumer interface {
+	// Stop stops fetching new messages, waits for the messages being processed to complete,
+	// commits their offsets and closes the consumer. When ctx ends first, it should commit
+	// what completed and return ctx's error; the rest will be redelivered.
+	Stop(ctx context.Context) error
+}
+
+// QuiescingConsumer is a Consumer that can stop fetching right away, without waiting for the
+// messages in flight.
+type QuiescingConsumer interface {
+	Consumer
+	Quiesce()
+}
+
+// RegisterConsumer stops c when the drain starts, within the drain budget. The ingress is
+// quiesced before any work is drained: InitiateShutdown flips the readiness and quiesces the
+// QuiescingConsumers, then the drain hooks wait for the in-flight messages while the HTTP
+// requests keep completing.
+func (a *APIServer) RegisterConsumer(name string, c Consumer) {
+	if q, ok := c.(QuiescingConsumer); ok {
+		a.quiescers = append(a.quiescers, q)
+	}
+	a.OnDrain(name, c.Stop)
+}
+
+// quiesceIngress stops every consumer from fetching new messages.
+func (a *APIServer) quiesceIngress() {
+	for _, q := range a.quiescers {
+		q.Quiesce()
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsumer processes the messages of its queue one at a time, each taking delay, and
+// commits them once processed.
+type fakeConsumer struct {
+	queue chan string
+	delay time.Duration
+
+	mu        sync.Mutex
+	started   []string
+	committed []string
+	quiesced  bool
+
+	stop chan struct{}
+	once sync.Once
+	done chan struct{}
+}
+
+func newFakeConsumer(delay time.Duration) *fakeConsumer {
+	c := &fakeConsumer{
+		queue: make(chan string, 10),
+		delay: delay,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+func (c *fakeConsumer) run() {
+	defer close(c.done)
+	for {
+		// Once stopped, nothing more is fetched, even with messages queued
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		select {
+		case <-c.stop:
+			return
+		case msg := <-c.queue:
+			c.mu.Lock()
+			c.started = append(c.started, msg)
+			c.mu.Unlock()
+
+			time.Sleep(c.delay) // processing ignores the shutdown, it completes
+
+			c.mu.Lock()
+			c.committed = append(c.committed, msg)
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (c *fakeConsumer) Quiesce() {
+	c.mu.Lock()
+	c.quiesced = true
+	c.mu.Unlock()
+	c.once.Do(func() { close(c.stop) })
+}
+
+func (c *fakeConsumer) Stop(ctx context.Context) error {
+	c.once.Do(func() { close(c.stop) })
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// state returns the messages started and committed so far.
+func (c *fakeConsumer) state() (started, committed []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.started), slices.Clone(c.committed)
+}
+
+// onlyStop hides Quiesce, for a Consumer stopped by its drain hook only.
+type onlyStop struct{ Consumer }
+
+func TestConsumerFinishesInFlightMessagesAndStops(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	c := newFakeConsumer(100 * time.Millisecond)
+	a.RegisterConsumer("orders", onlyStop{c})
+
+	c.queue <- "m1"
+	for started, _ := c.state(); len(started) == 0; started, _ = c.state() {
+		time.Sleep(time.Millisecond)
+	}
+	c.queue <- "m2" // fetched only if the consumer keeps consuming
+
+	a.InitiateShutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := a.RunDrainHooks(ctx); err != nil {
+		t.Fatalf("RunDrainHooks: %v", err)
+	}
+
+	started, committed := c.state()
+	if !slices.Equal(committed, []string{"m1"}) {
+		t.Fatalf("committed = %v, want the in-flight message m1", committed)
+	}
+	if !slices.Equal(started, []string{"m1"}) {
+		t.Fatalf("started = %v, want the consumption stopped before m2", started)
+	}
+}
+
+func TestIngressQuiescedBeforeDraining(t *testing.T) {
+	a := NewTestAPIServer(t)
+	c := newFakeConsumer(0)
+
+	// Registered first, this drain hook runs before the consumer is asked to drain
+	var readiness int
+	var quiesced, fetching bool
+	a.OnDrain("http", func(context.Context) error {
+		readiness = getStatus(t, a.URL+"/healthz")
+		c.mu.Lock()
+		quiesced = c.quiesced
+		c.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-time.After(time.Second):
+			fetching = true
+		}
+		return nil
+	})
+	a.RegisterConsumer("orders", c)
+
+	a.InitiateShutdown()
+	if err := a.RunDrainHooks(context.Background()); err != nil {
+		t.Fatalf("RunDrainHooks: %v", err)
+	}
+
+	if readiness != http.StatusServiceUnavailable {
+		t.Errorf("readiness when the drain started = %d, want 503: HTTP ingress still open", readiness)
+	}
+	if !quiesced || fetching {
+		t.Errorf("consumer quiesced %v, still fetching %v when the drain started: want it stopped", quiesced, fetching)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+)
+
+// RequireJSON rejects requests to the route whose body isn't declared as application/json.
+func RequireJSON() RouteOption {
+	return func(r *Route) {
+		r.RequireJSON = true
+	}
+}
+
+// JSONContentTypeMiddleware answers 415 to writes without "Content-Type: application/json",
+// before the handler gets to decode the body. Reads, and deletes without a body, are let through.
+func JSONContentTypeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		case http.MethodDelete:
+			if r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			WriteJSON(w, http.StatusUnsupportedMediaType, APIError{
+				Code:    http.StatusUnsupportedMediaType,
+				Message: "the request body must be application/json",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
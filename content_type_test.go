@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireJSONRoute(t *testing.T) {
+	a := newUnstartedTestServer(t, func(a *APIServer) {
+		a.Handle("POST /items", func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusCreated)
+			return nil
+		}, RequireJSON())
+		a.Handle("GET /items", func(w http.ResponseWriter, r *http.Request) error { return nil }, RequireJSON())
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		want        int
+	}{
+		{"json", http.MethodPost, "application/json", http.StatusCreated},
+		{"json with charset", http.MethodPost, "application/json; charset=utf-8", http.StatusCreated},
+		{"form", http.MethodPost, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"missing", http.MethodPost, "", http.StatusUnsupportedMediaType},
+		{"get skipped", http.MethodGet, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/items", strings.NewReader(`{"name":"bolt"}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if rec := a.serve(req); rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
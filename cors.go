@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+const (
+	_corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	_corsAllowedHeaders = "Authorization, Content-Type, X-Request-ID"
+)
+
+// CORSMiddleware allows cross-origin requests from allowedOrigins ("*" allows any origin)
+// and answers preflight requests itself.
+func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowAny := slices.Contains(allowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || (!allowAny && !slices.Contains(allowedOrigins, origin)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", _corsAllowedMethods)
+				w.Header().Set("Access-Control-Allow-Headers", _corsAllowedHeaders)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// _maxRegionPingBody is how much of a region's health response is read to reuse the connection.
+const _maxRegionPingBody = 64 << 10
+
+// CrossRegionHealthCheck returns a health check calling the health endpoints of the other
+// regions concurrently, each with the client returned by clientFor. It fails when more than
+// half of them are unreachable, so the region reports degraded before inter-region routing
+// problems cascade.
+func CrossRegionHealthCheck(endpoints []string, clientFor func(endpoint string) *http.Client) HealthCheck {
+	clients := make([]*http.Client, len(endpoints))
+	for i, endpoint := range endpoints {
+		clients[i] = clientFor(endpoint)
+	}
+
+	return func(ctx context.Context) error {
+		errs := make([]error, len(endpoints))
+
+		var wg sync.WaitGroup
+		for i, endpoint := range endpoints {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = pingRegion(ctx, clients[i], endpoint)
+			}()
+		}
+		wg.Wait()
+
+		var unreachable []string
+		for i, err := range errs {
+			if err != nil {
+				unreachable = append(unreachable, fmt.Sprintf("%s: %v", endpoints[i], err))
+			}
+		}
+		if len(unreachable)*2 > len(endpoints) {
+			return fmt.Errorf("%d of %d regions unreachable: %s",
+				len(unreachable), len(endpoints), strings.Join(unreachable, "; "))
+		}
+		return nil
+	}
+}
+
+func pingRegion(ctx context.Context, client *http.Client, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Drained, the keep-alive connection is reused by the next check instead of redialed
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, _maxRegionPingBody))
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// regionServer serves the health endpoint of another region, answering with status.
+func regionServer(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCrossRegionIsAReadinessWarning(t *testing.T) {
+	healthy := regionServer(t, http.StatusOK)
+	down := []*httptest.Server{regionServer(t, http.StatusServiceUnavailable), regionServer(t, http.StatusBadGateway)}
+
+	t.Setenv("GSD_CROSS_REGION_ENDPOINTS", strings.Join([]string{healthy.URL, down[0].URL, down[1].URL}, ","))
+	a := newUnstartedTestServer(t)
+	a.warmedUp.Store(true)
+
+	rec := a.serve(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("readiness = %d, want 200: other regions being down must not fail it", rec.Code)
+	}
+	var resp GetReadinessResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	warning := resp.Warnings["cross_region"]
+	if !strings.Contains(warning, "2 of 3 regions unreachable") {
+		t.Fatalf("cross_region warning = %q, want 2 of 3 regions unreachable", warning)
+	}
+	for _, srv := range down {
+		if !strings.Contains(warning, srv.URL) {
+			t.Errorf("warning %q does not name %s", warning, srv.URL)
+		}
+	}
+
+	// The regions are called through the outbound clients and their circuit breakers
+	if _, ok := resp.CircuitBreakers["outbound:"+strings.TrimPrefix(healthy.URL, "http://")]; !ok {
+		t.Errorf("circuit breakers = %v, want one per region host", resp.CircuitBreakers)
+	}
+}
+
+func TestCrossRegionHealthyWithAMinorityDown(t *testing.T) {
+	endpoints := []string{regionServer(t, http.StatusOK).URL, regionServer(t, http.StatusOK).URL, regionServer(t, http.StatusInternalServerError).URL}
+	check := CrossRegionHealthCheck(endpoints, func(string) *http.Client { return http.DefaultClient })
+
+	if err := check(t.Context()); err != nil {
+		t.Fatalf("check = %v, want nil with 1 of 3 regions down", err)
+	}
+}
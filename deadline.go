@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// NoDeadline disables a read or write deadline for a route, e.g. on a streaming route.
+const NoDeadline time.Duration = -1
+
+// WithReadTimeout overrides the server read timeout for the route. Use NoDeadline to disable it.
+func WithReadTimeout(d time.Duration) RouteOption {
+	return func(r *Route) {
+		r.ReadTimeout = d
+	}
+}
+
+// WithWriteTimeout overrides the server write timeout for the route. Use NoDeadline to disable it.
+func WithWriteTimeout(d time.Duration) RouteOption {
+	return func(r *Route) {
+		r.WriteTimeout = d
+	}
+}
+
+// DeadlineMiddleware sets the read and write deadlines of the connection for the request,
+// overriding the server-wide timeouts. A zero duration keeps the server default and
+// NoDeadline removes the deadline.
+func DeadlineMiddleware(read, write time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Writers that can't reach the connection return ErrNotSupported; the server
+			// timeouts then simply stay in place.
+			rc := http.NewResponseController(w)
+			if read != 0 {
+				_ = rc.SetReadDeadline(deadlineAfter(read))
+			}
+			if write != 0 {
+				_ = rc.SetWriteDeadline(deadlineAfter(write))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func deadlineAfter(d time.Duration) time.Time {
+	if d < 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// routeTimeouts returns the deadlines of route, the configuration taking precedence over
+// the route options. A zero duration in the configuration means no deadline.
+func (a *APIServer) routeTimeouts(route Route) (read, write time.Duration) {
+	read, write = route.ReadTimeout, route.WriteTimeout
+	if d, ok := a.Config.RouteReadTimeouts[route.Pattern]; ok {
+		read = orNoDeadline(d)
+	}
+	if d, ok := a.Config.RouteWriteTimeouts[route.Pattern]; ok {
+		write = orNoDeadline(d)
+	}
+	return read, write
+}
+
+func orNoDeadline(d time.Duration) time.Duration {
+	if d == 0 {
+		return NoDeadline
+	}
+	return d
+}
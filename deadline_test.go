@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRouteDeadlineOverridesServerDefault(t *testing.T) {
+	t.Setenv("GSD_WRITE_TIMEOUT", "100ms")
+	slow := func(w http.ResponseWriter, r *http.Request) error {
+		time.Sleep(300 * time.Millisecond)
+		_, err := io.WriteString(w, "done")
+		return err
+	}
+	a := NewTestAPIServer(t,
+		func(a *APIServer) { a.Handle("GET /stream", slow, WithWriteTimeout(NoDeadline)) },
+		withRoute("GET /strict", slow),
+	)
+
+	resp, err := http.Get(a.URL + "/stream")
+	if err != nil {
+		t.Fatalf("GET /stream: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "done" {
+		t.Fatalf("GET /stream body = %q, %v; want the response written past the server deadline", body, err)
+	}
+
+	// The server write timeout still applies to the other routes
+	if resp, err := http.Get(a.URL + "/strict"); err == nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil && string(body) == "done" {
+			t.Fatal("GET /strict was answered past the server write timeout")
+		}
+	}
+}
+
+func TestRouteTimeoutsConfigTakesPrecedence(t *testing.T) {
+	t.Setenv("GSD_ROUTE_READ_TIMEOUTS", "GET /upload:0s")
+	t.Setenv("GSD_ROUTE_WRITE_TIMEOUTS", "GET /upload:2s")
+	a := newUnstartedTestServer(t)
+
+	read, write := a.routeTimeouts(Route{Pattern: "GET /upload", ReadTimeout: time.Second})
+	if read != NoDeadline || write != 2*time.Second {
+		t.Fatalf("routeTimeouts = %v, %v; want NoDeadline, 2s", read, write)
+	}
+}
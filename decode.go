@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Limits of DecodeJSON outside of a server, e.g. in handlers under test.
+const (
+	_defaultJSONMaxBytes  = 1 << 20
+	_defaultJSONMaxDepth  = 32
+	_defaultJSONMaxTokens = 10_000
+)
+
+// jsonLimits bound the request bodies accepted by DecodeJSON.
+type jsonLimits struct {
+	maxBytes  int64
+	maxDepth  int
+	maxTokens int
+}
+
+func jsonLimitsOf(r *http.Request) jsonLimits {
+	a, ok := ServerFromContext(r.Context())
+	if !ok {
+		return jsonLimits{_defaultJSONMaxBytes, _defaultJSONMaxDepth, _defaultJSONMaxTokens}
+	}
+	return jsonLimits{a.Config.JSONMaxBytes, a.Config.JSONMaxDepth, a.Config.JSONMaxTokens}
+}
+
+// DecodeJSON decodes the JSON body of r into v. The body is checked against
+// Config.JSONMaxBytes, JSONMaxDepth and JSONMaxTokens before anything is decoded, so a
+// deeply nested or huge document can't exhaust the stack or the memory of the decoder.
+// The returned errors are APIErrors: 413 for a body too large, 400 otherwise.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	limits := jsonLimitsOf(r)
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limits.maxBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return APIError{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf("the request body exceeds %d bytes", maxErr.Limit),
+			}
+		}
+		return APIError{Code: http.StatusBadRequest, Message: "failed to read the request body"}
+	}
+
+	if err := checkJSONLimits(body, limits); err != nil {
+		return APIError{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return APIError{Code: http.StatusBadRequest, Message: "invalid request body"}
+	}
+	return nil
+}
+
+// checkJSONLimits scans the tokens of body, failing as soon as a limit is exceeded. Keys
+// count as tokens, delimiters don't.
+func checkJSONLimits(body []byte, limits jsonLimits) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	depth, tokens := 0, 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.New("the request body is not valid JSON")
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > limits.maxDepth {
+				return fmt.Errorf("the request body is nested deeper than %d levels", limits.maxDepth)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		default:
+			tokens++
+			if tokens > limits.maxTokens {
+				return fmt.Errorf("the request body has more than %d values", limits.maxTokens)
+			}
+		}
+		if depth == 0 && dec.More() {
+			return errors.New("the request body holds more than one JSON value")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// nestedJSON returns depth arrays nested in one another around a number.
+func nestedJSON(depth int) string {
+	return strings.Repeat("[", depth) + "1" + strings.Repeat("]", depth)
+}
+
+func TestDecodeJSONLimits(t *testing.T) {
+	t.Setenv("GSD_JSON_MAX_DEPTH", "4")
+	t.Setenv("GSD_JSON_MAX_TOKENS", "5")
+	t.Setenv("GSD_JSON_MAX_BYTES", "64")
+	a := newUnstartedTestServer(t, withRoute("POST /items", func(w http.ResponseWriter, r *http.Request) error {
+		var v any
+		if err := DecodeJSON(w, r, &v); err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, v)
+	}))
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "at the depth limit", body: nestedJSON(4), want: http.StatusOK},
+		{name: "past the depth limit", body: nestedJSON(5), want: http.StatusBadRequest},
+		{name: "deep object", body: `{"a":{"b":{"c":{"d":{"e":1}}}}}`, want: http.StatusBadRequest},
+		{name: "at the token limit", body: `{"a":1,"b":2}`, want: http.StatusOK},
+		{name: "past the token limit", body: `[1,2,3,4,5,6]`, want: http.StatusBadRequest},
+		{name: "too large", body: `"` + strings.Repeat("x", 64) + `"`, want: http.StatusRequestEntityTooLarge},
+		{name: "two values", body: `1 2`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := a.serve(httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONDefaultDepthOutsideServer(t *testing.T) {
+	decode := func(body string) error {
+		var v any
+		return DecodeJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), &v)
+	}
+
+	if err := decode(nestedJSON(_defaultJSONMaxDepth)); err != nil {
+		t.Fatalf("body at the default depth: %v", err)
+	}
+	err := decode(nestedJSON(100_000))
+	var apiErr APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusBadRequest {
+		t.Fatalf("JSON bomb = %v, want a 400 APIError", err)
+	}
+}
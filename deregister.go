@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Deregistrar removes the instance from an external load balancer or service registry
+// (Consul, an ALB target group, ...) where flipping readiness has no effect.
+type Deregistrar interface {
+	Deregister(ctx context.Context) error
+}
+
+// DrainWaiter is optionally implemented by a Deregistrar able to tell when the
+// load balancer has finished draining the connections to this instance.
+type DrainWaiter interface {
+	WaitDrained(ctx context.Context) error
+}
+
+// deregister runs every registered Deregistrar concurrently, bounded by ctx.
+// Failures are logged and never block the rest of the shutdown.
+func (r *Runner) deregister(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, d := range r.deregistrars {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := d.Deregister(ctx); err != nil {
+				r.logger.Error("Failed to deregister instance", zap.Error(err))
+				return
+			}
+
+			waiter, ok := d.(DrainWaiter)
+			if !ok {
+				return
+			}
+			if err := waiter.WaitDrained(ctx); err != nil {
+				r.logger.Error("Failed to wait for load balancer drain", zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type webhookDeregistration struct {
+	Service  string `json:"service"`
+	Instance string `json:"instance"`
+	Event    string `json:"event"`
+}
+
+// WebhookDeregistrar deregisters the instance by POSTing to a webhook.
+type WebhookDeregistrar struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookDeregistrar(url string) *WebhookDeregistrar {
+	return &WebhookDeregistrar{
+		URL:    url,
+		Client: http.DefaultClient,
+	}
+}
+
+func (d *WebhookDeregistrar) Deregister(ctx context.Context) error {
+	instance, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookDeregistration{
+		Service:  _serviceName,
+		Instance: instance,
+		Event:    "deregister",
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("deregistration webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stubDeregistrar records its calls into the mock server, to check where they happen in the
+// shutdown sequence. With block, Deregister waits for the drain budget to run out.
+type stubDeregistrar struct {
+	srv   *MockAPIServer
+	block bool
+	err   error // the context error Deregister returned with
+}
+
+func (d *stubDeregistrar) Deregister(ctx context.Context) error {
+	d.srv.record("Deregister")
+	if d.block {
+		<-ctx.Done()
+		d.err = ctx.Err()
+		return d.err
+	}
+	return nil
+}
+
+func (d *stubDeregistrar) WaitDrained(context.Context) error {
+	d.srv.record("WaitDrained")
+	return nil
+}
+
+func runMockUntilCancelled(t *testing.T, runner *Runner, srv *MockAPIServer) ShutdownReport {
+	t.Helper()
+
+	started := runUntilStarted(srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	return runner.Run(ctx)
+}
+
+func TestDeregistrarRunsDuringDrain(t *testing.T) {
+	srv := NewMockAPIServer()
+	runner := newTestRunner(srv)
+	runner.RegisterDeregistrar(&stubDeregistrar{srv: srv})
+
+	runMockUntilCancelled(t, runner, srv)
+
+	want := []string{"Run", "InitiateShutdown", "RunDrainHooks", "Deregister", "WaitDrained", "Shutdown", "ShutdownResources", "ShutdownTelemetry"}
+	if got := srv.Calls(); !slices.Equal(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestDeregistrarBoundedByDrainBudget(t *testing.T) {
+	srv := NewMockAPIServer()
+	runner := newTestRunner(srv)
+	runner.DrainBudget = 50 * time.Millisecond
+	stuck := &stubDeregistrar{srv: srv, block: true}
+	runner.RegisterDeregistrar(stuck)
+
+	start := time.Now()
+	runMockUntilCancelled(t, runner, srv)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown took %s with a stuck deregistrar, want it bounded by the drain budget", elapsed)
+	}
+	if stuck.err != context.DeadlineExceeded {
+		t.Fatalf("deregistrar returned with %v, want the drain budget deadline", stuck.err)
+	}
+	if got := srv.Calls(); !slices.Contains(got, "ShutdownTelemetry") {
+		t.Fatalf("calls = %v, want the shutdown to go on", got)
+	}
+}
+
+func TestWebhookDeregistrarPostsDeregistration(t *testing.T) {
+	var got webhookDeregistration
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+	}))
+	defer webhook.Close()
+
+	if err := NewWebhookDeregistrar(webhook.URL).Deregister(context.Background()); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	if got.Service != _serviceName || got.Event != "deregister" || got.Instance == "" {
+		t.Fatalf("deregistration = %+v", got)
+	}
+}
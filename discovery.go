@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// DNSResolver resolves a service name to the addresses of its instances.
+type DNSResolver interface {
+	Resolve(ctx context.Context, name string) ([]string, error)
+}
+
+// NetResolver is a DNSResolver backed by a net.Resolver, the system one when nil.
+type NetResolver struct {
+	Resolver *net.Resolver
+}
+
+func (r NetResolver) Resolve(ctx context.Context, name string) ([]string, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return resolver.LookupHost(ctx, name)
+}
+
+// NewServiceDiscoveryHTTPClient returns a client resolving serviceName with resolver on every
+// new connection, instead of relying on the cached system lookup. The addresses are taken in
+// round-robin; one refusing the connection is skipped for the next. Other hosts are dialed
+// as usual. Connections are pooled, so the rotation happens per connection, not per request.
+func NewServiceDiscoveryHTTPClient(resolver DNSResolver, serviceName string) *http.Client {
+	dialer := &net.Dialer{}
+	var next atomic.Uint64
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || host != serviceName {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		ips, err := resolver.Resolve(ctx, serviceName)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", serviceName, err)
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("resolve %s: no addresses", serviceName)
+		}
+
+		var errs error
+		start := next.Add(1) - 1
+		for i := range ips {
+			ip := ips[(start+uint64(i))%uint64(len(ips))]
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = errors.Join(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errs
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// OutboundClient returns the client for calls to rawURL: with Config.ServiceDiscovery its
+// host is resolved through DNS on every new connection, otherwise it's dialed as usual.
+// The calls go through the circuit breaker of the host, reported in the readiness probe.
+func (a *APIServer) OutboundClient(rawURL string) *http.Client {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return http.DefaultClient
+	}
+
+	client := &http.Client{Transport: http.DefaultTransport}
+	if a.Config.ServiceDiscovery {
+		client = NewServiceDiscoveryHTTPClient(NetResolver{}, u.Hostname())
+	}
+	client.Transport = a.outboundBreaker(u.Host).Transport(client.Transport)
+	return client
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// staticResolver resolves every name to the same addresses.
+type staticResolver []string
+
+func (r staticResolver) Resolve(context.Context, string) ([]string, error) {
+	return r, nil
+}
+
+// instancesOnPort starts one server per loopback address in ips, all on the same port, each
+// answering with its address. It returns the port.
+func instancesOnPort(t *testing.T, ips ...string) int {
+	t.Helper()
+
+	port := 0
+	for _, ip := range ips {
+		ln, err := net.Listen("tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+		if err != nil {
+			t.Skipf("listen on %s: %v", ip, err)
+		}
+		port = ln.Addr().(*net.TCPAddr).Port
+
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, ip)
+		}))
+		srv.Listener.Close()
+		srv.Listener = ln
+		srv.Start()
+		t.Cleanup(srv.Close)
+	}
+	return port
+}
+
+func TestServiceDiscoveryRoundRobin(t *testing.T) {
+	instances := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+	port := instancesOnPort(t, instances...)
+	// 127.0.0.4 refuses the connections and is skipped for the next address
+	client := NewServiceDiscoveryHTTPClient(staticResolver{"127.0.0.1", "127.0.0.2", "127.0.0.4", "127.0.0.3"}, "orders.internal")
+
+	var got []string
+	for range 8 {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://orders.internal:%d/", port), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Close = true // a new connection, so a new pick, per request
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		got = append(got, string(body))
+	}
+
+	want := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.3", "127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.3"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("instances hit = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	_maxDrainBudget    = 7 * time.Second
+	_drainPollInterval = 100 * time.Millisecond
+)
+
+// Names accepted by Config.DrainStrategy for the built-in strategies.
+const (
+	DrainFixed       = "fixed"
+	DrainProbe       = "probe"
+	DrainConnections = "connections"
+	DrainScrape      = "scrape"
+)
+
+// ProbeInfo describes the last readiness probe served.
+type ProbeInfo struct {
+	Time  time.Time `json:"time"`
+	Ready bool      `json:"ready"`
+}
+
+// ServerState is what a DrainStrategy can observe while it waits.
+type ServerState interface {
+	InFlightRequests() int64
+	LastProbe() (ProbeInfo, bool)
+	LastScrape() (time.Time, bool)
+	DrainElapsed() time.Duration
+}
+
+// DrainStrategy decides how long to wait, after the readiness flip, before the server stops
+// accepting connections. The runner calls exactly one strategy; ctx ends at the drain budget.
+type DrainStrategy interface {
+	Wait(ctx context.Context, s ServerState) error
+}
+
+// NewDrainStrategy returns the built-in strategy registered under name.
+func NewDrainStrategy(name string) (DrainStrategy, error) {
+	switch name {
+	case DrainFixed:
+		return FixedDelayDrain{Delay: _readinessDrainDelay}, nil
+	case DrainProbe:
+		return ProbeObservedDrain{}, nil
+	case DrainConnections:
+		return ConnectionDrain{}, nil
+	case DrainScrape:
+		return ScrapeConfirmedDrain{Fallback: _readinessDrainDelay}, nil
+	default:
+		return nil, fmt.Errorf("unknown drain strategy %q", name)
+	}
+}
+
+// drainStrategyName returns the name reported for s in the ShutdownReport.
+func drainStrategyName(s DrainStrategy) string {
+	if named, ok := s.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// FixedDelayDrain waits a fixed delay, giving the readiness change time to propagate.
+type FixedDelayDrain struct {
+	Delay time.Duration
+}
+
+func (FixedDelayDrain) Name() string { return DrainFixed }
+
+func (d FixedDelayDrain) Wait(ctx context.Context, _ ServerState) error {
+	select {
+	case <-time.After(d.Delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ProbeObservedDrain waits until a readiness probe has been answered with "not ready",
+// which means the load balancer knows about the drain.
+type ProbeObservedDrain struct{}
+
+func (ProbeObservedDrain) Name() string { return DrainProbe }
+
+func (ProbeObservedDrain) Wait(ctx context.Context, s ServerState) error {
+	return pollUntil(ctx, func() bool {
+		probe, ok := s.LastProbe()
+		return ok && !probe.Ready && time.Since(probe.Time) < s.DrainElapsed()
+	})
+}
+
+// ConnectionDrain waits until no request is in flight anymore.
+type ConnectionDrain struct{}
+
+func (ConnectionDrain) Name() string { return DrainConnections }
+
+func (ConnectionDrain) Wait(ctx context.Context, s ServerState) error {
+	return pollUntil(ctx, func() bool {
+		return s.InFlightRequests() == 0
+	})
+}
+
+// ScrapeConfirmedDrain waits until /metrics has been scraped since the drain started, which
+// means the not-ready timestamp reached the metric-driven load balancer. Without a scrape it
+// falls back to the fixed delay.
+type ScrapeConfirmedDrain struct {
+	Fallback time.Duration
+}
+
+func (ScrapeConfirmedDrain) Name() string { return DrainScrape }
+
+func (d ScrapeConfirmedDrain) Wait(ctx context.Context, s ServerState) error {
+	fallbackCtx, cancel := context.WithTimeout(ctx, d.Fallback)
+	defer cancel()
+
+	err := pollUntil(fallbackCtx, func() bool {
+		at, ok := s.LastScrape()
+		return ok && time.Since(at) < s.DrainElapsed()
+	})
+	if err != nil && ctx.Err() == nil {
+		return nil // fallback delay elapsed
+	}
+	return err
+}
+
+func pollUntil(ctx context.Context, done func() bool) error {
+	ticker := time.NewTicker(_drainPollInterval)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// drainState exposes a Server to a DrainStrategy during the drain phase.
+type drainState struct {
+	Server
+	start time.Time
+}
+
+func (s drainState) DrainElapsed() time.Duration {
+	return time.Since(s.start)
+}
+
+// trackInFlight counts the requests being served.
+func (a *APIServer) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.inFlight.Add(1)
+		id := a.inFlightRequests.add(r)
+		defer func() {
+			a.inFlightRequests.remove(id)
+			a.inFlight.Add(-1)
+			a.served.Add(1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// waitRequestsDone polls until no request is in flight or ctx is done.
+func (a *APIServer) waitRequestsDone(ctx context.Context) error {
+	ticker := time.NewTicker(_drainPollInterval)
+	defer ticker.Stop()
+	for a.inFlight.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// InFlightRequests returns the number of requests being served.
+func (a *APIServer) InFlightRequests() int64 {
+	return a.inFlight.Load()
+}
+
+// ActiveWorkerSpans returns the number of worker spans not ended yet.
+func (a *APIServer) ActiveWorkerSpans() int {
+	return a.Spans.Active()
+}
+
+// RequestsServed returns the number of requests served since the server started.
+func (a *APIServer) RequestsServed() int64 {
+	return a.served.Load()
+}
+
+// LastProbe returns the last readiness probe served, if any.
+func (a *APIServer) LastProbe() (ProbeInfo, bool) {
+	probe := a.lastProbe.Load()
+	if probe == nil {
+		return ProbeInfo{}, false
+	}
+	return *probe, true
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// DrainRejectMiddleware answers 503 with "Connection: close" to the requests still arriving
+// once the drain strategy is done waiting and the server is closing. During the drain itself
+// requests are served, since the load balancers may not have seen the readiness flip yet.
+// Paths in exempt, such as the probes and /metrics, keep being served until the server
+// closes so monitoring can take a final scrape.
+func (a *APIServer) DrainRejectMiddleware(exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !a.drained.Load() || slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Connection", "close")
+			a.writeError(w, r, APIError{
+				Code:    http.StatusServiceUnavailable,
+				Message: "the server is shutting down",
+			})
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDrainRejectAfterTheDrainWait(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "recovery,drain_reject")
+	t.Setenv("GSD_PROMETHEUS_ENABLED", "true")
+	a := newUnstartedTestServer(t,
+		withBackends(func(b *Backends) { b.OTel.promRegistry = prometheus.NewRegistry() }),
+		withRoute("GET /work", func(w http.ResponseWriter, r *http.Request) error {
+			return WriteJSON(w, http.StatusOK, map[string]string{"status": "done"})
+		}),
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		return a.serve(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// During the drain the load balancers may still route here: requests are served
+	a.InitiateShutdown()
+	if rec := get("/work"); rec.Code != http.StatusOK {
+		t.Fatalf("/work during the drain = %d, want 200", rec.Code)
+	}
+	if rec := get(_metricsPath); rec.Code != http.StatusOK {
+		t.Fatalf("/metrics during the drain = %d, want 200", rec.Code)
+	}
+
+	// Once the drain strategy is done and the server closes, only the exempt paths are served
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	rec := get("/work")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Connection") != "close" {
+		t.Fatalf("/work after the drain = %d with Connection %q, want 503 with close", rec.Code, rec.Header().Get("Connection"))
+	}
+	if rec := get(_metricsPath); rec.Code != http.StatusOK {
+		t.Fatalf("/metrics after the drain = %d, want 200", rec.Code)
+	}
+	if a.lastScrape.Load() == 0 {
+		t.Fatal("the scrapes were not tracked")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// meshAckDrain stands in for a user strategy that waits for a control plane acknowledgement
+// which never comes.
+type meshAckDrain struct {
+	calls   int
+	state   ServerState
+	ctxDone bool
+}
+
+func (meshAckDrain) Name() string { return "mesh-ack" }
+
+func (d *meshAckDrain) Wait(ctx context.Context, s ServerState) error {
+	d.calls++
+	d.state = s
+	<-ctx.Done()
+	d.ctxDone = true
+	return ctx.Err()
+}
+
+func TestCustomDrainStrategyConsultedAndBounded(t *testing.T) {
+	srv := NewMockAPIServer()
+	strategy := &meshAckDrain{}
+	runner := newTestRunner(srv)
+	runner.DrainStrategy = strategy
+	runner.DrainBudget = 50 * time.Millisecond
+
+	start := time.Now()
+	report := runMockUntilCancelled(t, runner, srv)
+
+	if strategy.calls != 1 {
+		t.Fatalf("strategy called %d times, want 1", strategy.calls)
+	}
+	if !strategy.ctxDone {
+		t.Fatal("strategy context was not cancelled at the drain budget")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown took %v, want it bounded by the 50ms drain budget", elapsed)
+	}
+	if elapsed := strategy.state.DrainElapsed(); elapsed < runner.DrainBudget {
+		t.Fatalf("DrainElapsed = %v, want at least the drain budget", elapsed)
+	}
+	if report.DrainStrategy != "mesh-ack" {
+		t.Fatalf("report drain strategy = %q, want mesh-ack", report.DrainStrategy)
+	}
+}
+
+func TestNewDrainStrategyBuiltins(t *testing.T) {
+	for _, name := range []string{DrainFixed, DrainProbe, DrainConnections, DrainScrape} {
+		s, err := NewDrainStrategy(name)
+		if err != nil {
+			t.Fatalf("NewDrainStrategy(%q): %v", name, err)
+		}
+		if got := drainStrategyName(s); got != name {
+			t.Errorf("drainStrategyName(NewDrainStrategy(%q)) = %q", name, got)
+		}
+	}
+	if _, err := NewDrainStrategy("mesh"); err == nil {
+		t.Fatal("NewDrainStrategy accepted an unknown name")
+	}
+}
+
+func TestReportTellsDrainWaitFromDrainHooks(t *testing.T) {
+	srv := NewMockAPIServer()
+	srv.RunDrainHooksFunc = func(context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}
+	runner := newTestRunner(srv)
+	runner.DrainStrategy = FixedDelayDrain{Delay: 20 * time.Millisecond}
+
+	report := runMockUntilCancelled(t, runner, srv)
+
+	if report.DrainWaitedMS < 20 || report.DrainWaitedMS >= 100 {
+		t.Fatalf("drain waited %vms, want the 20ms of the strategy alone", report.DrainWaitedMS)
+	}
+	if drain := report.Phases[0]; drain.Name != "drain" || drain.DurationMS < 120 {
+		t.Fatalf("first phase = %+v, want the drain with its hooks and wait", drain)
+	}
+}
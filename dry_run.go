@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// _dryRunTimeout is how long a dry run keeps the server running by default.
+const _dryRunTimeout = 10 * time.Minute
+
+// ShutdownPlanPhase is a phase of the shutdown sequence, with its time budget and steps.
+// A phase with SharedBudget gets what the previous phase left of their common budget.
+type ShutdownPlanPhase struct {
+	Name         string        `json:"name"`
+	Budget       time.Duration `json:"budget"`
+	SharedBudget bool          `json:"shared_budget,omitempty"`
+	Steps        []string      `json:"steps"`
+}
+
+// ShutdownPlan returns the phases the shutdown sequence would go through.
+func (r *Runner) ShutdownPlan() []ShutdownPlanPhase {
+	drainHooks, shutdownHooks := r.server.HookNames()
+
+	drainSteps := []string{"flip readiness to not ready", "disable keep-alives"}
+	for _, name := range drainHooks {
+		drainSteps = append(drainSteps, "drain hook "+name)
+	}
+	if len(r.deregistrars) > 0 {
+		drainSteps = append(drainSteps, fmt.Sprintf("deregister from %d load balancers", len(r.deregistrars)))
+	}
+	drainSteps = append(drainSteps, "wait with drain strategy "+drainStrategyName(r.DrainStrategy))
+
+	resourceSteps := make([]string, 0, len(shutdownHooks))
+	for _, name := range shutdownHooks {
+		resourceSteps = append(resourceSteps, "shutdown hook "+name)
+	}
+
+	// The HTTP, resources and telemetry phases share the shutdown period
+	return []ShutdownPlanPhase{
+		{Name: "drain", Budget: r.DrainBudget, Steps: drainSteps},
+		{Name: "http_shutdown", Budget: r.ShutdownPeriod, Steps: []string{"stop accepting connections", "wait for in-flight requests", "cancel the remaining requests"}},
+		{Name: "resources", Budget: r.ShutdownPeriod, SharedBudget: true, Steps: resourceSteps},
+		{Name: "telemetry", Budget: r.ShutdownPeriod, SharedBudget: true, Steps: []string{"wait for worker spans", "flush traces and metrics"}},
+		{Name: "hard_period", Budget: r.HardPeriod, Steps: []string{"wait before exit"}},
+	}
+}
+
+// dryRunUntilSignal simulates the shutdown, then keeps the server running until the next
+// signal or the dry run timeout, either of which also cuts the simulation short. The signals
+// are listened to again before anything is logged, so a second one is never handled by the
+// default, killing, handler.
+func (r *Runner) dryRunUntilSignal() {
+	signalCtx, stop := r.Signals(context.Background())
+	defer stop()
+
+	ctx := signalCtx
+	if r.DryRunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(signalCtx, r.DryRunTimeout)
+		defer cancel()
+	}
+
+	r.dryRun(ctx)
+
+	<-ctx.Done()
+	if signalCtx.Err() != nil {
+		r.logger.Warn("Shutdown dry run: signal received again, shutting down")
+	} else {
+		r.logger.Warn("Shutdown dry run: timed out, shutting down", zap.Duration("timeout", r.DryRunTimeout))
+	}
+}
+
+// dryRun goes through the phases of the shutdown plan, each against its budget, and logs
+// how long each one took. Nothing is closed: the steps closing something are no-ops, and
+// the server keeps running and serving traffic. The waits do happen: the drain strategy,
+// seeing the probes as not ready as they would be after the flip, then the in-flight
+// requests and the worker spans. The hard period isn't waited.
+func (r *Runner) dryRun(ctx context.Context) {
+	worstCase := r.DrainBudget + r.ShutdownPeriod + r.HardPeriod
+	r.logger.Warn("Shutdown dry run: the server keeps running, nothing is closed",
+		zap.Duration("worst_case_duration", worstCase),
+	)
+
+	var phaseCtx context.Context
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	for _, phase := range r.ShutdownPlan() {
+		if !phase.SharedBudget || phaseCtx == nil {
+			var cancel context.CancelFunc
+			phaseCtx, cancel = context.WithTimeout(ctx, phase.Budget)
+			cancels = append(cancels, cancel)
+		}
+		budget := phase.Budget
+		if deadline, ok := phaseCtx.Deadline(); ok {
+			budget = max(time.Until(deadline), 0)
+		}
+
+		start := time.Now()
+		err := r.simulatePhase(phaseCtx, phase.Name)
+		d := time.Since(start)
+		if ctx.Err() != nil {
+			return // the dry run is over
+		}
+
+		level := zapcore.InfoLevel
+		if err != nil {
+			level = zapcore.WarnLevel
+		}
+		r.logger.Log(level, "Shutdown dry run: phase",
+			zap.String("phase", phase.Name),
+			zap.Duration("budget", budget),
+			zap.Duration("duration", d),
+			zap.Bool("within_budget", err == nil),
+			zap.Strings("steps", phase.Steps),
+		)
+	}
+}
+
+// simulatePhase runs the steps of the phase name that close nothing, the waits, and fails
+// when ctx, its budget, ends first.
+func (r *Runner) simulatePhase(ctx context.Context, name string) error {
+	switch name {
+	case "drain":
+		return r.DrainStrategy.Wait(ctx, dryRunState{drainState{Server: r.server, start: time.Now()}})
+	case "http_shutdown":
+		return pollUntil(ctx, func() bool { return r.server.InFlightRequests() == 0 })
+	case "telemetry":
+		spansCtx, cancel := context.WithTimeout(ctx, _workerSpanGracePeriod)
+		defer cancel()
+		if err := pollUntil(spansCtx, func() bool { return r.server.ActiveWorkerSpans() == 0 }); err != nil {
+			return err
+		}
+		return ctx.Err() // the flush needs what's left of the budget
+	case "hard_period":
+		return nil // a sleep, not a deadline
+	default:
+		return ctx.Err()
+	}
+}
+
+// dryRunState is the drain state of a dry run, where readiness was never flipped: the
+// probes are reported as not ready, as they would have been answered.
+type dryRunState struct {
+	drainState
+}
+
+func (s dryRunState) LastProbe() (ProbeInfo, bool) {
+	probe, ok := s.drainState.LastProbe()
+	probe.Ready = false
+	return probe, ok
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newDryRunner returns a dry-running Runner for srv logging to the returned logs, whose
+// second signal is sent by cancelling the returned function.
+func newDryRunner(srv *MockAPIServer) (*Runner, *observer.ObservedLogs, context.CancelFunc) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	runner := newTestRunner(srv)
+	runner.logger = zap.New(core)
+	runner.DryRun = true
+
+	signalCtx, signal := context.WithCancel(context.Background())
+	runner.Signals = func(parent context.Context) (context.Context, context.CancelFunc) {
+		return signalCtx, func() {}
+	}
+	return runner, logs, signal
+}
+
+func TestDryRunLogsPlanAndKeepsServing(t *testing.T) {
+	srv := NewMockAPIServer()
+	runner, logs, signal := newDryRunner(srv)
+
+	done := make(chan ShutdownReport, 1)
+	go func() { done <- runMockUntilCancelled(t, runner, srv) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("Shutdown dry run: phase").Len() < len(runner.ShutdownPlan()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("logs = %v, want every phase of the plan", logs.All())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if calls := srv.Calls(); !slices.Equal(calls, []string{"Run"}) {
+		t.Fatalf("calls during the dry run = %v, want the server left running", calls)
+	}
+
+	// The second signal shuts down for real
+	signal()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the second signal did not shut the server down")
+	}
+	if calls := srv.Calls(); !slices.Contains(calls, "Shutdown") {
+		t.Fatalf("calls = %v, want the server shut down", calls)
+	}
+}
+
+func TestDryRunTimesPhasesAgainstTheirBudget(t *testing.T) {
+	srv := NewMockAPIServer()
+	runner, logs, signal := newDryRunner(srv)
+	defer signal()
+	runner.DrainStrategy = FixedDelayDrain{Delay: time.Minute}
+	runner.DrainBudget = 50 * time.Millisecond
+
+	go runMockUntilCancelled(t, runner, srv)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("Shutdown dry run: phase").Len() < len(runner.ShutdownPlan()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("logs = %v, want every phase of the plan", logs.All())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, entry := range logs.FilterMessage("Shutdown dry run: phase").All() {
+		fields := entry.ContextMap()
+		overBudget := fields["phase"] == "drain"
+		if fields["within_budget"] == overBudget {
+			t.Errorf("phase %v within budget = %v, want %v", fields["phase"], fields["within_budget"], !overBudget)
+		}
+		if d := fields["duration"].(time.Duration); d > time.Second {
+			t.Errorf("phase %v took %v, want it bounded by its budget", fields["phase"], d)
+		}
+	}
+}
+
+func TestDryRunWaitsWithinTheSharedShutdownPeriod(t *testing.T) {
+	tests := []struct {
+		name        string
+		inFlight    int64
+		workerSpans int
+		overBudget  []string
+	}{
+		// The in-flight request uses up the budget the next phases share
+		{name: "request in flight", inFlight: 1, overBudget: []string{"http_shutdown", "resources", "telemetry"}},
+		{name: "worker span", workerSpans: 1, overBudget: []string{"telemetry"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewMockAPIServer()
+			srv.InFlight, srv.WorkerSpans = tt.inFlight, tt.workerSpans
+			runner, logs, signal := newDryRunner(srv)
+			defer signal()
+			runner.ShutdownPeriod = 100 * time.Millisecond
+
+			go runMockUntilCancelled(t, runner, srv)
+
+			deadline := time.Now().Add(2 * time.Second)
+			for logs.FilterMessage("Shutdown dry run: phase").Len() < len(runner.ShutdownPlan()) {
+				if time.Now().After(deadline) {
+					t.Fatalf("logs = %v, want every phase of the plan", logs.All())
+				}
+				time.Sleep(time.Millisecond)
+			}
+			var over []string
+			for _, entry := range logs.FilterMessage("Shutdown dry run: phase").All() {
+				if fields := entry.ContextMap(); fields["within_budget"] == false {
+					over = append(over, fields["phase"].(string))
+				}
+			}
+			if !slices.Equal(over, tt.overBudget) {
+				t.Fatalf("phases over budget = %v, want %v", over, tt.overBudget)
+			}
+		})
+	}
+}
+
+func TestDryRunKeepsServingTraffic(t *testing.T) {
+	port := freePort(t)
+	t.Setenv("GSD_PORT", strconv.Itoa(port))
+	a := newUnstartedTestServer(t)
+	var closed bool
+	a.RegisterShutdownHook("db", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	runner := newTestRunner(a.APIServer)
+	runner.logger = zap.New(core)
+	runner.DryRun = true
+	runner.DrainStrategy = ProbeObservedDrain{}
+	runner.DrainBudget = time.Second // no probe follows the real readiness flip
+	signalCtx, signal := context.WithCancel(context.Background())
+	runner.Signals = func(context.Context) (context.Context, context.CancelFunc) {
+		return signalCtx, func() {}
+	}
+
+	rootCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan ShutdownReport, 1)
+	go func() { done <- runner.Run(rootCtx) }()
+	if err := WaitForPort("127.0.0.1", port, _testServerStartTimeout); err != nil {
+		t.Fatal(err)
+	}
+	a.warmedUp.Store(true)
+	cancel()
+
+	// The drain strategy sees the probe answered during the dry run as not ready
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("Shutdown dry run: phase").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("logs = %v, want the drain phase simulated", logs.All())
+		}
+		if code := getStatus(t, "http://127.0.0.1:"+strconv.Itoa(port)+"/healthz"); code != http.StatusOK {
+			t.Fatalf("readiness during the dry run = %d, want 200", code)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if within := logs.FilterMessage("Shutdown dry run: phase").All()[0].ContextMap()["within_budget"]; within != true {
+		t.Fatalf("drain phase within budget = %v, want the probe observed", within)
+	}
+
+	if code := getStatus(t, "http://127.0.0.1:"+strconv.Itoa(port)+"/"); code != http.StatusOK {
+		t.Fatalf("request during the dry run = %d, want 200", code)
+	}
+	if closed || a.isShuttingDown.Load() {
+		t.Fatal("the dry run shut the server down")
+	}
+
+	signal()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second signal did not shut the server down")
+	}
+	if !closed {
+		t.Fatal("the second signal did not close the resources")
+	}
+}
+
+func TestDryRunTimesOut(t *testing.T) {
+	srv := NewMockAPIServer()
+	runner, logs, _ := newDryRunner(srv)
+	runner.DryRunTimeout = 50 * time.Millisecond
+
+	done := make(chan ShutdownReport, 1)
+	go func() { done <- runMockUntilCancelled(t, runner, srv) }()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the dry run did not time out")
+	}
+	if logs.FilterMessage("Shutdown dry run: timed out, shutting down").Len() != 1 {
+		t.Fatalf("logs = %v, want the timeout logged", logs.All())
+	}
+}
@@ -1,12 +1,71 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// _statusClientClosedRequest is the non-standard status used (as nginx does) when the client
+// went away before the response was written.
+const _statusClientClosedRequest = 499
+
+// ErrServerShuttingDown is the cause of the ongoing context cancellation once the
+// shutdown period is over and in-flight requests are forcibly cancelled.
+var ErrServerShuttingDown = errors.New("server is shutting down")
+
+// ErrHookTimeout is returned for a drain or shutdown hook still running past its deadline.
+var ErrHookTimeout = errors.New("hook timed out")
 
 type APIError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	// Details carries structured information about the error, such as a *ValidationError.
+	Details any `json:"details,omitempty"`
 }
 
 func (e APIError) Error() string {
 	return fmt.Sprintf("api error: code=%d, message=%s", e.Code, e.Message)
 }
+
+// RetryableError is implemented by errors of operations that may succeed if attempted again.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// isRetryable reports whether err asks to be retried. Context cancellation is never retried.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var retryable RetryableError
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+// errorCollector joins up to limit errors and summarizes the rest as "+N more", so
+// a long list of failures can't grow the joined error without bound.
+type errorCollector struct {
+	limit   int
+	errs    []error
+	dropped int
+}
+
+func (c *errorCollector) add(err error) {
+	if err == nil {
+		return
+	}
+	if len(c.errs) < c.limit {
+		c.errs = append(c.errs, err)
+		return
+	}
+	c.dropped++
+}
+
+func (c *errorCollector) err() error {
+	if c.dropped > 0 {
+		return errors.Join(append(c.errs, fmt.Errorf("+%d more errors", c.dropped))...)
+	}
+	return errors.Join(c.errs...)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// alternatingRand returns 0.2 and 0.8 in turn.
+func alternatingRand() func() float64 {
+	var n int
+	return func() float64 {
+		n++
+		if n%2 == 1 {
+			return 0.2
+		}
+		return 0.8
+	}
+}
+
+func failingRoute() Option {
+	return withRoute("GET /fail", func(http.ResponseWriter, *http.Request) error {
+		return errors.New("database unreachable")
+	})
+}
+
+func TestInternalErrorLogsAreSampled(t *testing.T) {
+	t.Setenv("GSD_ERROR_LOG_SAMPLE_RATE", "0.5")
+	a := newUnstartedTestServer(t, WithRandSource(alternatingRand()), failingRoute())
+
+	for range 4 {
+		if rec := a.serve(httptest.NewRequest(http.MethodGet, "/fail", nil)); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", rec.Code)
+		}
+	}
+
+	if got := a.Logs.FilterMessage("Request failed").Len(); got != 2 {
+		t.Fatalf("%d errors logged, want 2 of 4 at a 0.5 sample rate", got)
+	}
+}
+
+func TestInternalErrorsAlwaysLoggedInDevelopment(t *testing.T) {
+	t.Setenv("GSD_ENV", "development")
+	t.Setenv("GSD_ERROR_LOG_SAMPLE_RATE", "0")
+	a := newUnstartedTestServer(t, WithRandSource(alternatingRand()), failingRoute())
+
+	for range 4 {
+		a.serve(httptest.NewRequest(http.MethodGet, "/fail", nil))
+	}
+
+	if got := a.Logs.FilterMessage("Request failed").Len(); got != 4 {
+		t.Fatalf("%d errors logged, want every one in development", got)
+	}
+}
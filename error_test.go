@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestErrorCollectorCapsJoinedErrors(t *testing.T) {
+	c := errorCollector{limit: 3}
+	errs := make([]error, 10)
+	for i := range errs {
+		errs[i] = fmt.Errorf("error %d", i)
+		c.add(errs[i])
+	}
+	c.add(nil)
+
+	err := c.err()
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 4 {
+		t.Fatalf("err = %v, want the first 3 errors and a summary", err)
+	}
+	for _, e := range errs[:3] {
+		if !errors.Is(err, e) {
+			t.Errorf("%v missing from the joined error", e)
+		}
+	}
+	if errors.Is(err, errs[3]) {
+		t.Error("errors past the limit were kept")
+	}
+	if !strings.HasSuffix(err.Error(), "+7 more errors") {
+		t.Errorf("err = %q, want it to end with the +7 more errors summary", err)
+	}
+}
+
+func TestErrorCollectorWithinLimit(t *testing.T) {
+	var c errorCollector
+	if err := c.err(); err != nil {
+		t.Fatalf("err of an empty collector = %v, want nil", err)
+	}
+
+	c.limit = 2
+	c.add(errors.New("only"))
+	if err := c.err(); err == nil || strings.Contains(err.Error(), "more errors") {
+		t.Fatalf("err = %v, want the error without a summary", err)
+	}
+}
+
+func TestShutdownResourcesErrorSummary(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	for i := range _maxShutdownErrors + 5 {
+		a.RegisterShutdownHook(fmt.Sprintf("hook%d", i), func(context.Context) error {
+			return errors.New("failed")
+		})
+	}
+
+	err := a.ShutdownResources(context.Background())
+	if err == nil || !strings.HasSuffix(err.Error(), "+5 more errors") {
+		t.Fatalf("ShutdownResources = %v, want the +5 more errors summary", err)
+	}
+}
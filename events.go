@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	EventLifecycle = "lifecycle"
+	EventState     = "state"
+	EventHook      = "hook"
+	EventRejected  = "rejected"
+)
+
+type Event struct {
+	Seq     uint64            `json:"seq"`
+	Time    time.Time         `json:"time"`
+	Kind    string            `json:"kind"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// EventRing keeps the most recent events in a fixed-size ring. Recording is lock-free
+// (an atomic counter picks the slot, an atomic pointer swap fills it), so it is cheap
+// enough to be called from hot paths.
+type EventRing struct {
+	next  atomic.Uint64
+	slots []atomic.Pointer[Event]
+}
+
+func NewEventRing(size int) *EventRing {
+	return &EventRing{
+		slots: make([]atomic.Pointer[Event], max(size, 1)),
+	}
+}
+
+// Record adds an event. attrs are key/value pairs.
+func (r *EventRing) Record(kind, message string, attrs ...string) {
+	e := &Event{
+		Seq:     r.next.Add(1),
+		Time:    time.Now(),
+		Kind:    kind,
+		Message: message,
+	}
+	if len(attrs) > 0 {
+		e.Attrs = make(map[string]string, len(attrs)/2)
+		for i := 0; i+1 < len(attrs); i += 2 {
+			e.Attrs[attrs[i]] = attrs[i+1]
+		}
+	}
+	r.slots[e.Seq%uint64(len(r.slots))].Store(e)
+}
+
+// Events returns the buffered events, oldest first.
+func (r *EventRing) Events() []Event {
+	events := make([]Event, 0, len(r.slots))
+	for i := range r.slots {
+		if e := r.slots[i].Load(); e != nil {
+			events = append(events, *e)
+		}
+	}
+	slices.SortFunc(events, func(a, b Event) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return events
+}
+
+// countRejected is called for every request rejected with a 503; the counts are
+// recorded as one event per second by recordRejected.
+func (a *APIServer) countRejected() {
+	a.rejected.Add(1)
+}
+
+func (a *APIServer) recordRejected(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if n := a.rejected.Swap(0); n > 0 {
+				a.Events.Record(EventRejected, "requests rejected", "count", fmt.Sprint(n))
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// recordOutcome records the outcome of a shutdown step.
+func (a *APIServer) recordOutcome(kind, message string, err error) {
+	if err != nil {
+		a.Events.Record(kind, message, "outcome", "error", "error", err.Error())
+		return
+	}
+	a.Events.Record(kind, message, "outcome", "ok")
+}
+
+func (a *APIServer) handleGetEvents(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJSON(w, http.StatusOK, a.Events.Events())
+}
+
+// dumpEvents writes every buffered event to the log and, when configured, to the
+// termination message file so they survive the process.
+func (a *APIServer) dumpEvents() {
+	events := a.Events.Events()
+	a.Logger.Info("Shutdown events", zap.Any("events", events))
+
+	if a.Config.TerminationMessagePath == "" {
+		return
+	}
+
+	b, err := json.Marshal(events)
+	if err == nil {
+		err = os.WriteFile(a.Config.TerminationMessagePath, b, 0o644)
+	}
+	if err != nil {
+		a.Logger.Error("Failed to write termination message", zap.Error(err))
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEventsServedInOrderAndCapped(t *testing.T) {
+	t.Setenv("GSD_EVENT_BUFFER_SIZE", "4")
+	a := newUnstartedTestServer(t)
+
+	for i := range 10 {
+		a.Events.Record(EventHook, fmt.Sprintf("event %d", i))
+	}
+
+	rec := httptest.NewRecorder()
+	a.adminMux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+	var events []Event
+	if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+		t.Fatalf("decode events: %v", err)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want the last 4", len(events))
+	}
+	for i, e := range events {
+		if want := fmt.Sprintf("event %d", 6+i); e.Message != want {
+			t.Errorf("events[%d] = %q, want %q", i, e.Message, want)
+		}
+		if i > 0 && e.Seq <= events[i-1].Seq {
+			t.Errorf("events out of order: %d after %d", e.Seq, events[i-1].Seq)
+		}
+	}
+}
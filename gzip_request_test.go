@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, s string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestGzipDecompressionMiddleware(t *testing.T) {
+	const body = `{"name":"gopher","tags":["a","b"]}`
+
+	var received string
+	var encoding string
+	var length int64
+	handler := GzipDecompressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		received, encoding, length = string(b), r.Header.Get("Content-Encoding"), r.ContentLength
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/items", gzipped(t, body))
+	req.Header.Set("Content-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if received != body {
+		t.Fatalf("handler received %q, want %q", received, body)
+	}
+	if encoding != "" || length != -1 {
+		t.Fatalf("Content-Encoding %q, ContentLength %d: want them cleared once decompressed", encoding, length)
+	}
+
+	// Plain bodies pass through untouched
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body)))
+	if received != body {
+		t.Fatalf("handler received %q, want %q", received, body)
+	}
+}
+
+func TestGzipDecompressionRejectsInvalidBody(t *testing.T) {
+	called := false
+	handler := GzipDecompressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"not":"gzip"}`))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || called {
+		t.Fatalf("status = %d, handler called %v: want 400 without calling it", rec.Code, called)
+	}
+}
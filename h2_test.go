@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTP2ShutdownRefusesNewStreams(t *testing.T) {
+	t.Setenv("GSD_HTTP2_CLEARTEXT", "true")
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	a := NewTestAPIServer(t, withRoute("GET /slow", func(w http.ResponseWriter, r *http.Request) error {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}))
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	transport := &http.Transport{Protocols: protocols}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	inFlight := make(chan *http.Response, 1)
+	go func() {
+		resp, err := client.Get(a.URL + "/slow")
+		if err != nil {
+			t.Errorf("in-flight stream: %v", err)
+			close(inFlight)
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp
+	}()
+	<-entered
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErr <- a.Shutdown(ctx)
+	}()
+
+	// Once the GOAWAY is sent and the listener closed, no new stream is accepted
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := client.Get(a.URL + "/healthz")
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("new streams are still accepted after shutdown began")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	resp, ok := <-inFlight
+	if !ok {
+		t.FailNow()
+	}
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Fatalf("in-flight stream = %s %d, want HTTP/2 200", resp.Proto, resp.StatusCode)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HeaderLimitsMiddleware rejects with 431 the requests carrying more than maxCount header
+// values, or a single header value longer than maxValueBytes. A zero limit is not enforced.
+// It complements http.Server.MaxHeaderBytes, which only bounds the total size.
+func HeaderLimitsMiddleware(maxCount, maxValueBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if msg := checkHeaderLimits(r.Header, maxCount, maxValueBytes); msg != "" {
+				WriteJSON(w, http.StatusRequestHeaderFieldsTooLarge, APIError{
+					Code:    http.StatusRequestHeaderFieldsTooLarge,
+					Message: msg,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func checkHeaderLimits(h http.Header, maxCount, maxValueBytes int) string {
+	count := 0
+	for name, values := range h {
+		count += len(values)
+		if maxValueBytes <= 0 {
+			continue
+		}
+		for _, v := range values {
+			if len(v) > maxValueBytes {
+				return fmt.Sprintf("header %q is longer than %d bytes", name, maxValueBytes)
+			}
+		}
+	}
+
+	if maxCount > 0 && count > maxCount {
+		return fmt.Sprintf("too many headers: %d, at most %d are allowed", count, maxCount)
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeaderLimitsMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		header func(http.Header)
+		want   int
+	}{
+		{"within limits", func(h http.Header) { h.Set("X-Trace", "abc") }, http.StatusOK},
+		{"too many headers", func(h http.Header) {
+			for i := range 5 {
+				h.Set(fmt.Sprintf("X-Extra-%d", i), "v")
+			}
+		}, http.StatusRequestHeaderFieldsTooLarge},
+		{"oversized value", func(h http.Header) { h.Set("Cookie", strings.Repeat("a", 65)) }, http.StatusRequestHeaderFieldsTooLarge},
+	}
+	h := HeaderLimitsMiddleware(4, 64)(_okHandler)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.header(req.Header)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
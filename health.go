@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const _healthCheckTimeout = 2 * time.Second
+
+// HealthCheck reports whether a dependency is able to serve traffic.
+type HealthCheck func(ctx context.Context) error
+
+type healthCheck struct {
+	name  string
+	check HealthCheck
+	// readyAtStartup ignores the failures until passed is set by the first success.
+	readyAtStartup bool
+	passed         *atomic.Bool
+}
+
+// HealthCheckOption customizes a health check given to RegisterHealthCheck.
+type HealthCheckOption func(*healthCheck)
+
+// ReadyUntilFirstSuccess lets the pod become ready while the check fails at startup, until
+// it passes once; from then on its failures count. It suits dependencies that themselves
+// wait for this service, which would otherwise never become ready. By default a check
+// keeps the pod not ready until it passes, the safe choice.
+func ReadyUntilFirstSuccess() HealthCheckOption {
+	return func(hc *healthCheck) {
+		hc.readyAtStartup = true
+	}
+}
+
+// RegisterHealthCheck adds a check that must pass for the readiness probe to report ok.
+// Checks named in Config.HealthChecksReadyAtStartup are ReadyUntilFirstSuccess.
+func (a *APIServer) RegisterHealthCheck(name string, check HealthCheck, opts ...HealthCheckOption) {
+	hc := healthCheck{
+		name:           name,
+		check:          check,
+		readyAtStartup: slices.Contains(a.Config.HealthChecksReadyAtStartup, name),
+		passed:         new(atomic.Bool),
+	}
+	for _, opt := range opts {
+		opt(&hc)
+	}
+	a.healthChecks = append(a.healthChecks, hc)
+}
+
+// failing reports whether err fails the readiness, and remembers the first success.
+func (hc healthCheck) failing(err error) bool {
+	if err == nil {
+		hc.passed.Store(true)
+		return false
+	}
+	return !hc.readyAtStartup || hc.passed.Load()
+}
+
+// RegisterHealthWarning adds a check reported by the readiness probe that never fails it.
+func (a *APIServer) RegisterHealthWarning(name string, check HealthCheck) {
+	a.healthWarnings = append(a.healthWarnings, healthCheck{name: name, check: check})
+}
+
+// runHealthWarnings runs the warning checks and returns the failing ones by name.
+func (a *APIServer) runHealthWarnings(ctx context.Context) map[string]string {
+	var warnings map[string]string
+	for _, hc := range a.healthWarnings {
+		checkCtx, cancel := context.WithTimeout(ctx, _healthCheckTimeout)
+		err := hc.check(checkCtx)
+		cancel()
+
+		if err != nil {
+			if warnings == nil {
+				warnings = make(map[string]string)
+			}
+			warnings[hc.name] = err.Error()
+		}
+	}
+	return warnings
+}
+
+// runHealthChecks runs the registered checks in order and stops at the first failure,
+// returning the name of the failing check along with its error. The failures of the checks
+// ready at startup that never passed are recorded in the history only.
+func (a *APIServer) runHealthChecks(ctx context.Context) (string, error) {
+	for _, hc := range a.healthChecks {
+		start := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, _healthCheckTimeout)
+		err := hc.check(checkCtx)
+		cancel()
+
+		result := HealthCheckResult{Time: start, Duration: time.Since(start), Healthy: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		a.healthHistory.Record(hc.name, result)
+
+		if hc.failing(err) {
+			return hc.name, err
+		}
+	}
+	return "", nil
+}
+
+// healthCheckCache remembers the last health check result for a short time, so probes
+// arriving from many nodes at once don't all hit the dependencies. Each run of the checks
+// gets the next number of runs, handed out with its result so that readiness can tell a
+// new result from a cached one.
+type healthCheckCache struct {
+	mu     sync.Mutex
+	name   string
+	err    error
+	run    uint64
+	expiry time.Time
+	runs   atomic.Uint64
+}
+
+// checkHealth runs the health checks, reusing the last result within Config.HealthCheckCacheTTL.
+// Concurrent callers wait for the run in progress instead of starting their own. It returns
+// the number of the run that produced the result along with it.
+func (a *APIServer) checkHealth(ctx context.Context) (string, uint64, error) {
+	c := &a.healthCache
+	ttl := a.Config.HealthCheckCacheTTL
+	if ttl <= 0 {
+		name, err := a.runHealthChecks(ctx)
+		return name, c.runs.Add(1), err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.expiry) {
+		return c.name, c.run, c.err
+	}
+
+	name, err := a.runHealthChecks(ctx)
+	run := c.runs.Add(1)
+	if ctx.Err() != nil {
+		// The caller went away; its result says nothing about the dependencies.
+		return name, run, err
+	}
+
+	c.name, c.err, c.run, c.expiry = name, err, run, time.Now().Add(ttl)
+	return name, run, err
+}
+
+// readinessDebounce keeps a failing health check from flipping readiness until it has been
+// failing for the whole Config.ReadinessDebounce window, so transient blips don't make the
+// load balancer thrash. Once readiness failed, it only recovers after
+// Config.ReadinessRecoverySuccesses consecutive healthy checks, so a dependency coming back
+// for a moment doesn't put the pod back into rotation. A success counts once per run of the
+// checks, however many probes get the cached result. The shutdown flip doesn't go through it.
+type readinessDebounce struct {
+	mu             sync.Mutex
+	unhealthySince time.Time
+	failing        bool
+	successes      int
+	lastRun        uint64
+}
+
+// debounce reports whether the result of the given run of the health checks should make
+// the probe fail.
+func (a *APIServer) debounce(err error, run uint64) bool {
+	d := &a.readinessDebounce
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err == nil {
+		d.unhealthySince = time.Time{}
+		if !d.failing {
+			return false
+		}
+		if run != d.lastRun {
+			d.lastRun = run
+			d.successes++
+		}
+		if d.successes < a.Config.ReadinessRecoverySuccesses {
+			return true
+		}
+		d.failing, d.successes = false, 0
+		return false
+	}
+
+	d.successes = 0
+	if d.unhealthySince.IsZero() {
+		d.unhealthySince = time.Now()
+	}
+	if time.Since(d.unhealthySince) >= a.Config.ReadinessDebounce {
+		d.failing = true
+	}
+	return d.failing
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheckResult is one run of a health check.
+type HealthCheckResult struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// HealthCheckHistory keeps the last results of every health check, to diagnose flapping probes.
+type HealthCheckHistory struct {
+	size int
+
+	mu    sync.Mutex
+	rings map[string]*healthRing
+}
+
+// healthRing is a fixed-size ring buffer; next is where the following result goes.
+type healthRing struct {
+	results []HealthCheckResult
+	next    int
+	full    bool
+}
+
+// NewHealthCheckHistory keeps up to size results per check.
+func NewHealthCheckHistory(size int) *HealthCheckHistory {
+	return &HealthCheckHistory{
+		size:  max(size, 1),
+		rings: make(map[string]*healthRing),
+	}
+}
+
+// Record adds a result for the check name, overwriting the oldest one once the ring is full.
+func (h *HealthCheckHistory) Record(name string, result HealthCheckResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, ok := h.rings[name]
+	if !ok {
+		ring = &healthRing{results: make([]HealthCheckResult, h.size)}
+		h.rings[name] = ring
+	}
+
+	ring.results[ring.next] = result
+	ring.next = (ring.next + 1) % h.size
+	if ring.next == 0 {
+		ring.full = true
+	}
+}
+
+// Results returns the results of every check, most recent first.
+func (h *HealthCheckHistory) Results() map[string][]HealthCheckResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make(map[string][]HealthCheckResult, len(h.rings))
+	for name, ring := range h.rings {
+		n := ring.next
+		if ring.full {
+			n = h.size
+		}
+
+		results := make([]HealthCheckResult, 0, n)
+		for i := range n {
+			results = append(results, ring.results[(ring.next-1-i+h.size)%h.size])
+		}
+		out[name] = results
+	}
+	return out
+}
+
+func (a *APIServer) handleGetHealthHistory(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJSON(w, http.StatusOK, a.healthHistory.Results())
+}
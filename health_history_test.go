@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckHistoryWraps(t *testing.T) {
+	h := NewHealthCheckHistory(3)
+	start := time.Now()
+	for i := range 5 {
+		h.Record("db", HealthCheckResult{Time: start.Add(time.Duration(i) * time.Second), Error: fmt.Sprint(i)})
+	}
+
+	got := h.Results()["db"]
+	if len(got) != 3 {
+		t.Fatalf("kept %d results, want 3", len(got))
+	}
+	for i, want := range []string{"4", "3", "2"} {
+		if got[i].Error != want {
+			t.Fatalf("results = %+v, want the last three, most recent first", got)
+		}
+	}
+}
+
+func TestHealthHistoryEndpointMostRecentFirst(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	start := time.Now()
+	a.healthHistory.Record("redis", HealthCheckResult{Time: start, Healthy: true})
+	a.healthHistory.Record("redis", HealthCheckResult{Time: start.Add(time.Second), Error: "connection refused"})
+
+	rec := httptest.NewRecorder()
+	if err := a.handleGetHealthHistory(rec, httptest.NewRequest(http.MethodGet, "/admin/health-history", nil)); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string][]HealthCheckResult
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	results := got["redis"]
+	if len(results) != 2 || results[0].Error != "connection refused" || !results[1].Healthy {
+		t.Fatalf("redis history = %+v, want the failure before the earlier success", results)
+	}
+	if !results[0].Time.After(results[1].Time) {
+		t.Fatalf("history not in reverse-chronological order: %+v", results)
+	}
+}
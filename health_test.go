@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthChecksCachedWithinTTL(t *testing.T) {
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "100ms")
+	a := newUnstartedTestServer(t)
+
+	var calls atomic.Int32
+	a.RegisterHealthCheck("dependency", func(context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond) // probes pile up while it runs
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.checkHealth(context.Background())
+		}()
+	}
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("dependency checked %d times within the TTL, want 1", got)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	a.checkHealth(context.Background())
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("dependency checked %d times after the TTL, want 2", got)
+	}
+}
+
+// readiness returns the status of the readiness probe of a warmed up a.
+func readiness(a *testAPIServer) int {
+	a.warmedUp.Store(true)
+	return a.serve(httptest.NewRequest(http.MethodGet, "/healthz", nil)).Code
+}
+
+func TestReadinessDebouncesHealthCheckBlips(t *testing.T) {
+	t.Setenv("GSD_READINESS_DEBOUNCE", "100ms")
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "0s")
+	a := newUnstartedTestServer(t)
+
+	var failing atomic.Bool
+	a.RegisterHealthCheck("dependency", func(context.Context) error {
+		if failing.Load() {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+
+	// A blip shorter than the window doesn't flip readiness
+	failing.Store(true)
+	if code := readiness(a); code != http.StatusOK {
+		t.Fatalf("readiness during a blip = %d, want 200", code)
+	}
+	failing.Store(false)
+	if code := readiness(a); code != http.StatusOK {
+		t.Fatalf("readiness after a blip = %d, want 200", code)
+	}
+
+	// A sustained failure does
+	failing.Store(true)
+	readiness(a)
+	time.Sleep(150 * time.Millisecond)
+	if code := readiness(a); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness after a sustained failure = %d, want 503", code)
+	}
+}
+
+func TestShutdownBypassesReadinessDebounce(t *testing.T) {
+	t.Setenv("GSD_READINESS_DEBOUNCE", "1m")
+	a := newUnstartedTestServer(t)
+
+	a.InitiateShutdown()
+	if code := readiness(a); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness once shutting down = %d, want 503 right away", code)
+	}
+}
+
+func TestReadinessRecoversAfterConsecutiveSuccesses(t *testing.T) {
+	t.Setenv("GSD_READINESS_RECOVERY_SUCCESSES", "3")
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "0s")
+	a := newUnstartedTestServer(t)
+
+	var failing atomic.Bool
+	a.RegisterHealthCheck("dependency", func(context.Context) error {
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	failing.Store(true)
+	if code := readiness(a); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness while failing = %d, want 503", code)
+	}
+
+	// Two successes, then a failure: the count starts over
+	failing.Store(false)
+	for i := range 2 {
+		if code := readiness(a); code != http.StatusServiceUnavailable {
+			t.Fatalf("readiness after %d healthy checks = %d, want 503", i+1, code)
+		}
+	}
+	failing.Store(true)
+	readiness(a)
+	failing.Store(false)
+
+	for i := range 2 {
+		if code := readiness(a); code != http.StatusServiceUnavailable {
+			t.Fatalf("readiness after %d healthy checks in a row = %d, want 503", i+1, code)
+		}
+	}
+	if code := readiness(a); code != http.StatusOK {
+		t.Fatalf("readiness after 3 healthy checks in a row = %d, want 200", code)
+	}
+}
+
+func TestReadinessRecoveryCountsHealthCheckRunsNotProbes(t *testing.T) {
+	t.Setenv("GSD_READINESS_RECOVERY_SUCCESSES", "2")
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "100ms")
+	a := newUnstartedTestServer(t)
+
+	var failing atomic.Bool
+	a.RegisterHealthCheck("dependency", func(context.Context) error {
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	failing.Store(true)
+	if code := readiness(a); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness while failing = %d, want 503", code)
+	}
+
+	// Probes within one TTL get the result of a single run: one healthy check
+	time.Sleep(100 * time.Millisecond)
+	failing.Store(false)
+	for i := range 5 {
+		if code := readiness(a); code != http.StatusServiceUnavailable {
+			t.Fatalf("readiness of probe %d on one healthy check = %d, want 503", i+1, code)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if code := readiness(a); code != http.StatusOK {
+		t.Fatalf("readiness after 2 healthy checks in a row = %d, want 200", code)
+	}
+}
+
+func TestHealthCheckStartupDefault(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		opts  []HealthCheckOption
+		ready bool // while the check fails before its first success
+	}{
+		{name: "not ready by default"},
+		{name: "option", opts: []HealthCheckOption{ReadyUntilFirstSuccess()}, ready: true},
+		{name: "config", env: "dependency", ready: true},
+		{name: "config naming another check", env: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "0s")
+			t.Setenv("GSD_HEALTH_CHECKS_READY_AT_STARTUP", tt.env)
+			a := newUnstartedTestServer(t)
+
+			var failing atomic.Bool
+			failing.Store(true)
+			a.RegisterHealthCheck("dependency", func(context.Context) error {
+				if failing.Load() {
+					return errors.New("connection refused")
+				}
+				return nil
+			}, tt.opts...)
+
+			want := http.StatusServiceUnavailable
+			if tt.ready {
+				want = http.StatusOK
+			}
+			if code := readiness(a); code != want {
+				t.Fatalf("readiness before the first success = %d, want %d", code, want)
+			}
+
+			// Once the check passed, its failures count either way
+			failing.Store(false)
+			if code := readiness(a); code != http.StatusOK {
+				t.Fatalf("readiness once passing = %d, want 200", code)
+			}
+			failing.Store(true)
+			if code := readiness(a); code != http.StatusServiceUnavailable {
+				t.Fatalf("readiness failing after the first success = %d, want 503", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InFlightRequest describes a request being served, as listed by /admin/in-flight.
+type InFlightRequest struct {
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Start  time.Time `json:"start"`
+}
+
+// InFlightSnapshot lists the requests in flight, oldest first. Untracked counts the ones that
+// arrived while the registry was full.
+type InFlightSnapshot struct {
+	Requests  []InFlightRequest `json:"requests"`
+	Untracked int64             `json:"untracked"`
+}
+
+// inFlightRegistry keeps the metadata of at most limit requests in flight, so a connection
+// flood during the drain can't grow it: past the limit the requests are only counted.
+type inFlightRegistry struct {
+	mu        sync.Mutex
+	limit     int
+	next      uint64
+	entries   map[uint64]InFlightRequest
+	untracked int64
+}
+
+func newInFlightRegistry(limit int) *inFlightRegistry {
+	return &inFlightRegistry{
+		limit:   limit,
+		entries: make(map[uint64]InFlightRequest, max(limit, 0)),
+	}
+}
+
+// add registers r and returns the id to remove it with; 0 when it was only counted.
+func (reg *inFlightRegistry) add(r *http.Request) uint64 {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if len(reg.entries) >= reg.limit {
+		reg.untracked++
+		return 0
+	}
+	reg.next++
+	reg.entries[reg.next] = InFlightRequest{Method: r.Method, Path: r.URL.Path, Start: time.Now()}
+	return reg.next
+}
+
+func (reg *inFlightRegistry) remove(id uint64) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if id == 0 {
+		reg.untracked--
+		return
+	}
+	delete(reg.entries, id)
+}
+
+func (reg *inFlightRegistry) snapshot() InFlightSnapshot {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	requests := make([]InFlightRequest, 0, len(reg.entries))
+	for _, req := range reg.entries {
+		requests = append(requests, req)
+	}
+	slices.SortFunc(requests, func(a, b InFlightRequest) int {
+		return a.Start.Compare(b.Start)
+	})
+	return InFlightSnapshot{Requests: requests, Untracked: reg.untracked}
+}
+
+func (a *APIServer) handleGetInFlight(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJSON(w, http.StatusOK, a.inFlightRequests.snapshot())
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestInFlightRegistryCapped(t *testing.T) {
+	reg := newInFlightRegistry(2)
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+
+	ids := []uint64{reg.add(req), reg.add(req), reg.add(req), reg.add(req)}
+	snap := reg.snapshot()
+	if len(snap.Requests) != 2 || snap.Untracked != 2 {
+		t.Fatalf("snapshot has %d requests and %d untracked, want 2 and 2", len(snap.Requests), snap.Untracked)
+	}
+
+	for _, id := range ids {
+		reg.remove(id)
+	}
+	if snap := reg.snapshot(); len(snap.Requests) != 0 || snap.Untracked != 0 {
+		t.Fatalf("snapshot after the requests ended = %+v, want it empty", snap)
+	}
+}
+
+// BenchmarkInFlightRegistry keeps n requests in flight at once. The heap-bytes metric stays
+// flat past the registry size, however many requests there are.
+func BenchmarkInFlightRegistry(b *testing.B) {
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	for _, n := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("in_flight=%d", n), func(b *testing.B) {
+			ids := make([]uint64, n)
+			var before, after runtime.MemStats
+			b.ReportAllocs()
+			for b.Loop() {
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				reg := newInFlightRegistry(1000)
+				for i := range ids {
+					ids[i] = reg.add(req)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				b.ReportMetric(float64(after.HeapAlloc)-float64(before.HeapAlloc), "heap-bytes")
+				for _, id := range ids {
+					reg.remove(id)
+				}
+				runtime.KeepAlive(reg)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// InitGraph runs initialization steps in the order their dependencies impose, so the order
+// NewAPIServer initializes things in is declared instead of implied by the code layout.
+// Steps without dependencies between them run in the order they were added.
+type InitGraph struct {
+	steps []initGraphStep
+	order []string
+}
+
+type initGraphStep struct {
+	name string
+	deps []string
+	fn   func() error
+}
+
+func NewInitGraph() *InitGraph {
+	return &InitGraph{}
+}
+
+// Step adds the step name, running fn once the steps in deps ran. Steps are run by Run.
+func (g *InitGraph) Step(name string, deps []string, fn func() error) error {
+	if g.index(name) >= 0 {
+		return fmt.Errorf("init step %q added twice", name)
+	}
+	g.steps = append(g.steps, initGraphStep{name: name, deps: deps, fn: fn})
+	return nil
+}
+
+func (g *InitGraph) index(name string) int {
+	return slices.IndexFunc(g.steps, func(s initGraphStep) bool { return s.name == name })
+}
+
+// Run checks the graph, then runs the steps in topological order, stopping at the first
+// error. Unknown dependencies and cycles are reported before any step runs.
+func (g *InitGraph) Run() error {
+	order, err := g.sort()
+	if err != nil {
+		return err
+	}
+	for _, i := range order {
+		step := g.steps[i]
+		if err := step.fn(); err != nil {
+			return err
+		}
+		g.order = append(g.order, step.name)
+	}
+	return nil
+}
+
+// Order returns the steps run so far, in order.
+func (g *InitGraph) Order() []string {
+	return g.order
+}
+
+// sort returns the indexes of the steps in topological order, the earliest added first
+// among those ready.
+func (g *InitGraph) sort() ([]int, error) {
+	pending := make([]int, len(g.steps)) // unmet dependencies
+	dependents := make([][]int, len(g.steps))
+	for i, step := range g.steps {
+		for _, dep := range step.deps {
+			j := g.index(dep)
+			if j < 0 {
+				return nil, fmt.Errorf("init step %q depends on unknown step %q", step.name, dep)
+			}
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	order := make([]int, 0, len(g.steps))
+	done := make([]bool, len(g.steps))
+	for len(order) < len(g.steps) {
+		next := indexReady(pending, done)
+		if next < 0 {
+			return nil, g.cycleError(done)
+		}
+		done[next] = true
+		order = append(order, next)
+		for _, i := range dependents[next] {
+			pending[i]--
+		}
+	}
+	return order, nil
+}
+
+// indexReady returns the first step not run yet whose dependencies all ran, or -1.
+func indexReady(pending []int, done []bool) int {
+	for i := range pending {
+		if pending[i] == 0 && !done[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (g *InitGraph) cycleError(done []bool) error {
+	var stuck []string
+	for i, step := range g.steps {
+		if !done[i] {
+			stuck = append(stuck, step.name)
+		}
+	}
+	return errors.New("init steps depend on each other in a cycle: " + strings.Join(stuck, ", "))
+}
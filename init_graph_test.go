@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInitGraphRunsInDependencyOrder(t *testing.T) {
+	g := NewInitGraph()
+	var ran []string
+	step := func(name string, deps ...string) {
+		t.Helper()
+		if err := g.Step(name, deps, func() error {
+			ran = append(ran, name)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	step("middleware", "logger", "otel")
+	step("otel", "logger")
+	step("logger")
+	step("auth")
+
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{"logger", "otel", "middleware", "auth"}
+	if !slices.Equal(ran, want) || !slices.Equal(g.Order(), want) {
+		t.Fatalf("ran %v, order %v, want %v", ran, g.Order(), want)
+	}
+}
+
+func TestInitGraphRejectsBadGraphs(t *testing.T) {
+	noop := func() error { return nil }
+	tests := []struct {
+		name  string
+		steps map[string][]string
+		want  string
+	}{
+		{name: "cycle", steps: map[string][]string{"a": {"b"}, "b": {"a"}}, want: "cycle"},
+		{name: "unknown dependency", steps: map[string][]string{"a": {"missing"}}, want: `unknown step "missing"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewInitGraph()
+			var ran bool
+			for name, deps := range tt.steps {
+				_ = g.Step(name, deps, func() error {
+					ran = true
+					return nil
+				})
+			}
+			if err := g.Run(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Run = %v, want an error about %s", err, tt.want)
+			}
+			if ran {
+				t.Fatal("a step ran in an invalid graph")
+			}
+		})
+	}
+
+	g := NewInitGraph()
+	_ = g.Step("a", nil, noop)
+	if err := g.Step("a", nil, noop); err == nil {
+		t.Fatal("Step added the same name twice")
+	}
+}
+
+func TestInitGraphStopsAtFirstError(t *testing.T) {
+	g := NewInitGraph()
+	failure := errors.New("boom")
+	_ = g.Step("a", nil, func() error { return failure })
+	_ = g.Step("b", []string{"a"}, func() error {
+		t.Fatal("b ran after a failed")
+		return nil
+	})
+	if err := g.Run(); !errors.Is(err, failure) {
+		t.Fatalf("Run = %v, want the failure of a", err)
+	}
+}
+
+func TestServerInitOrder(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	entries := a.Logs.FilterMessage("Server initialized").All()
+	if len(entries) != 1 {
+		t.Fatalf("%d init order entries, want 1", len(entries))
+	}
+	var order []string
+	for _, step := range entries[0].ContextMap()["init_order"].([]any) {
+		order = append(order, step.(string))
+	}
+	// Hooks and middlewares are registered once the chaos faults that wrap them are loaded
+	for _, step := range []string{"routes", "dependencies", "rate_limiter", "middleware"} {
+		if slices.Index(order, step) < slices.Index(order, "chaos") {
+			t.Errorf("init order %v runs %s before chaos", order, step)
+		}
+	}
+}
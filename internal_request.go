@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+)
+
+type internalRequestKey struct{}
+
+// withInternalRequest marks ctx as the context of a request the server sends to itself,
+// such as a warmup request. The auth middleware lets those through unauthenticated.
+func withInternalRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalRequestKey{}, true)
+}
+
+// isInternalRequest reports whether ctx belongs to a request the server sent to itself.
+// The mark lives in the context only, so it can't be set from the outside.
+func isInternalRequest(ctx context.Context) bool {
+	internal, _ := ctx.Value(internalRequestKey{}).(bool)
+	return internal
+}
+
+// internalResponse records the response to an internal request.
+type internalResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *internalResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *internalResponse) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *internalResponse) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// serveInternal sends req through the public handler chain in process, marked as internal,
+// and returns the recorded response. It's only valid once Run has built the handler.
+func (a *APIServer) serveInternal(req *http.Request) *internalResponse {
+	ctx := withInternalRequest(contextWithServer(req.Context(), a))
+	req = req.WithContext(ctx)
+	req.RequestURI = req.URL.RequestURI()
+	req.RemoteAddr = "127.0.0.1:0"
+
+	resp := &internalResponse{header: make(http.Header)}
+	a.publicHandler.ServeHTTP(resp, req)
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	return resp
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WriteJSONLines streams items as line-delimited JSON (application/x-ndjson) until the channel
+// is closed, flushing after every line so clients can process the dataset as it comes. It stops
+// when ctx, usually the request context, is done: the producer should select on it too rather
+// than block on a send nobody reads anymore.
+// Errors once the first line is sent wrap ErrPartialWrite, since the status is already out.
+func WriteJSONLines[T any](ctx context.Context, w http.ResponseWriter, items <-chan T) error {
+	flusher, _ := w.(http.Flusher)
+	started := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			if !started {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w: %w", ErrPartialWrite, ctx.Err())
+		case item, ok := <-items:
+			if !ok {
+				if !started {
+					w.Header().Set("Content-Type", "application/x-ndjson")
+					w.WriteHeader(http.StatusOK)
+				}
+				return nil
+			}
+
+			line, err := json.Marshal(item)
+			if err != nil {
+				if !started {
+					return err
+				}
+				return fmt.Errorf("%w: %w", ErrPartialWrite, err)
+			}
+
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				return fmt.Errorf("%w: %w", ErrPartialWrite, err)
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+type jsonlItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	items := make(chan jsonlItem)
+	go func() {
+		defer close(items)
+		for i, name := range []string{"alpha", "beta\nwith a newline", "gamma"} {
+			items <- jsonlItem{ID: i, Name: name}
+		}
+	}()
+
+	rec := httptest.NewRecorder()
+	if err := WriteJSONLines(t.Context(), rec, items); err != nil {
+		t.Fatalf("WriteJSONLines: %v", err)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	if !rec.Flushed {
+		t.Fatal("the lines were not flushed")
+	}
+
+	var got []jsonlItem
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var item jsonlItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("line %q is not a JSON value: %v", scanner.Text(), err)
+		}
+		got = append(got, item)
+	}
+	if len(got) != 3 || got[1].Name != "beta\nwith a newline" {
+		t.Fatalf("items = %+v, want the 3 items sent", got)
+	}
+}
+
+func TestWriteJSONLinesStopsWithTheContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	items := make(chan jsonlItem)
+	go func() {
+		// The client goes away after the first line; the channel is never closed
+		items <- jsonlItem{ID: 1}
+		cancel()
+	}()
+
+	rec := httptest.NewRecorder()
+	err := WriteJSONLines(ctx, rec, items)
+	if !errors.Is(err, ErrPartialWrite) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("WriteJSONLines = %v, want a partial write cancelled", err)
+	}
+	if rec.Body.String() != `{"id":1,"name":""}`+"\n" {
+		t.Fatalf("body = %q, want the first line only", rec.Body.String())
+	}
+}
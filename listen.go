@@ -0,0 +1,26 @@
+package main
+
+import "net"
+
+// listen binds the public listener, with SO_REUSEPORT when Config.ReusePort is set, and
+// applies Config.TCPListenBacklog.
+func (a *APIServer) listen(addr string) (net.Listener, error) {
+	var ln net.Listener
+	var err error
+	if a.Config.ReusePort {
+		ln, err = newReusePortListener(a.Config.Port)
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if backlog := a.Config.TCPListenBacklog; backlog > 0 {
+		if err := setListenBacklog(ln, backlog); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
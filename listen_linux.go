@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// newReusePortListener listens on port with SO_REUSEPORT, so several processes (e.g. the old
+// and the new binary during an upgrade) can accept connections on the same port, the kernel
+// balancing them across the listeners. Linux only, kernel 3.9 or later.
+func newReusePortListener(port int) (net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}); err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+
+	ln, err := lc.Listen(context.Background(), "tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("reuse port listener: %w", err)
+	}
+	return ln, nil
+}
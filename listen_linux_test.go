@@ -0,0 +1,90 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenBacklog returns the backlog of a listening socket, which Linux reports in the
+// tcpi_sacked field of TCP_INFO.
+func listenBacklog(t *testing.T, ln net.Listener) uint32 {
+	t.Helper()
+	raw, err := ln.(*net.TCPListener).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info *unix.TCPInfo
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	return info.Sacked
+}
+
+func TestListenAppliesBacklog(t *testing.T) {
+	t.Setenv("GSD_TCP_LISTEN_BACKLOG", "64")
+	a := newUnstartedTestServer(t)
+
+	ln, err := a.listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	if got := listenBacklog(t, ln); got != 64 {
+		t.Fatalf("listen backlog = %d, want 64", got)
+	}
+}
+
+func TestReusePortListenersShareThePort(t *testing.T) {
+	port := freePort(t)
+	first, err := newReusePortListener(port)
+	if err != nil {
+		t.Fatalf("first listener: %v", err)
+	}
+	defer first.Close()
+	second, err := newReusePortListener(port)
+	if err != nil {
+		t.Fatalf("second listener on the same port: %v", err)
+	}
+	defer second.Close()
+
+	// The kernel balances the connections by hash, so dial until both listeners accepted one
+	accepted := make(chan int, 64)
+	for i, ln := range []net.Listener{first, second} {
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+				accepted <- i
+			}
+		}()
+	}
+
+	seen := map[int]bool{}
+	for range 64 {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		conn.Close()
+		seen[<-accepted] = true
+		if len(seen) == 2 {
+			return
+		}
+	}
+	t.Fatalf("only listener %v accepted connections", seen)
+}
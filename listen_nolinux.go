@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// newReusePortListener is Linux only.
+func newReusePortListener(_ int) (net.Listener, error) {
+	return nil, errors.New("reuse port listener: SO_REUSEPORT is only supported on Linux")
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// setListenBacklog isn't supported outside unix systems, where the listener keeps the OS default.
+func setListenBacklog(_ net.Listener, _ int) error {
+	return errors.New("listen backlog: not supported on this platform")
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// setListenBacklog calls listen(2) again on the bound socket: Linux and the BSDs (macOS
+// included) update the backlog of a socket that is already listening. The kernel still
+// caps it, at net.core.somaxconn on Linux and kern.ipc.somaxconn on macOS.
+func setListenBacklog(ln net.Listener, backlog int) error {
+	tcpLn, ok := ln.(*net.TCPListener)
+	if !ok {
+		return fmt.Errorf("listen backlog: unsupported listener %T", ln)
+	}
+
+	raw, err := tcpLn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("listen backlog: %w", err)
+	}
+
+	var listenErr error
+	if err := raw.Control(func(fd uintptr) {
+		listenErr = syscall.Listen(int(fd), backlog)
+	}); err != nil {
+		return fmt.Errorf("listen backlog: %w", err)
+	}
+	if listenErr != nil {
+		return fmt.Errorf("listen backlog: %w", listenErr)
+	}
+	return nil
+}
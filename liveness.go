@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const _heartbeatInterval = time.Second
+
+// runHeartbeat ticks from its own goroutine until done is closed. A stale heartbeat means the
+// process stopped scheduling work (e.g. a deadlock), which the HTTP layer alone can't detect.
+func (a *APIServer) runHeartbeat(done <-chan struct{}) {
+	ticker := time.NewTicker(_heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		a.lastHeartbeat.Store(time.Now().UnixNano())
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (a *APIServer) handleLiveness(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == http.MethodGet {
+		return a.handleGetLiveness(w, r)
+	}
+
+	return fmt.Errorf("method not allowed: %s", r.Method)
+}
+
+func (a *APIServer) handleGetLiveness(w http.ResponseWriter, _ *http.Request) error {
+	stale := time.Since(time.Unix(0, a.lastHeartbeat.Load()))
+	if stale > a.Config.LivenessStaleThreshold {
+		return APIError{
+			Code:    http.StatusServiceUnavailable,
+			Message: fmt.Sprintf("heartbeat stale for %s", stale.Round(time.Second)),
+		}
+	}
+
+	return WriteJSON(
+		w,
+		http.StatusOK,
+		GetReadinessResponse{
+			Message: "ok",
+		},
+	)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLivenessFailsOnStaleHeartbeat(t *testing.T) {
+	t.Setenv("GSD_LIVENESS_STALE_THRESHOLD", "1s")
+	a := newUnstartedTestServer(t)
+
+	a.lastHeartbeat.Store(time.Now().UnixNano())
+	if rec := a.serve(httptest.NewRequest(http.MethodGet, "/livez", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("liveness with a fresh heartbeat = %d, want 200", rec.Code)
+	}
+
+	// The heartbeat goroutine stalled, as it would behind a deadlock
+	a.lastHeartbeat.Store(time.Now().Add(-2 * time.Second).UnixNano())
+	if rec := a.serve(httptest.NewRequest(http.MethodGet, "/livez", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("liveness with a stale heartbeat = %d, want 503", rec.Code)
+	}
+}
+
+func TestHeartbeatKeepsLivenessFresh(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	done := make(chan struct{})
+	defer close(done)
+
+	go a.runHeartbeat(done)
+	deadline := time.Now().Add(time.Second)
+	for a.lastHeartbeat.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if rec := a.serve(httptest.NewRequest(http.MethodGet, "/livez", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("liveness with the heartbeat running = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// namedLevelCore applies a level of its own to the entries of some named loggers, such as
+// "access" or "recovery", so one middleware can be made more or less verbose without
+// touching the others. The other entries keep the level of the wrapped core.
+type namedLevelCore struct {
+	zapcore.Core
+	levels map[string]zapcore.LevelEnabler
+}
+
+// WithNamedLogLevels sets the level of the named loggers in levels.
+func WithNamedLogLevels(levels map[string]zapcore.LevelEnabler) LoggerOption {
+	return func(cfg *loggerConfig) {
+		if len(levels) == 0 {
+			return
+		}
+		cfg.options = append(cfg.options, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &namedLevelCore{Core: core, levels: levels}
+		}))
+	}
+}
+
+// Enabled lets through whatever the wrapped core or any named level would log; Check decides
+// per entry.
+func (c *namedLevelCore) Enabled(lvl zapcore.Level) bool {
+	if c.Core.Enabled(lvl) {
+		return true
+	}
+	for _, level := range c.levels {
+		if level.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *namedLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &namedLevelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *namedLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	level, ok := c.levels[ent.LoggerName]
+	if !ok {
+		return c.Core.Check(ent, ce)
+	}
+	if level.Enabled(ent.Level) {
+		return ce.AddCore(ent, c.Core)
+	}
+	return ce
+}
+
+// parseNamedLogLevels parses Config.LogLevels. Invalid levels are reported by Config.Validate.
+func parseNamedLogLevels(levels map[string]string) map[string]zapcore.LevelEnabler {
+	parsed := make(map[string]zapcore.LevelEnabler, len(levels))
+	for name, lvl := range levels {
+		if level, err := zapcore.ParseLevel(lvl); err == nil {
+			parsed[name] = level
+		}
+	}
+	return parsed
+}
@@ -2,25 +2,295 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-func NewBaseLogger() (*zap.Logger, error) {
-	cfg := zap.NewProductionConfig()
-	cfg.EncoderConfig.TimeKey = "timestamp"
-	return cfg.Build()
+// Log time formats accepted by Config.LogTimeFormat.
+const (
+	LogTimeISO8601 = "iso8601" // 2006-01-02T15:04:05.000Z0700, for humans
+	LogTimeRFC3339 = "rfc3339" // 2006-01-02T15:04:05Z07:00
+	LogTimeEpoch   = "epoch"   // seconds since the Unix epoch, as a float
+)
+
+var _logTimeEncoders = map[string]zapcore.TimeEncoder{
+	LogTimeISO8601: zapcore.ISO8601TimeEncoder,
+	LogTimeRFC3339: zapcore.RFC3339TimeEncoder,
+	LogTimeEpoch:   zapcore.EpochTimeEncoder,
 }
 
-func WithTrace(ctx context.Context, base *zap.Logger) *zap.Logger {
+// _logStacktraceNone disables the stacktraces in Config.LogStacktraceLevel.
+const _logStacktraceNone = "none"
+
+// Log encodings accepted by Config.LogFormat.
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// _bufferedSinkScheme is the zap sink buffering the writes to the log output, see WithLogOutput.
+const _bufferedSinkScheme = "buffered"
+
+var _registerBufferedSink = sync.OnceValue(func() error {
+	return zap.RegisterSink(_bufferedSinkScheme, newBufferedSink)
+})
+
+// loggerConfig is what NewBaseLogger builds from: the zap config, and the options
+// applied on top of it.
+type loggerConfig struct {
+	zap     zap.Config
+	options []zap.Option
+	// buffered is set when an output goes through the buffered sink.
+	buffered bool
+}
+
+// LoggerOption customizes the production config NewBaseLogger builds from.
+type LoggerOption func(*loggerConfig)
+
+// WithLogTimeFormat encodes the timestamp as iso8601, rfc3339 or epoch.
+// Unknown formats keep the zap default, epoch.
+func WithLogTimeFormat(format string) LoggerOption {
+	return func(cfg *loggerConfig) {
+		if enc, ok := _logTimeEncoders[format]; ok {
+			cfg.zap.EncoderConfig.EncodeTime = enc
+		}
+	}
+}
+
+// WithLogCaller adds the file:line of the log call to every entry. Resolving it costs a
+// runtime.Caller per entry, so hot services may want it off.
+func WithLogCaller(enabled bool) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.zap.DisableCaller = !enabled
+	}
+}
+
+// WithStacktraceLevel attaches a stacktrace to the entries at level or above.
+func WithStacktraceLevel(level zapcore.Level) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.zap.DisableStacktrace = true // replaced by the option below
+		cfg.options = append(cfg.options, zap.AddStacktrace(level))
+	}
+}
+
+// WithoutStacktraces never attaches stacktraces.
+func WithoutStacktraces() LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.zap.DisableStacktrace = true
+	}
+}
+
+// WithLogLevel logs the entries at level or above.
+func WithLogLevel(level zapcore.Level) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.zap.Level = zap.NewAtomicLevelAt(level)
+	}
+}
+
+// WithLogFormat encodes the entries as json or console, the human-readable layout.
+// Unknown formats keep json.
+func WithLogFormat(format string) LoggerOption {
+	return func(cfg *loggerConfig) {
+		if format == LogFormatConsole {
+			cfg.zap.Encoding = LogFormatConsole
+		}
+	}
+}
+
+// WithLogSampling keeps or drops the zap sampling, which logs the first 100 entries of each
+// message every second, then one in 100.
+func WithLogSampling(enabled bool) LoggerOption {
+	return func(cfg *loggerConfig) {
+		if !enabled {
+			cfg.zap.Sampling = nil
+		}
+	}
+}
+
+// WithLogOutput writes the entries to the file at path instead of stderr, if path isn't
+// empty. With a positive bufferSize, the writes are buffered up to that many bytes and
+// flushed every 30 seconds and on Sync: the entries of a crash may then be lost.
+func WithLogOutput(path string, bufferSize int) LoggerOption {
+	return func(cfg *loggerConfig) {
+		if path != "" {
+			cfg.zap.OutputPaths = []string{path}
+		}
+		if bufferSize <= 0 {
+			return
+		}
+		for i, out := range cfg.zap.OutputPaths {
+			target := url.URL{Scheme: _bufferedSinkScheme, Opaque: out}
+			target.RawQuery = url.Values{"size": {strconv.Itoa(bufferSize)}}.Encode()
+			cfg.zap.OutputPaths[i] = target.String()
+		}
+		cfg.buffered = true
+	}
+}
+
+// bufferedSink buffers the writes to a zap output.
+type bufferedSink struct {
+	*zapcore.BufferedWriteSyncer
+	close func()
+}
+
+// newBufferedSink opens the output of a "buffered:<output>?size=<bytes>" URL.
+func newBufferedSink(u *url.URL) (zap.Sink, error) {
+	size, err := strconv.Atoi(u.Query().Get("size"))
+	if err != nil {
+		return nil, fmt.Errorf("buffered log output: size: %w", err)
+	}
+	// Paths parse as the URL path, stderr and stdout as its opaque part
+	output := u.Opaque
+	if output == "" {
+		output = u.Path
+	}
+	out, closeOut, err := zap.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedSink{
+		BufferedWriteSyncer: &zapcore.BufferedWriteSyncer{WS: out, Size: size},
+		close:               closeOut,
+	}, nil
+}
+
+func (s *bufferedSink) Close() error {
+	err := s.Stop()
+	s.close()
+	return err
+}
+
+// NewLogger builds the logger described by the logging settings of config, customized
+// further by opts.
+func NewLogger(config Config, opts ...LoggerOption) (*zap.Logger, error) {
+	cfg := loggerConfig{zap: zap.NewProductionConfig()}
+	cfg.zap.EncoderConfig.TimeKey = "timestamp"
+	for _, opt := range append(loggerOptions(config), opts...) {
+		opt(&cfg)
+	}
+	if cfg.buffered {
+		if err := _registerBufferedSink(); err != nil {
+			return nil, err
+		}
+	}
+	return cfg.zap.Build(cfg.options...)
+}
+
+// NewBaseLogger builds a logger from the zap production settings, customized by opts.
+func NewBaseLogger(opts ...LoggerOption) (*zap.Logger, error) {
+	return NewLogger(Config{LogCaller: true, LogSampling: true}, opts...)
+}
+
+// loggerOptions translates the logging settings of config. The stacktrace level defaults to
+// error in production and to the zap default elsewhere; the level to info.
+func loggerOptions(config Config) []LoggerOption {
+	opts := []LoggerOption{
+		WithLogTimeFormat(config.LogTimeFormat),
+		WithLogCaller(config.LogCaller),
+		WithLogFormat(config.LogFormat),
+		WithLogSampling(config.LogSampling),
+		WithLogOutput(config.LogFilePath, config.LogBufferSize),
+		WithNamedLogLevels(parseNamedLogLevels(config.LogLevels)),
+	}
+	if config.LogLevel != "" {
+		// Checked by Config.Validate
+		if level, err := zapcore.ParseLevel(config.LogLevel); err == nil {
+			opts = append(opts, WithLogLevel(level))
+		}
+	}
+	if config.EnablePIIRedaction {
+		opts = append(opts, WithPIIRedaction())
+	}
+
+	switch config.LogStacktraceLevel {
+	case _logStacktraceNone:
+		opts = append(opts, WithoutStacktraces())
+	case "":
+		// Elsewhere the zap config decides, as before the setting existed
+		if config.IsProduction() {
+			opts = append(opts, WithStacktraceLevel(zapcore.ErrorLevel))
+		}
+	default:
+		// Checked by Config.Validate
+		level, _ := zapcore.ParseLevel(config.LogStacktraceLevel)
+		opts = append(opts, WithStacktraceLevel(level))
+	}
+	return opts
+}
+
+// requestLogLevel is info for the requests whose trace is sampled, and debug for the others,
+// so that at the info level the request logs match the traces that were actually kept.
+// Requests without a span are logged at info.
+func requestLogLevel(ctx context.Context) zapcore.Level {
 	sc := trace.SpanFromContext(ctx).SpanContext()
-	if !sc.IsValid() {
+	if sc.IsValid() && !sc.IsSampled() {
+		return zapcore.DebugLevel
+	}
+	return zapcore.InfoLevel
+}
+
+// WithTrace returns the logger of a request: correlated with the active span through its
+// trace and span IDs, and carrying the authenticated subject, redacted, if any.
+func WithTrace(ctx context.Context, base *zap.Logger) *zap.Logger {
+	var fields []zap.Field
+	if sc := trace.SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+		fields = append(fields,
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()),
+		)
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		fields = append(fields, zap.String("subject", principal.RedactedSubject()))
+	}
+
+	if len(fields) == 0 {
 		return base
 	}
+	return base.With(fields...)
+}
+
+// WithSpanAttributes is WithTrace with attrs added as fields, for correlating the entries
+// of a handler with attributes of its span, e.g. db.statement.
+func WithSpanAttributes(ctx context.Context, base *zap.Logger, attrs ...attribute.KeyValue) *zap.Logger {
+	logger := WithTrace(ctx, base)
+	if len(attrs) == 0 {
+		return logger
+	}
+	return logger.With(OTelAttrsToZapFields(attrs)...)
+}
 
-	return base.With(
-		zap.String("trace_id", sc.TraceID().String()),
-		zap.String("span_id", sc.SpanID().String()),
-	)
+// OTelAttrsToZapFields converts attrs to zap fields of the same key and type.
+func OTelAttrsToZapFields(attrs []attribute.KeyValue) []zap.Field {
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, kv := range attrs {
+		key := string(kv.Key)
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			fields = append(fields, zap.Bool(key, kv.Value.AsBool()))
+		case attribute.INT64:
+			fields = append(fields, zap.Int64(key, kv.Value.AsInt64()))
+		case attribute.FLOAT64:
+			fields = append(fields, zap.Float64(key, kv.Value.AsFloat64()))
+		case attribute.STRING:
+			fields = append(fields, zap.String(key, kv.Value.AsString()))
+		case attribute.BOOLSLICE:
+			fields = append(fields, zap.Bools(key, kv.Value.AsBoolSlice()))
+		case attribute.INT64SLICE:
+			fields = append(fields, zap.Int64s(key, kv.Value.AsInt64Slice()))
+		case attribute.FLOAT64SLICE:
+			fields = append(fields, zap.Float64s(key, kv.Value.AsFloat64Slice()))
+		case attribute.STRINGSLICE:
+			fields = append(fields, zap.Strings(key, kv.Value.AsStringSlice()))
+		default:
+			fields = append(fields, zap.String(key, kv.Value.Emit()))
+		}
+	}
+	return fields
 }
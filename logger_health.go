@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// _loggerSyncTimeout bounds the flush of the logger health check.
+const _loggerSyncTimeout = time.Second
+
+// LoggerHealthCheck returns a health check flushing logger, so a log output that stopped
+// accepting writes (a full disk, a stuck pipe) is noticed while serving instead of blocking
+// the final flush at shutdown. Give it the logger of the file or stderr output only: a core
+// teed to OTel would flush the exporter too.
+//
+// The flush can't be interrupted: on timeout it is left running and the check fails, and the
+// next checks wait for that same flush rather than piling up goroutines on a stuck output.
+func LoggerHealthCheck(logger *zap.Logger) HealthCheck {
+	var (
+		mu      sync.Mutex
+		pending *loggerSync
+	)
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, _loggerSyncTimeout)
+		defer cancel()
+
+		mu.Lock()
+		if pending == nil {
+			pending = &loggerSync{done: make(chan struct{})}
+			go pending.run(logger)
+		}
+		s := pending
+		mu.Unlock()
+
+		select {
+		case <-s.done:
+			mu.Lock()
+			if pending == s {
+				pending = nil
+			}
+			mu.Unlock()
+			if s.err != nil && !isUnsyncable(s.err) {
+				return fmt.Errorf("logger sync: %w", s.err)
+			}
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("logger sync: %w", ctx.Err())
+		}
+	}
+}
+
+// loggerSync is a flush of the logger, shared by the checks running while it lasts.
+type loggerSync struct {
+	done chan struct{}
+	err  error // set before done is closed
+}
+
+func (s *loggerSync) run(logger *zap.Logger) {
+	s.err = logger.Sync()
+	close(s.done)
+}
+
+// isUnsyncable reports whether err comes from an output that doesn't support fsync, such as
+// stdout attached to a terminal or a pipe. Those are healthy.
+func isUnsyncable(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY)
+}
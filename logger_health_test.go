@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// brokenSyncer accepts the writes and fails or blocks on Sync.
+type brokenSyncer struct {
+	err     error
+	release chan struct{} // when not nil, Sync blocks until it is closed
+	syncs   atomic.Int32
+}
+
+func (s *brokenSyncer) Write(p []byte) (int, error) { return len(p), nil }
+
+func (s *brokenSyncer) Sync() error {
+	s.syncs.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.err
+}
+
+func loggerTo(ws zapcore.WriteSyncer) *zap.Logger {
+	return zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), ws, zapcore.InfoLevel))
+}
+
+func TestLoggerHealthCheckFailsOnBrokenOutput(t *testing.T) {
+	check := LoggerHealthCheck(loggerTo(&brokenSyncer{err: errors.New("no space left on device")}))
+	if err := check(t.Context()); err == nil {
+		t.Fatal("check passed, want the sync error")
+	}
+
+	if err := LoggerHealthCheck(loggerTo(&brokenSyncer{}))(t.Context()); err != nil {
+		t.Fatalf("check on a healthy output: %v", err)
+	}
+}
+
+func TestLoggerHealthCheckSharesAStuckSync(t *testing.T) {
+	out := &brokenSyncer{release: make(chan struct{})}
+	check := LoggerHealthCheck(loggerTo(out))
+
+	for range 3 {
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		err := check(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("check = %v, want a timeout", err)
+		}
+	}
+	if n := out.syncs.Load(); n != 1 {
+		t.Fatalf("%d syncs started, want the checks to wait for the stuck one", n)
+	}
+
+	close(out.release)
+	if err := check(t.Context()); err != nil {
+		t.Fatalf("check once the output recovered: %v", err)
+	}
+	if err := check(t.Context()); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if n := out.syncs.Load(); n != 2 {
+		t.Fatalf("%d syncs, want a new one once the stuck one finished", n)
+	}
+}
+
+func TestBrokenLogOutputFailsReadiness(t *testing.T) {
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "0s")
+	a := newUnstartedTestServer(t, withBackends(func(b *Backends) {
+		b.Logger = loggerTo(&brokenSyncer{err: errors.New("no space left on device")})
+	}))
+
+	if code := readiness(a); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness = %d, want %d with a broken log output", code, http.StatusServiceUnavailable)
+	}
+}
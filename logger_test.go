@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// logToFile builds the logger of config writing to a file of the test, logs with it through
+// log, and returns the decoded entries.
+func logToFile(t *testing.T, config Config, log func(*zap.Logger), opts ...LoggerOption) []map[string]any {
+	t.Helper()
+
+	config.LogFilePath = filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewLogger(config, opts...)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	log(logger)
+	_ = logger.Sync()
+
+	f, err := os.Open(config.LogFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("entry %q is not JSON: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogTimeFormat(t *testing.T) {
+	for format, pattern := range map[string]string{
+		LogTimeISO8601: `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}(Z|[+-]\d{4})$`,
+		LogTimeRFC3339: `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`,
+	} {
+		t.Run(format, func(t *testing.T) {
+			entries := logToFile(t, Config{LogTimeFormat: format}, func(l *zap.Logger) { l.Info("hello") })
+			ts, _ := entries[0]["timestamp"].(string)
+			if !regexp.MustCompile(pattern).MatchString(ts) {
+				t.Fatalf("timestamp = %q, want %s", ts, format)
+			}
+		})
+	}
+
+	t.Run(LogTimeEpoch, func(t *testing.T) {
+		entries := logToFile(t, Config{LogTimeFormat: LogTimeEpoch}, func(l *zap.Logger) { l.Info("hello") })
+		if ts, ok := entries[0]["timestamp"].(float64); !ok || ts < 1e9 {
+			t.Fatalf("timestamp = %v, want seconds since the epoch", entries[0]["timestamp"])
+		}
+	})
+}
+
+func TestLogCaller(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		entries := logToFile(t, Config{LogCaller: enabled}, func(l *zap.Logger) { l.Info("hello") })
+		caller, ok := entries[0]["caller"].(string)
+		if ok != enabled {
+			t.Fatalf("LogCaller %v: entry caller = %v", enabled, entries[0]["caller"])
+		}
+		if enabled && !strings.Contains(caller, "logger_test.go:") {
+			t.Fatalf("caller = %q, want the line of the log call", caller)
+		}
+	}
+}
+
+func TestLogStacktraceDefaults(t *testing.T) {
+	tests := []struct {
+		env        string
+		level      string
+		stackAtErr bool
+		stackAtWrn bool
+	}{
+		{env: "production", stackAtErr: true},
+		{env: "dev", stackAtErr: true}, // the zap production default
+		{env: "dev", level: "warn", stackAtErr: true, stackAtWrn: true},
+		{env: "production", level: _logStacktraceNone},
+	}
+	for _, tt := range tests {
+		entries := logToFile(t, Config{Env: tt.env, LogStacktraceLevel: tt.level}, func(l *zap.Logger) {
+			l.Warn("degraded")
+			l.Error("failed")
+		})
+		_, stackAtWrn := entries[0]["stacktrace"]
+		_, stackAtErr := entries[1]["stacktrace"]
+		if stackAtWrn != tt.stackAtWrn || stackAtErr != tt.stackAtErr {
+			t.Errorf("env %q, level %q: stacktrace at warn %v, at error %v; want %v, %v",
+				tt.env, tt.level, stackAtWrn, stackAtErr, tt.stackAtWrn, tt.stackAtErr)
+		}
+	}
+}
+
+func TestNewLoggerOptionCombinations(t *testing.T) {
+	const repeated = 300
+	for _, format := range []string{LogFormatJSON, LogFormatConsole} {
+		for _, sampling := range []bool{true, false} {
+			for _, redaction := range []bool{true, false} {
+				for _, bufferSize := range []int{0, 64 << 10} {
+					config := Config{
+						LogLevel:           "warn",
+						LogFormat:          format,
+						LogFilePath:        filepath.Join(t.TempDir(), "app.log"),
+						LogBufferSize:      bufferSize,
+						LogSampling:        sampling,
+						EnablePIIRedaction: redaction,
+					}
+					name := fmt.Sprintf("%s/sampling=%v/redaction=%v/buffer=%d", format, sampling, redaction, bufferSize)
+					t.Run(name, func(t *testing.T) {
+						testLoggerConfig(t, config, repeated)
+					})
+				}
+			}
+		}
+	}
+}
+
+// testLoggerConfig checks the logger of config against each of its logging settings.
+func testLoggerConfig(t *testing.T, config Config, repeated int) {
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	readLines := func() []string {
+		data, err := os.ReadFile(config.LogFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.FieldsFunc(string(data), func(r rune) bool { return r == '\n' })
+	}
+
+	logger.Warn("first")
+	if written := len(readLines()) > 0; written == (config.LogBufferSize > 0) {
+		t.Fatalf("entry written before the flush = %v with buffer size %d", written, config.LogBufferSize)
+	}
+	logger.Info("below the level")
+	for range repeated {
+		logger.Warn("repeated", zap.String("password", "hunter2"))
+	}
+	_ = logger.Sync()
+
+	lines := readLines()
+	var count int
+	for _, line := range lines {
+		if strings.Contains(line, "below the level") {
+			t.Fatalf("info entry logged at the warn level: %s", line)
+		}
+		if isJSON := json.Valid([]byte(line)); isJSON != (config.LogFormat == LogFormatJSON) {
+			t.Fatalf("entry %q in the wrong format for %s", line, config.LogFormat)
+		}
+		if !strings.Contains(line, "repeated") {
+			continue
+		}
+		count++
+		if redacted := !strings.Contains(line, "hunter2"); redacted != config.EnablePIIRedaction {
+			t.Fatalf("entry %q with redaction %v", line, config.EnablePIIRedaction)
+		}
+	}
+	// Sampling keeps the first 100 of each second: 200 at most if the loop straddles two
+	if sampled := count < repeated; sampled != config.LogSampling {
+		t.Fatalf("%d of %d repeated entries logged with sampling %v", count, repeated, config.LogSampling)
+	}
+}
+
+func TestWithSpanAttributes(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(t.Context(), "query")
+	defer span.End()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := WithSpanAttributes(ctx, zap.New(core),
+		attribute.String("db.statement", "SELECT 1"),
+		attribute.Int("db.rows", 3),
+		attribute.Bool("db.cached", false),
+	)
+	logger.Info("query done")
+
+	fields := logs.All()[0].ContextMap()
+	sc := span.SpanContext()
+	want := map[string]any{
+		"trace_id":     sc.TraceID().String(),
+		"span_id":      sc.SpanID().String(),
+		"db.statement": "SELECT 1",
+		"db.rows":      int64(3),
+		"db.cached":    false,
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("field %s = %#v, want %#v", key, fields[key], value)
+		}
+	}
+}
+
+func TestOTelAttrsToZapFieldsKeepsTypes(t *testing.T) {
+	fields := OTelAttrsToZapFields([]attribute.KeyValue{
+		attribute.Float64("ratio", 0.5),
+		attribute.StringSlice("tags", []string{"a", "b"}),
+	})
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	if enc.Fields["ratio"] != 0.5 {
+		t.Fatalf("ratio = %#v, want 0.5", enc.Fields["ratio"])
+	}
+	if tags, ok := enc.Fields["tags"].([]any); !ok || len(tags) != 2 || tags[0] != "a" {
+		t.Fatalf("tags = %#v, want [a b]", enc.Fields["tags"])
+	}
+}
@@ -2,8 +2,7 @@ package main
 
 import (
 	"context"
-	"net/http"
-	"os/signal"
+	"os"
 	"syscall"
 	"time"
 
@@ -24,44 +23,49 @@ func main() {
 
 	logger := app.Logger
 
-	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM) // It returns a context that is canceled when one of the specified signals is received
-	defer stop()
-
-	// By creating a separate context for the api server, we can control their lifecycle during shutdown
-	ongoingCtx, stopOngoingGracefully := context.WithCancel(context.Background())
-
-	go func() {
-		logger.Info("Starting API server", zap.Int("port", app.Config.Port))
-		if err := app.Run(ongoingCtx); err != nil && err != http.ErrServerClosed {
+	// ./app openapi > spec.yaml prints the OpenAPI document of the registered routes
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		if err := WriteOpenAPI(os.Stdout, app.OpenAPI()); err != nil {
 			panic(err)
 		}
-	}()
-
-	<-rootCtx.Done() // Block until a signal is received
-	stop()           // Stop receiving any more signals
-
-	app.InitiateShutdown() // Mark the server as shutting down
-	logger.Info("Receiving shutdown signal, shutting down.")
-
-	time.Sleep(_readinessDrainDelay) // Give time for readiness check to propagate
-	logger.Info("Readiness check propagated, now waiting for ongoing requests to finish.")
-
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), _shutdownPeriod)
-	defer cancel()
-
-	err = app.Shutdown(shutdownCtx)
-	if err != nil {
-		logger.Error("Failed to wait for ongoing requests to finish, waiting for forced cancellation")
+		return
 	}
-	stopOngoingGracefully() // Cancel ongoing requests context
 
-	// Shutdown application resources
-	err = app.ShutdownResources(shutdownCtx)
+	rootCtx, stop := app.SignalContext(context.Background(), syscall.SIGINT, syscall.SIGTERM) // It returns a context that is canceled when one of the specified signals is received
+	defer stop()
+	context.AfterFunc(rootCtx, stop) // Stop receiving any more signals once the first one arrives
+
+	runner := NewRunner(WithChaos(app), logger)
+	runner.Tracer = app.Tracer()
+	runner.RecordShutdown = app.RecordShutdownDuration
+	runner.DryRun = app.Config.ShutdownDryRun
+	runner.DryRunTimeout = app.Config.ShutdownDryRunTimeout
+	runner.GoroutineThreshold = app.Config.ShutdownGoroutineThreshold
+	runner.StrictGoroutines = app.Config.ShutdownStrict
+	runner.Signals = func(parent context.Context) (context.Context, context.CancelFunc) {
+		return app.SignalContext(parent, syscall.SIGINT, syscall.SIGTERM)
+	}
+	runner.DrainStrategy, err = NewDrainStrategy(app.Config.DrainStrategy)
 	if err != nil {
-		logger.Error("Failed to shut down api server resources", zap.Error(err))
+		panic(err)
+	}
+	if app.Config.DeregisterWebhookURL != "" {
+		deregistrar := NewWebhookDeregistrar(app.Config.DeregisterWebhookURL)
+		deregistrar.Client = app.OutboundClient(app.Config.DeregisterWebhookURL)
+		runner.RegisterDeregistrar(deregistrar)
 	}
 
-	time.Sleep(_shutdownHardPeriod)
+	logger.Info("Starting API server", zap.Int("port", app.Config.Port))
+	report := runner.Run(rootCtx)
 
 	logger.Info("Server shut down gracefully.")
+	app.shutdownComplete()
+
+	// The machine-readable summary is the last line logged, flushed right before exit
+	logShutdownSummary(logger, report)
+	_ = app.syncLogger()
+
+	if code := report.ExitCode(app.Config.ShutdownStrict); code != 0 {
+		os.Exit(code)
+	}
 }
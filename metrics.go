@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const _instrumentationName = "github.com/AmadorHeE/graceful_shutdown"
+
+// OutcomeMetricsMiddleware counts requests per route by outcome:
+// success (2xx), client_error (4xx), server_error (5xx) or cancelled.
+func OutcomeMetricsMiddleware(meter metric.Meter) func(http.Handler) http.Handler {
+	outcomes, err := meter.Int64Counter(
+		"http.server.request.outcomes",
+		metric.WithDescription("Number of requests by route and outcome."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newStatusRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			outcomes.Add(r.Context(), 1, metric.WithAttributes(
+				attribute.String("http.route", routeOf(r)),
+				attribute.String("outcome", requestOutcome(r, rec.Status())),
+			))
+		})
+	}
+}
+
+func requestOutcome(r *http.Request, status int) string {
+	switch {
+	case r.Context().Err() != nil:
+		return "cancelled"
+	case status >= 500:
+		return "server_error"
+	case status >= 400:
+		return "client_error"
+	default:
+		return "success"
+	}
+}
+
+// RequestSizeMiddleware records the request and response body sizes per method and route.
+// The request size is what the handler actually read, which covers chunked bodies too.
+func RequestSizeMiddleware(meter metric.Meter) func(http.Handler) http.Handler {
+	requestSize, err := meter.Int64Histogram(
+		"http.request.size",
+		metric.WithDescription("Size of the HTTP request bodies."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	responseSize, err := meter.Int64Histogram(
+		"http.response.size",
+		metric.WithDescription("Size of the HTTP response bodies."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			rec := newStatusRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			attrs := metric.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.route", routeOf(r)),
+			)
+			requestSize.Record(r.Context(), body.read, attrs)
+			responseSize.Record(r.Context(), rec.written, attrs)
+		})
+	}
+}
+
+// NewConnectionStateTracker returns an http.Server.ConnState callback keeping the number of
+// connections in the new, active and idle states as OTel up-down counters.
+func NewConnectionStateTracker(meter metric.Meter) func(net.Conn, http.ConnState) {
+	names := map[http.ConnState]string{
+		http.StateNew:    "http.connections.new",
+		http.StateActive: "http.connections.active",
+		http.StateIdle:   "http.connections.idle",
+	}
+
+	gauges := make(map[http.ConnState]metric.Int64UpDownCounter, len(names))
+	for state, name := range names {
+		gauge, err := meter.Int64UpDownCounter(
+			name,
+			metric.WithDescription("Number of HTTP connections in the "+state.String()+" state."),
+			metric.WithUnit("{connection}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+		gauges[state] = gauge
+	}
+
+	var states sync.Map // net.Conn -> http.ConnState
+	return func(conn net.Conn, state http.ConnState) {
+		ctx := context.Background()
+		if prev, ok := states.Load(conn); ok {
+			gauges[prev.(http.ConnState)].Add(ctx, -1)
+		}
+
+		if _, tracked := gauges[state]; !tracked {
+			// Hijacked and closed connections are no longer managed by the server
+			states.Delete(conn)
+			return
+		}
+		states.Store(conn, state)
+		gauges[state].Add(ctx, 1)
+	}
+}
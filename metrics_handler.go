@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+const _metricsPath = "/metrics"
+
+// handleMetrics serves the Prometheus exposition of the meter provider's metrics. The route
+// lives on the public listener so it's drained like any other, and the drain_reject
+// middleware exempts it so the final scrape can still happen during the drain. Scrapes are
+// tracked for the scrape drain strategy.
+func (a *APIServer) handleMetrics(w http.ResponseWriter, r *http.Request) error {
+	a.lastScrape.Store(time.Now().UnixNano())
+	a.metricsHandler.ServeHTTP(w, r)
+	return nil
+}
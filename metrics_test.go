@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// outcomeCounts returns the outcome counter by route and outcome.
+func outcomeCounts(t *testing.T, a *testAPIServer) map[[2]string]int64 {
+	t.Helper()
+
+	sum, ok := a.metric(t, "http.server.request.outcomes").(metricdata.Sum[int64])
+	if !ok {
+		t.Fatal("http.server.request.outcomes is not an int64 sum")
+	}
+	counts := make(map[[2]string]int64)
+	for _, dp := range sum.DataPoints {
+		route, _ := dp.Attributes.Value(attribute.Key("http.route"))
+		outcome, _ := dp.Attributes.Value(attribute.Key("outcome"))
+		counts[[2]string{route.AsString(), outcome.AsString()}] += dp.Value
+	}
+	return counts
+}
+
+func TestOutcomeMetricsByRoute(t *testing.T) {
+	// request_id replaces the request further in, which must not lose the matched route
+	t.Setenv("GSD_MIDDLEWARE", "metrics,request_id")
+	a := newUnstartedTestServer(t)
+	a.Handle("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSON(w, http.StatusOK, r.PathValue("id"))
+	})
+	a.Handle("GET /broken", func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("broken")
+	})
+
+	for _, path := range []string{"/items/1", "/items/2", "/broken", "/missing"} {
+		a.serve(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	got := outcomeCounts(t, a)
+	want := map[[2]string]int64{
+		{"GET /items/{id}", "success"}:  2,
+		{"GET /broken", "server_error"}: 1,
+		{"/", "client_error"}:           1,
+	}
+	for key, n := range want {
+		if got[key] != n {
+			t.Errorf("outcomes%v = %d, want %d (all: %v)", key, got[key], n, got)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("outcomes = %v, want %v", got, want)
+	}
+}
+
+func TestRequestSizeHistogramsByRoute(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	a.Handle("POST /echo", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := io.Copy(w, r.Body)
+		return err
+	})
+
+	a.serve(httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("0123456789")))
+
+	for _, name := range []string{"http.request.size", "http.response.size"} {
+		hist, ok := a.metric(t, name).(metricdata.Histogram[int64])
+		if !ok || len(hist.DataPoints) != 1 {
+			t.Fatalf("%s = %+v, want one int64 histogram data point", name, a.metric(t, name))
+		}
+		dp := hist.DataPoints[0]
+		if route, _ := dp.Attributes.Value("http.route"); route.AsString() != "POST /echo" {
+			t.Errorf("%s route = %q, want POST /echo", name, route.AsString())
+		}
+		if dp.Count != 1 || dp.Sum != 10 {
+			t.Errorf("%s count=%d sum=%d, want one 10-byte body", name, dp.Count, dp.Sum)
+		}
+	}
+}
+
+func TestConnectionStateGauges(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	track := NewConnectionStateTracker(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+	gauge := func(name string) int64 {
+		data, ok := findMetric(t, reader, name)
+		if !ok {
+			return 0
+		}
+		var total int64
+		for _, dp := range data.(metricdata.Sum[int64]).DataPoints {
+			total += dp.Value
+		}
+		return total
+	}
+	assertGauges := func(step string, wantNew, wantActive, wantIdle int64) {
+		t.Helper()
+		got := [3]int64{gauge("http.connections.new"), gauge("http.connections.active"), gauge("http.connections.idle")}
+		if want := [3]int64{wantNew, wantActive, wantIdle}; got != want {
+			t.Errorf("%s: new/active/idle = %v, want %v", step, got, want)
+		}
+	}
+
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	track(conn, http.StateNew)
+	assertGauges("accepted", 1, 0, 0)
+	track(conn, http.StateActive)
+	assertGauges("request", 0, 1, 0)
+	track(conn, http.StateIdle)
+	assertGauges("keep-alive", 0, 0, 1)
+	track(conn, http.StateActive)
+	assertGauges("second request", 0, 1, 0)
+	track(conn, http.StateClosed)
+	assertGauges("closed", 0, 0, 0)
+}
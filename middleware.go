@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// _middlewares maps the names accepted by Config.Middleware to their constructors.
+var _middlewares = map[string]func(a *APIServer) func(http.Handler) http.Handler{
+	"request_id": func(a *APIServer) func(http.Handler) http.Handler {
+		return RequestIDMiddleware
+	},
+	"recovery": func(a *APIServer) func(http.Handler) http.Handler {
+		return RecoveryMiddleware(a.Logger)
+	},
+	"logging": func(a *APIServer) func(http.Handler) http.Handler {
+		cfg := a.Config
+		accessLog := accessLogMiddleware(a.Logger, a.routeLatencies, cfg.AccessLogFollowsSampling, cfg.RejectExpiredRequests, cfg.RequestTimeoutHeader)
+		if a.watchdog == nil {
+			return accessLog
+		}
+		return chainMiddleware(accessLog, WatchdogMiddleware(a.watchdog))
+	},
+	"metrics": func(a *APIServer) func(http.Handler) http.Handler {
+		meter := a.meter()
+		return chainMiddleware(
+			OutcomeMetricsMiddleware(meter),
+			RequestSizeMiddleware(meter),
+		)
+	},
+	"cors": func(a *APIServer) func(http.Handler) http.Handler {
+		return CORSMiddleware(a.Config.CORSAllowedOrigins)
+	},
+	"trailing_slash": func(a *APIServer) func(http.Handler) http.Handler {
+		return trailingSlashMiddleware(a.Config.TrailingSlash, a.Config.TrailingSlashRedirect)
+	},
+	"drain_reject": func(a *APIServer) func(http.Handler) http.Handler {
+		return a.DrainRejectMiddleware("/livez", "/healthz", _metricsPath)
+	},
+	"header_limits": func(a *APIServer) func(http.Handler) http.Handler {
+		return HeaderLimitsMiddleware(a.Config.MaxHeaderCount, a.Config.MaxHeaderValueBytes)
+	},
+	"gzip_request": func(a *APIServer) func(http.Handler) http.Handler {
+		return GzipDecompressionMiddleware()
+	},
+	"compression": func(a *APIServer) func(http.Handler) http.Handler {
+		return CompressionMiddleware(a.Config.BrotliEnabled)
+	},
+	"auth": func(a *APIServer) func(http.Handler) http.Handler {
+		return AuthMiddleware(a.authenticator, a.Logger, "/livez", "/healthz", _metricsPath, _selfTestPath, _chaosHangPath)
+	},
+	"read_only": func(a *APIServer) func(http.Handler) http.Handler {
+		return ReadOnlyMiddleware(a)
+	},
+	"rate_limit": func(a *APIServer) func(http.Handler) http.Handler {
+		return RateLimitMiddleware(a.rateLimiter, a.Logger)
+	},
+	"retry": func(a *APIServer) func(http.Handler) http.Handler {
+		cfg := a.Config
+		return RetryMiddleware(RetryOptions{
+			MaxRetries:       cfg.RetryMaxRetries,
+			BaseDelay:        cfg.RetryBaseDelay,
+			MaxDelay:         cfg.RetryMaxDelay,
+			MaxBodyBytes:     cfg.RetryMaxBodyBytes,
+			MaxResponseBytes: cfg.RetryMaxResponseBytes,
+		})
+	},
+	"tenant": func(a *APIServer) func(http.Handler) http.Handler {
+		cfg := a.Config
+		return TenantMiddleware(cfg.TenantHeader, cfg.TenantFromSubdomain, cfg.TenantAllowlist)
+	},
+}
+
+// Use appends middlewares to the chain wrapping every route.
+// The first middleware registered is the outermost one.
+func (a *APIServer) Use(mw ...func(http.Handler) http.Handler) {
+	a.middlewares = append(a.middlewares, mw...)
+}
+
+// useConfiguredMiddleware assembles the chain declared in Config.Middleware, in order.
+// Names are checked by Config.Validate.
+func (a *APIServer) useConfiguredMiddleware() {
+	for _, name := range a.Config.Middleware {
+		a.Use(_middlewares[name](a))
+		if mw := a.chaosMiddleware(name); mw != nil {
+			a.Use(mw)
+		}
+	}
+}
+
+// chainMiddleware composes mw into a single middleware, the first one being the outermost.
+func chainMiddleware(mw ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		for i := len(mw) - 1; i >= 0; i-- {
+			h = mw[i](h)
+		}
+		return h
+	}
+}
+
+// buildHandler wraps mux with the registered middlewares and the OpenTelemetry instrumentation.
+// A request stays in flight until otelhttp recorded its span and metrics, so the telemetry
+// flush, which waits for the requests in flight, can't miss them.
+func (a *APIServer) buildHandler(mux http.Handler) http.Handler {
+	h := otelhttp.NewHandler(chainMiddleware(a.middlewares...)(mux), "http.server", a.otelHandlerOptions()...)
+	return a.trackInFlight(withRouteHolder(h))
+}
+
+// otelHandlerOptions derives the otelhttp options from the telemetry config, followed by
+// the ones given programmatically with WithOTelHTTPOptions.
+func (a *APIServer) otelHandlerOptions() []otelhttp.Option {
+	cfg := a.Config
+	var opts []otelhttp.Option
+
+	if len(cfg.OTelDisabledPaths) > 0 {
+		opts = append(opts, otelhttp.WithFilter(func(r *http.Request) bool {
+			return !slices.Contains(cfg.OTelDisabledPaths, r.URL.Path)
+		}))
+	}
+
+	if cfg.OTelPublicEndpoint {
+		opts = append(opts, otelhttp.WithPublicEndpoint())
+	}
+
+	switch cfg.OTelSpanNameFormat {
+	case "method":
+		opts = append(opts, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}))
+	case "method_path":
+		opts = append(opts, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}))
+	}
+
+	// The providers are always given explicitly: otelhttp then records into the very ones
+	// ShutdownTelemetry flushes, whatever is installed globally by then
+	opts = append(opts,
+		otelhttp.WithTracerProvider(a.otel.TracerProvider()),
+		otelhttp.WithMeterProvider(a.otel.MeterProvider()),
+		otelhttp.WithPropagators(a.otel.Propagator()),
+	)
+
+	// Body events may carry sensitive payload sizes and are far too chatty for production
+	if cfg.OTelMessageEvents && !cfg.IsProduction() {
+		opts = append(opts, otelhttp.WithMessageEvents(otelhttp.ReadEvents, otelhttp.WriteEvents))
+	}
+
+	return append(opts, a.otelHTTPOptions...)
+}
+
+// routeHolder receives the pattern the mux matched. The middlewares only see the request
+// they were given, not the copies made further in with WithContext, which the mux sets
+// Pattern on; they all share the holder through the context.
+type routeHolder struct {
+	pattern string
+}
+
+type routeHolderKey struct{}
+
+func withRouteHolder(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), routeHolderKey{}, &routeHolder{})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// recordRoute stores the pattern the mux matched for h into the holder of the request.
+func recordRoute(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if holder, ok := r.Context().Value(routeHolderKey{}).(*routeHolder); ok {
+			holder.pattern = r.Pattern
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// routeOf returns the pattern the request matched, once the mux has routed it.
+func routeOf(r *http.Request) string {
+	if holder, ok := r.Context().Value(routeHolderKey{}).(*routeHolder); ok && holder.pattern != "" {
+		return holder.pattern
+	}
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return "unmatched"
+}
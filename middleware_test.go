@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// registerOrderMiddlewares adds middlewares appending their name to the X-Order response
+// header, for the test to see the order they ran in.
+func registerOrderMiddlewares(t *testing.T, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		_middlewares[name] = func(*APIServer) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Add("X-Order", name)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+		t.Cleanup(func() { delete(_middlewares, name) })
+	}
+}
+
+func TestConfiguredMiddlewareChainOrder(t *testing.T) {
+	registerOrderMiddlewares(t, "first", "second", "third")
+	t.Setenv("GSD_MIDDLEWARE", "second,third,first")
+	a := newUnstartedTestServer(t)
+
+	rec := a.serve(httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	want := []string{"second", "third", "first"}
+	if got := rec.Header().Values("X-Order"); !slices.Equal(got, want) {
+		t.Fatalf("middlewares ran in order %v, want %v", got, want)
+	}
+}
+
+func TestUnknownMiddlewareFailsStartup(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "recovery,nope")
+
+	_, err := NewAPIServer()
+	var verr *ConfigValidationError
+	if !errors.As(err, &verr) || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("NewAPIServer = %v, want a validation error naming the unknown middleware", err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var _ Server = (*MockAPIServer)(nil)
+
+// MockAPIServer is a Server that records the calls made to it, so the shutdown
+// sequence can be exercised without a real HTTP server. The optional func fields
+// override the default behaviour of each method.
+type MockAPIServer struct {
+	RunFunc               func(ctx context.Context) error
+	RunDrainHooksFunc     func(ctx context.Context) error
+	ShutdownFunc          func(ctx context.Context) error
+	ShutdownResourcesFunc func(ctx context.Context) error
+	ShutdownTelemetryFunc func(ctx context.Context) error
+
+	InFlight    int64
+	WorkerSpans int
+	Served      int64
+	Probe       *ProbeInfo
+	Scrape      time.Time
+
+	mu       sync.Mutex
+	calls    []string
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func NewMockAPIServer() *MockAPIServer {
+	return &MockAPIServer{
+		stopped: make(chan struct{}),
+	}
+}
+
+// Calls returns the names of the methods called so far, in call order.
+func (m *MockAPIServer) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.calls...)
+}
+
+func (m *MockAPIServer) record(call string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, call)
+}
+
+// Run blocks until Shutdown is called, mirroring http.Server.ListenAndServe.
+func (m *MockAPIServer) Run(ctx context.Context) error {
+	m.record("Run")
+	if m.RunFunc != nil {
+		return m.RunFunc(ctx)
+	}
+
+	<-m.stopped
+	return http.ErrServerClosed
+}
+
+func (m *MockAPIServer) InitiateShutdown() {
+	m.record("InitiateShutdown")
+}
+
+func (m *MockAPIServer) RunDrainHooks(ctx context.Context) error {
+	m.record("RunDrainHooks")
+	if m.RunDrainHooksFunc != nil {
+		return m.RunDrainHooksFunc(ctx)
+	}
+	return nil
+}
+
+func (m *MockAPIServer) Shutdown(ctx context.Context) error {
+	m.record("Shutdown")
+	m.stopOnce.Do(func() { close(m.stopped) })
+	if m.ShutdownFunc != nil {
+		return m.ShutdownFunc(ctx)
+	}
+	return nil
+}
+
+func (m *MockAPIServer) ShutdownResources(ctx context.Context) error {
+	m.record("ShutdownResources")
+	if m.ShutdownResourcesFunc != nil {
+		return m.ShutdownResourcesFunc(ctx)
+	}
+	return nil
+}
+
+func (m *MockAPIServer) ShutdownTelemetry(ctx context.Context) error {
+	m.record("ShutdownTelemetry")
+	if m.ShutdownTelemetryFunc != nil {
+		return m.ShutdownTelemetryFunc(ctx)
+	}
+	return nil
+}
+
+func (m *MockAPIServer) InFlightRequests() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.InFlight
+}
+
+func (m *MockAPIServer) ActiveWorkerSpans() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.WorkerSpans
+}
+
+func (m *MockAPIServer) LastProbe() (ProbeInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Probe == nil {
+		return ProbeInfo{}, false
+	}
+	return *m.Probe, true
+}
+
+func (m *MockAPIServer) LastScrape() (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Scrape, !m.Scrape.IsZero()
+}
+
+func (m *MockAPIServer) RequestsServed() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Served
+}
+
+func (m *MockAPIServer) HookNames() (drain, shutdown []string) {
+	return nil, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+type acceptEntry struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses an Accept style header (Accept, Accept-Encoding, ...) into its
+// values and quality factors. Entries without a q parameter default to 1.
+func parseAccept(header string) []acceptEntry {
+	var entries []acceptEntry
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(k)) != "q" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = parsed
+			}
+		}
+		entries = append(entries, acceptEntry{value: value, q: q})
+	}
+	return entries
+}
+
+// mediaTypeQuality returns the quality the Accept header assigns to mediaType,
+// using the most specific matching range (type/subtype, then type/*, then */*).
+func mediaTypeQuality(accept []acceptEntry, mediaType string) float64 {
+	major, _, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, e := range accept {
+		s := -1
+		switch e.value {
+		case mediaType:
+			s = 2
+		case major + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = e.q, s
+		}
+	}
+	return q
+}
+
+// prefersHTML reports whether the client asked for text/html over application/json,
+// which is what browsers do and API clients don't.
+func prefersHTML(acceptHeader string) bool {
+	accept := parseAccept(acceptHeader)
+	html := mediaTypeQuality(accept, "text/html")
+	return html > 0 && html > mediaTypeQuality(accept, "application/json")
+}
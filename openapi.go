@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const _openAPIVersion = "3.0.3"
+
+var _pathParam = regexp.MustCompile(`\{([^}$.]+)(\.\.\.)?\}`)
+
+// OpenAPI builds a starting-point OpenAPI 3 document from the registered routes. Request and
+// response types are reflected through their JSON shape; errors are documented as APIError.
+func (a *APIServer) OpenAPI() map[string]any {
+	g := openAPIGenerator{components: map[string]any{}}
+	errorRef := g.schemaOf(reflect.TypeOf(APIError{}))
+
+	paths := map[string]map[string]any{}
+	for _, route := range a.routes {
+		if route.Hidden {
+			continue
+		}
+
+		method, path := splitPattern(route.Pattern)
+		op := map[string]any{
+			"responses": map[string]any{
+				"200": g.content("OK", route.Response),
+				"default": map[string]any{
+					"description": "Error",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": errorRef},
+					},
+				},
+			},
+		}
+		if route.Summary != "" {
+			op["summary"] = route.Summary
+		}
+		if route.Request != nil {
+			op["requestBody"] = g.content("", route.Request)
+		}
+		if params := pathParams(path); len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+
+	return map[string]any{
+		"openapi": _openAPIVersion,
+		"info": map[string]any{
+			"title":   _serviceName,
+			"version": _serviceVersion,
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.components},
+	}
+}
+
+// WriteOpenAPI writes the document as indented JSON, which YAML tooling accepts as well.
+func WriteOpenAPI(w io.Writer, doc map[string]any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func (a *APIServer) handleGetOpenAPI(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJSON(w, http.StatusOK, a.OpenAPI())
+}
+
+// splitPattern returns the method and the OpenAPI path of a ServeMux pattern. Patterns
+// without a method match every method and are documented as GET.
+func splitPattern(pattern string) (string, string) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = http.MethodGet, pattern
+	}
+	path = strings.TrimSuffix(path, "{$}")
+	if i := strings.Index(path, "/"); i > 0 {
+		path = path[i:] // drop the host
+	}
+	return method, _pathParam.ReplaceAllString(path, "{$1}")
+}
+
+func pathParams(path string) []map[string]any {
+	var params []map[string]any
+	for _, m := range _pathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	return params
+}
+
+type openAPIGenerator struct {
+	components map[string]any
+}
+
+// content documents a body of the JSON shape of v. A nil v gives an empty schema.
+func (g *openAPIGenerator) content(description string, v any) map[string]any {
+	schema := map[string]any{}
+	if v != nil {
+		schema = g.schemaOf(reflect.TypeOf(v))
+	}
+
+	body := map[string]any{
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+	if description != "" {
+		body["description"] = description
+	}
+	return body
+}
+
+// schemaOf reflects t into a JSON schema. Named structs become components referenced by $ref.
+func (g *openAPIGenerator) schemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := g.components[t.Name()]; !ok {
+			g.components[t.Name()] = map[string]any{} // placeholder for recursive types
+			g.components[t.Name()] = g.structSchema(t)
+		}
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.schemaOf(field.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+type testWidget struct {
+	ID    string   `json:"id"`
+	Price float64  `json:"price"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestOpenAPIDocumentsAnnotatedRoute(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	a.Handle("GET /widgets/{id}", func(http.ResponseWriter, *http.Request) error { return nil },
+		WithSummary("Get a widget"), WithResponseType(testWidget{}))
+	a.Handle("DELETE /widgets/{id}", func(http.ResponseWriter, *http.Request) error { return nil })
+
+	var buf bytes.Buffer
+	if err := WriteOpenAPI(&buf, a.OpenAPI()); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Summary    string `json:"summary"`
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Type string `json:"type"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+
+	get, ok := doc.Paths["/widgets/{id}"]["get"]
+	if !ok {
+		t.Fatalf("paths = %v, want GET /widgets/{id}", doc.Paths)
+	}
+	if get.Summary != "Get a widget" {
+		t.Errorf("summary = %q", get.Summary)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Errorf("parameters = %+v, want the id path parameter", get.Parameters)
+	}
+	if !bytes.Contains(get.Responses["200"], []byte("#/components/schemas/testWidget")) ||
+		!bytes.Contains(get.Responses["default"], []byte("#/components/schemas/APIError")) {
+		t.Errorf("responses = %s", get.Responses)
+	}
+
+	widget := doc.Components.Schemas["testWidget"]
+	if widget.Properties["id"].Type != "string" || widget.Properties["price"].Type != "number" ||
+		widget.Properties["tags"].Type != "array" {
+		t.Errorf("testWidget properties = %+v", widget.Properties)
+	}
+	if len(widget.Required) != 2 {
+		t.Errorf("testWidget required = %v, want id and price", widget.Required)
+	}
+
+	// Unannotated routes are still listed
+	if _, ok := doc.Paths["/widgets/{id}"]["delete"]; !ok {
+		t.Error("the unannotated DELETE route is missing")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+)
+
+// Option customizes the APIServer built by NewAPIServer.
+type Option func(*APIServer)
+
+// WithOTelHTTPOptions appends otelhttp options to the ones derived from the telemetry config.
+// They are applied last, so they win over the config.
+func WithOTelHTTPOptions(opts ...otelhttp.Option) Option {
+	return func(a *APIServer) {
+		a.otelHTTPOptions = append(a.otelHTTPOptions, opts...)
+	}
+}
+
+// WithRandSource replaces the source of the random numbers in [0, 1) used for sampling.
+func WithRandSource(fn func() float64) Option {
+	return func(a *APIServer) {
+		a.randFloat = fn
+	}
+}
+
+// WithSignalContext replaces signal.NotifyContext, e.g. with a function returning an
+// already cancelled context to exercise the shutdown path without sending a signal.
+func WithSignalContext(fn func(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc)) Option {
+	return func(a *APIServer) {
+		a.SignalContext = fn
+	}
+}
+
+// WithStartupContext makes the initialization steps of NewAPIServer stop when ctx is done,
+// on top of the signals and Config.StartupTimeout.
+func WithStartupContext(ctx context.Context) Option {
+	return func(a *APIServer) {
+		a.startupParent = ctx
+	}
+}
+
+// WithAuthenticator verifies the requests going through the auth middleware with auth,
+// instead of the static tokens of Config.AuthTokens.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(a *APIServer) {
+		a.authenticator = auth
+	}
+}
+
+// Backends are the clients the server depends on. NewAPIServer only creates the ones left nil,
+// so tests can pass pre-initialized fakes instead of connecting to real infrastructure.
+// Injected backends belong to the caller: they get no shutdown hook, except Cache whose
+// lifecycle is always managed by the server (see RegisterCache).
+type Backends struct {
+	Logger *zap.Logger
+	OTel   *OTelProvider
+	Cache  Cache
+	// Redis backs the distributed rate limiter; without it one is created from Config.RedisAddr.
+	Redis *redis.Client
+	// DB is health checked by the readiness probe. The server never opens one itself.
+	DB *sql.DB
+}
+
+// InjectBackends makes NewAPIServer use the given backends instead of creating them.
+func InjectBackends(backends Backends) Option {
+	return func(a *APIServer) {
+		a.backends = backends
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// fakeConnector opens connections whose Ping fails with pingErr.
+type fakeConnector struct {
+	pingErr error
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{c}, nil }
+func (c *fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c fakeConn) Ping(context.Context) error        { return c.connector.pingErr }
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+func TestInjectedDBIsHealthChecked(t *testing.T) {
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "0")
+	connector := &fakeConnector{}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	a := newUnstartedTestServer(t, withBackends(func(b *Backends) { b.DB = db }))
+
+	if name, _, err := a.checkHealth(context.Background()); err != nil {
+		t.Fatalf("health check %q failed with a healthy DB: %v", name, err)
+	}
+
+	connector.pingErr = driver.ErrBadConn
+	db.SetMaxIdleConns(0) // the next ping opens a new connection
+	if name, _, _ := a.checkHealth(context.Background()); name != "db" {
+		t.Fatalf("failing check = %q, want db", name)
+	}
+}
+
+func TestInjectedRedisUsedByRateLimiter(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "rate_limit")
+	t.Setenv("GSD_DISTRIBUTED_RATE_LIMIT", "true")
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	defer client.Close()
+
+	a := newUnstartedTestServer(t, withBackends(func(b *Backends) { b.Redis = client }))
+
+	limiter, ok := a.rateLimiter.(*redisRateLimiter)
+	if !ok || limiter.client != client {
+		t.Fatalf("rate limiter = %T, want one using the injected client", a.rateLimiter)
+	}
+	// The injected client belongs to the caller
+	if _, shutdown := a.HookNames(); slices.Contains(shutdown, "redis") {
+		t.Fatalf("shutdown hooks = %v, want no redis hook for an injected client", shutdown)
+	}
+}
+
+func TestDistributedRateLimitRequiresRedis(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "rate_limit")
+	t.Setenv("GSD_DISTRIBUTED_RATE_LIMIT", "true")
+
+	provider, _, _ := newTestOTelProvider()
+	if _, err := NewAPIServer(InjectBackends(Backends{Logger: zap.NewNop(), OTel: provider})); err == nil {
+		t.Fatal("NewAPIServer succeeded without a Redis address nor client")
+	}
+}
+
+func TestInjectedOTelProviderIsNotShutDown(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	if err := a.ShutdownTelemetry(context.Background()); err != nil {
+		t.Fatalf("ShutdownTelemetry: %v", err)
+	}
+
+	// The provider belongs to the caller: it still records spans
+	_, span := a.otel.TracerProvider().Tracer("test").Start(context.Background(), "after shutdown")
+	span.End()
+	if got := len(a.Traces.Ended()); got != 1 {
+		t.Fatalf("spans recorded after the server shutdown = %d, want 1", got)
+	}
+}
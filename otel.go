@@ -5,15 +5,39 @@ import (
 	"errors"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	otelmetric "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceName    = "graceful-shutdown"
+	_serviceVersion = "1.0.0"
+)
+
+// Policies applied by Setup when another component already installed global providers.
+const (
+	OTelGlobalOverwrite = "overwrite" // log a warning and install ours anyway
+	OTelGlobalReuse     = "reuse"     // keep the installed providers and use them
+	OTelGlobalError     = "error"     // refuse to start
+	OTelGlobalLocal     = "local"     // never touch the globals, only instrument our handler chain
+)
+
+// The providers the otel package hands out before anyone installs real ones.
+var (
+	_defaultTracerProvider = otel.GetTracerProvider()
+	_defaultMeterProvider  = otel.GetMeterProvider()
 )
 
 type OTelProvider struct {
@@ -21,6 +45,13 @@ type OTelProvider struct {
 	tracerProvider *trace.TracerProvider
 	meterProvider  *metric.MeterProvider
 
+	// Stats counts the telemetry waiting to be exported.
+	Stats *OTelStats
+	// promRegistry gathers the metrics served on /metrics, when Config.PrometheusEnabled.
+	promRegistry *prometheus.Registry
+
+	reused bool // the globals installed by someone else are used instead of ours
+
 	shutdownFuncs []func(context.Context) error
 }
 
@@ -28,13 +59,19 @@ func NewOTelProvider(ctx context.Context, config Config) (*OTelProvider, error)
 	shutdownFuncs := []func(context.Context) error{}
 
 	propagator := newPropagator()
+	stats := &OTelStats{}
 
-	tracerProvider, err := newTracerProvider(ctx, config)
+	tracerProvider, err := newTracerProvider(ctx, config, stats)
 	if err != nil {
 		return nil, err
 	}
 
-	meterProvider, err := newMeterProvider(ctx, config)
+	var promRegistry *prometheus.Registry
+	if config.PrometheusEnabled {
+		promRegistry = prometheus.NewRegistry()
+	}
+
+	meterProvider, err := newMeterProvider(ctx, config, stats, promRegistry)
 	if err != nil {
 		return nil, err
 	}
@@ -46,15 +83,62 @@ func NewOTelProvider(ctx context.Context, config Config) (*OTelProvider, error)
 		propagator:     propagator,
 		tracerProvider: tracerProvider,
 		meterProvider:  meterProvider,
+		Stats:          stats,
+		promRegistry:   promRegistry,
 		shutdownFuncs:  shutdownFuncs,
 	}, nil
 }
 
-// Initialize OpenTelemetry globally for the process
-func (p *OTelProvider) Setup() {
+// Initialize OpenTelemetry globally for the process. When another component already installed
+// global providers, policy decides whether to overwrite them, reuse them or fail.
+func (p *OTelProvider) Setup(policy string, logger *zap.Logger) error {
+	if policy == OTelGlobalLocal {
+		return nil
+	}
+
+	installed := otel.GetTracerProvider() != _defaultTracerProvider || otel.GetMeterProvider() != _defaultMeterProvider
+	if installed {
+		switch policy {
+		case OTelGlobalReuse:
+			logger.Info("Global OpenTelemetry providers already installed, reusing them")
+			p.reused = true
+			// Ours were never used; the installed ones belong to whoever set them up
+			return p.Shutdown(context.Background())
+		case OTelGlobalError:
+			return errors.New("global OpenTelemetry providers are already installed")
+		default:
+			logger.Warn("Global OpenTelemetry providers already installed, overwriting them; telemetry of the component that installed them will be lost")
+		}
+	}
+
 	otel.SetTextMapPropagator(p.propagator)  // setup propagator.
 	otel.SetTracerProvider(p.tracerProvider) // setup tracer provider.
 	otel.SetMeterProvider(p.meterProvider)   // setup meter provider.
+	return nil
+}
+
+// TracerProvider returns the tracer provider in use: ours, or the reused global one.
+func (p *OTelProvider) TracerProvider() oteltrace.TracerProvider {
+	if p.reused {
+		return otel.GetTracerProvider()
+	}
+	return p.tracerProvider
+}
+
+// MeterProvider returns the meter provider in use: ours, or the reused global one.
+func (p *OTelProvider) MeterProvider() otelmetric.MeterProvider {
+	if p.reused {
+		return otel.GetMeterProvider()
+	}
+	return p.meterProvider
+}
+
+// Propagator returns the propagator in use: ours, or the reused global one.
+func (p *OTelProvider) Propagator() propagation.TextMapPropagator {
+	if p.reused {
+		return otel.GetTextMapPropagator()
+	}
+	return p.propagator
 }
 
 // shutdown calls cleanup functions registered via shoutdownFuncs.
@@ -76,69 +160,88 @@ func newPropagator() propagation.TextMapPropagator {
 	)
 }
 
-func newTracerProvider(ctx context.Context, config Config) (*trace.TracerProvider, error) {
-	exporter, err := otlptracegrpc.New(
+// newResource describes the service identity attached to every span, metric and log record.
+func newResource(ctx context.Context, config Config) (*resource.Resource, error) {
+	return resource.New(
 		ctx,
-		otlptracegrpc.WithEndpoint(config.TracingEndpoint),
-		otlptracegrpc.WithInsecure(),
+		resource.WithAttributes(
+			semconv.ServiceName(_serviceName),
+			semconv.ServiceVersion(_serviceVersion),
+			attribute.String("environment", config.Env),
+		),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
 	)
+}
+
+func newTracerProvider(ctx context.Context, config Config, stats *OTelStats) (*trace.TracerProvider, error) {
+	// Without GSD_TRACING_ENDPOINT the exporter follows the standard OTEL_EXPORTER_OTLP_* variables
+	var opts []otlptracegrpc.Option
+	if config.TracingEndpoint != "" {
+		opts = append(opts,
+			otlptracegrpc.WithEndpoint(config.TracingEndpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Resource = service identity
-	res, err := resource.New(
-		ctx,
-		resource.WithAttributes(
-			semconv.ServiceName("graceful-shutdown"),
-			semconv.ServiceVersion("1.0.0"),
-			attribute.String("environment", config.Env),
-		),
-	)
+	res, err := newResource(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
 	tp := trace.NewTracerProvider(
 		trace.WithResource(res),
-		trace.WithBatcher(exporter),
+		trace.WithSpanProcessor(countingSpanProcessor{
+			SpanProcessor: trace.NewBatchSpanProcessor(statsSpanExporter{SpanExporter: exporter, stats: stats}),
+			stats:         stats,
+			maxQueued:     _otelMaxQueueSize,
+		}),
 		trace.WithSampler(trace.ParentBased(trace.TraceIDRatioBased(0.1))),
 	)
 	return tp, nil
 }
 
-func newMeterProvider(ctx context.Context, config Config) (*metric.MeterProvider, error) {
-	exporter, err := otlpmetricgrpc.New(
-		ctx,
-		otlpmetricgrpc.WithEndpoint(config.MetricsEndpoint),
-		otlpmetricgrpc.WithInsecure(),
-	)
+func newMeterProvider(ctx context.Context, config Config, stats *OTelStats, promRegistry *prometheus.Registry) (*metric.MeterProvider, error) {
+	// Without GSD_METRICS_ENDPOINT the exporter follows the standard OTEL_EXPORTER_OTLP_* variables
+	var opts []otlpmetricgrpc.Option
+	if config.MetricsEndpoint != "" {
+		opts = append(opts,
+			otlpmetricgrpc.WithEndpoint(config.MetricsEndpoint),
+			otlpmetricgrpc.WithInsecure(),
+		)
+	}
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Resource = service identity
-	res, err := resource.New(
-		ctx,
-		resource.WithAttributes(
-			semconv.ServiceName("graceful-shutdown"),
-			semconv.ServiceVersion("1.0.0"),
-			attribute.String("environment", config.Env),
-		),
-	)
+	res, err := newResource(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
 	reader := metric.NewPeriodicReader(
-		exporter,
+		statsMetricExporter{Exporter: exporter, stats: stats},
 		metric.WithInterval(30*time.Second),
 	)
 
-	mp := metric.NewMeterProvider(
+	providerOpts := []metric.Option{
 		metric.WithResource(res),
 		metric.WithReader(reader),
-	)
+	}
+	// The Prometheus exporter is a pull reader, next to the periodic OTLP push
+	if promRegistry != nil {
+		promExporter, err := otelprom.New(otelprom.WithRegisterer(promRegistry))
+		if err != nil {
+			return nil, err
+		}
+		providerOpts = append(providerOpts, metric.WithReader(promExporter))
+	}
+
+	mp := metric.NewMeterProvider(providerOpts...)
 
 	return mp, nil
 
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serveSpans sends req through the handler chain and returns the server spans it produced.
+func serveSpans(t *testing.T, a *testAPIServer, req *http.Request) []sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	a.serve(req)
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range a.Traces.Ended() {
+		if s.SpanKind() == trace.SpanKindServer {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func TestOTelSpanNameFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"operation", "http.server"},
+		{"method", "GET"},
+		{"method_path", "GET /missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			t.Setenv("GSD_OTEL_SPAN_NAME_FORMAT", tt.format)
+			a := newUnstartedTestServer(t)
+
+			spans := serveSpans(t, a, httptest.NewRequest(http.MethodGet, "/missing", nil))
+			if len(spans) != 1 || spans[0].Name() != tt.want {
+				t.Fatalf("server spans = %v, want one named %q", spans, tt.want)
+			}
+		})
+	}
+}
+
+func TestOTelDisabledPaths(t *testing.T) {
+	t.Setenv("GSD_OTEL_DISABLED_PATHS", "/livez")
+	a := newUnstartedTestServer(t)
+
+	if spans := serveSpans(t, a, httptest.NewRequest(http.MethodGet, "/livez", nil)); len(spans) != 0 {
+		t.Fatalf("got %d spans for a disabled path", len(spans))
+	}
+	if spans := serveSpans(t, a, httptest.NewRequest(http.MethodGet, "/missing", nil)); len(spans) != 1 {
+		t.Fatalf("got %d spans for an instrumented path, want 1", len(spans))
+	}
+}
+
+func TestOTelPublicEndpointStartsNewTrace(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	for _, public := range []bool{false, true} {
+		t.Run(map[bool]string{false: "internal", true: "public"}[public], func(t *testing.T) {
+			if public {
+				t.Setenv("GSD_OTEL_PUBLIC_ENDPOINT", "true")
+			}
+			a := newUnstartedTestServer(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+			req.Header.Set("traceparent", traceparent)
+			spans := serveSpans(t, a, req)
+			if len(spans) != 1 {
+				t.Fatalf("got %d server spans, want 1", len(spans))
+			}
+
+			continued := spans[0].Parent().TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736"
+			if continued == public {
+				t.Fatalf("incoming trace continued = %v on a public=%v endpoint", continued, public)
+			}
+			if public && len(spans[0].Links()) != 1 {
+				t.Fatalf("public endpoint span has %d links, want the incoming trace", len(spans[0].Links()))
+			}
+		})
+	}
+}
+
+func TestOTelMessageEventsNeverInProduction(t *testing.T) {
+	for _, env := range []string{"dev", "prod"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv("GSD_ENV", env)
+			t.Setenv("GSD_OTEL_MESSAGE_EVENTS", "true")
+			a := newUnstartedTestServer(t)
+			a.Handle("POST /echo", func(w http.ResponseWriter, r *http.Request) error {
+				_, err := io.Copy(w, r.Body)
+				return err
+			})
+
+			spans := serveSpans(t, a, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("body")))
+			if len(spans) != 1 {
+				t.Fatalf("got %d server spans, want 1", len(spans))
+			}
+			if hasEvents, want := len(spans[0].Events()) > 0, env == "dev"; hasEvents != want {
+				t.Fatalf("message events present = %v, want %v (events: %v)", hasEvents, want, spans[0].Events())
+			}
+		})
+	}
+}
+
+// recordingMetricExporter keeps the exported metrics.
+type recordingMetricExporter struct {
+	mu       sync.Mutex
+	exported []metricdata.ResourceMetrics
+}
+
+func (e *recordingMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (e *recordingMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *recordingMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exported = append(e.exported, *rm)
+	return nil
+}
+
+func (e *recordingMetricExporter) ForceFlush(context.Context) error { return nil }
+func (e *recordingMetricExporter) Shutdown(context.Context) error   { return nil }
+
+// requestCount returns the number of requests in the exported otelhttp duration histograms.
+func (e *recordingMetricExporter) requestCount() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var n uint64
+	for _, rm := range e.exported {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				hist, ok := m.Data.(metricdata.Histogram[float64])
+				if sm.Scope.Name != otelhttp.ScopeName || m.Name != "http.server.request.duration" || !ok {
+					continue
+				}
+				for _, dp := range hist.DataPoints {
+					n += dp.Count
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestOTelHTTPMetricsExportedOnShutdown(t *testing.T) {
+	exporter := &recordingMetricExporter{}
+	// Registered first, so it runs once the server and its telemetry are shut down
+	t.Cleanup(func() {
+		if n := exporter.requestCount(); n != 1 {
+			t.Errorf("otelhttp requests exported at shutdown = %d, want the last request", n)
+		}
+	})
+	a := NewTestAPIServer(t,
+		withBackends(func(b *Backends) {
+			// A reader that never exports on its own: only the shutdown flushes it
+			provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(
+				sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(time.Hour))))
+			b.OTel.meterProvider = provider
+			b.OTel.shutdownFuncs = append(b.OTel.shutdownFuncs, provider.Shutdown)
+		}),
+		ownOTel(),
+		withRoute("GET /orders", func(w http.ResponseWriter, r *http.Request) error {
+			return WriteJSON(w, http.StatusOK, "ok")
+		}),
+	)
+
+	resp, err := http.Get(a.URL + "/orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if n := exporter.requestCount(); n != 0 {
+		t.Fatalf("otelhttp requests exported before shutdown = %d, want none", n)
+	}
+}
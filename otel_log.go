@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap/zapcore"
+)
+
+// BatchedOTelZapCore is a zap core exporting log entries as OTel log records. Entries are
+// buffered and exported in batches of up to batchSize records, at least every flushInterval,
+// so logging never waits on the collector.
+type BatchedOTelZapCore struct {
+	zapcore.LevelEnabler
+
+	provider *sdklog.LoggerProvider
+	logger   otellog.Logger
+	fields   []otellog.KeyValue
+}
+
+// NewBatchedOTelZapCore exports the entries enabled by level through exporter. Shutdown must
+// be called to export the records still buffered.
+func NewBatchedOTelZapCore(exporter sdklog.Exporter, res *resource.Resource, level zapcore.LevelEnabler, batchSize int, flushInterval time.Duration) *BatchedOTelZapCore {
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter,
+			sdklog.WithExportMaxBatchSize(batchSize),
+			sdklog.WithExportInterval(flushInterval),
+		)),
+	)
+
+	return &BatchedOTelZapCore{
+		LevelEnabler: level,
+		provider:     provider,
+		logger:       provider.Logger(_instrumentationName),
+	}
+}
+
+// newOTelLogCore exports the logs over OTLP. Without GSD_LOGS_ENDPOINT the exporter follows
+// the standard OTEL_EXPORTER_OTLP_* variables.
+func newOTelLogCore(ctx context.Context, config Config, level zapcore.LevelEnabler) (*BatchedOTelZapCore, error) {
+	var opts []otlploggrpc.Option
+	if config.LogsEndpoint != "" {
+		opts = append(opts,
+			otlploggrpc.WithEndpoint(config.LogsEndpoint),
+			otlploggrpc.WithInsecure(),
+		)
+	}
+	exporter, err := otlploggrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := newResource(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBatchedOTelZapCore(exporter, res, level, config.OTelLogBatchSize, config.OTelLogFlushInterval), nil
+}
+
+func (c *BatchedOTelZapCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], otelLogAttributes(fields)...)
+	return &clone
+}
+
+func (c *BatchedOTelZapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write only buffers the record; the export happens in the background.
+func (c *BatchedOTelZapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	var record otellog.Record
+	record.SetTimestamp(entry.Time)
+	record.SetObservedTimestamp(time.Now())
+	record.SetSeverity(otelLogSeverity(entry.Level))
+	record.SetSeverityText(entry.Level.CapitalString())
+	record.SetBody(otellog.StringValue(entry.Message))
+	record.AddAttributes(c.fields...)
+	record.AddAttributes(otelLogAttributes(fields)...)
+	if entry.LoggerName != "" {
+		record.AddAttributes(otellog.String("logger", entry.LoggerName))
+	}
+
+	c.logger.Emit(context.Background(), record)
+	return nil
+}
+
+// Sync exports the buffered records.
+func (c *BatchedOTelZapCore) Sync() error {
+	return c.provider.ForceFlush(context.Background())
+}
+
+// Shutdown exports the buffered records and stops the exporter. Entries written afterwards
+// are dropped.
+func (c *BatchedOTelZapCore) Shutdown(ctx context.Context) error {
+	return c.provider.Shutdown(ctx)
+}
+
+func otelLogSeverity(level zapcore.Level) otellog.Severity {
+	switch level {
+	case zapcore.DebugLevel:
+		return otellog.SeverityDebug
+	case zapcore.InfoLevel:
+		return otellog.SeverityInfo
+	case zapcore.WarnLevel:
+		return otellog.SeverityWarn
+	case zapcore.ErrorLevel:
+		return otellog.SeverityError
+	case zapcore.DPanicLevel:
+		return otellog.SeverityFatal1
+	case zapcore.PanicLevel:
+		return otellog.SeverityFatal2
+	case zapcore.FatalLevel:
+		return otellog.SeverityFatal3
+	default:
+		return otellog.SeverityUndefined
+	}
+}
+
+// otelLogAttributes encodes zap fields the way the JSON encoder would, then maps the result
+// onto log attributes.
+func otelLogAttributes(fields []zapcore.Field) []otellog.KeyValue {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	attrs := make([]otellog.KeyValue, 0, len(enc.Fields))
+	for k, v := range enc.Fields {
+		attrs = append(attrs, otellog.KeyValue{Key: k, Value: otelLogValue(v)})
+	}
+	return attrs
+}
+
+func otelLogValue(v any) otellog.Value {
+	switch v := v.(type) {
+	case string:
+		return otellog.StringValue(v)
+	case bool:
+		return otellog.BoolValue(v)
+	case int:
+		return otellog.IntValue(v)
+	case int64:
+		return otellog.Int64Value(v)
+	case int32:
+		return otellog.Int64Value(int64(v))
+	case uint32:
+		return otellog.Int64Value(int64(v))
+	case float64:
+		return otellog.Float64Value(v)
+	case float32:
+		return otellog.Float64Value(float64(v))
+	case []byte:
+		return otellog.BytesValue(v)
+	case time.Time:
+		return otellog.StringValue(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return otellog.StringValue(v.String())
+	case []any:
+		values := make([]otellog.Value, len(v))
+		for i, e := range v {
+			values[i] = otelLogValue(e)
+		}
+		return otellog.SliceValue(values...)
+	case map[string]any:
+		kvs := make([]otellog.KeyValue, 0, len(v))
+		for k, e := range v {
+			kvs = append(kvs, otellog.KeyValue{Key: k, Value: otelLogValue(e)})
+		}
+		return otellog.MapValue(kvs...)
+	default:
+		return otellog.StringValue(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// recordingLogExporter keeps the bodies and the severities of the exported records.
+type recordingLogExporter struct {
+	mu         sync.Mutex
+	bodies     []string
+	severities map[string]otellog.Severity
+}
+
+func (e *recordingLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, record := range records {
+		e.bodies = append(e.bodies, record.Body().AsString())
+		if e.severities == nil {
+			e.severities = make(map[string]otellog.Severity)
+		}
+		e.severities[record.Body().AsString()] = record.Severity()
+	}
+	return nil
+}
+
+func (e *recordingLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *recordingLogExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *recordingLogExporter) exported() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.bodies...)
+}
+
+// severity returns the severity of the last exported record with the given body.
+func (e *recordingLogExporter) severity(body string) (otellog.Severity, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	severity, ok := e.severities[body]
+	return severity, ok
+}
+
+// newBufferingOTelCore never exports on its own within a test: the batch and the interval are
+// both out of reach.
+func newBufferingOTelCore(exporter sdklog.Exporter) *BatchedOTelZapCore {
+	return NewBatchedOTelZapCore(exporter, resource.Empty(), zapcore.InfoLevel, 1000, time.Hour)
+}
+
+func TestBatchedOTelZapCoreShutdownExportsBufferedRecords(t *testing.T) {
+	exporter := &recordingLogExporter{}
+	core := newBufferingOTelCore(exporter)
+	logger := zap.New(core)
+
+	logger.Info("first")
+	logger.Warn("second")
+	logger.Debug("below the level")
+	if got := exporter.exported(); len(got) != 0 {
+		t.Fatalf("records exported before the flush: %v", got)
+	}
+
+	if err := core.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	got := exporter.exported()
+	if len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Fatalf("exported %v, want [first second]", got)
+	}
+
+	logger.Info("after shutdown")
+	if got := exporter.exported(); len(got) != 2 {
+		t.Fatalf("record written after Shutdown was exported: %v", got)
+	}
+}
+
+func TestBatchedOTelZapCoreSyncExportsBufferedRecords(t *testing.T) {
+	exporter := &recordingLogExporter{}
+	core := newBufferingOTelCore(exporter)
+	t.Cleanup(func() { _ = core.Shutdown(context.Background()) })
+
+	zap.New(core).Info("buffered")
+	if err := core.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := exporter.exported(); len(got) != 1 || got[0] != "buffered" {
+		t.Fatalf("exported %v, want [buffered]", got)
+	}
+}
+
+func TestShutdownStatusSeverityFollowsOutcome(t *testing.T) {
+	tests := []struct {
+		name              string
+		shutdownResources func(ctx context.Context) error
+		want              otellog.Severity
+	}{
+		{name: "clean", want: otellog.SeverityInfo},
+		{
+			name:              "failed",
+			shutdownResources: func(context.Context) error { return errors.New("db close failed") },
+			want:              otellog.SeverityError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &recordingLogExporter{}
+			core := newBufferingOTelCore(exporter)
+
+			srv := NewMockAPIServer()
+			started := runUntilStarted(srv)
+			srv.ShutdownResourcesFunc = tt.shutdownResources
+			// the status is logged before the telemetry goes, so flushing there exports it
+			srv.ShutdownTelemetryFunc = core.Shutdown
+
+			runner := newTestRunner(srv)
+			runner.logger = zap.New(core)
+			ctx, cancel := context.WithCancel(t.Context())
+			go func() {
+				<-started
+				cancel()
+			}()
+			runner.Run(ctx)
+
+			got, ok := exporter.severity("Shutdown status")
+			if !ok {
+				t.Fatalf("no shutdown status record exported, got %v", exporter.exported())
+			}
+			if got != tt.want {
+				t.Fatalf("severity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// _otelMaxQueueSize is the default queue size of the batch span processor.
+const _otelMaxQueueSize = trace.DefaultMaxQueueSize
+
+// OTelStats counts what the exporters have yet to send. The SDK doesn't expose the queue of
+// the batch span processor, so its depth is derived: spans ended minus spans exported.
+type OTelStats struct {
+	spansPending  atomic.Int64
+	spansDropped  atomic.Int64
+	pointsPending atomic.Int64
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// OTelStatsResponse is the body of GET /admin/otel-stats.
+type OTelStatsResponse struct {
+	TracerQueueDepth int64  `json:"tracer_queue_depth"`
+	DroppedSpans     int64  `json:"dropped_spans"`
+	MeterQueueDepth  int64  `json:"meter_queue_depth"`
+	LastExportError  string `json:"last_export_error,omitempty"`
+}
+
+// Snapshot returns the current statistics. A nil OTelStats has nothing pending.
+func (s *OTelStats) Snapshot() OTelStatsResponse {
+	if s == nil {
+		return OTelStatsResponse{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := OTelStatsResponse{
+		TracerQueueDepth: s.spansPending.Load(),
+		DroppedSpans:     s.spansDropped.Load(),
+		MeterQueueDepth:  s.pointsPending.Load(),
+	}
+	if s.lastErr != nil {
+		resp.LastExportError = s.lastErr.Error()
+	}
+	return resp
+}
+
+func (s *OTelStats) exported(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastErr = err
+}
+
+// countingSpanProcessor hands the ended spans to a batch processor while fewer than
+// maxQueued are pending, and drops the others. The batch processor then never drops a span
+// on its own, unseen: every drop is counted here, and the pending count can't drift.
+type countingSpanProcessor struct {
+	trace.SpanProcessor
+	stats     *OTelStats
+	maxQueued int64
+}
+
+func (p countingSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	if p.stats.spansPending.Add(1) > p.maxQueued {
+		p.stats.spansPending.Add(-1)
+		p.stats.spansDropped.Add(1)
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// statsSpanExporter counts the spans leaving the queue.
+type statsSpanExporter struct {
+	trace.SpanExporter
+	stats *OTelStats
+}
+
+func (e statsSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.stats.spansPending.Add(-int64(len(spans)))
+	e.stats.exported(err)
+	return err
+}
+
+// statsMetricExporter counts the data points of the export in progress, or of the last one
+// if it failed.
+type statsMetricExporter struct {
+	metric.Exporter
+	stats *OTelStats
+}
+
+func (e statsMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.stats.pointsPending.Store(dataPoints(rm))
+	err := e.Exporter.Export(ctx, rm)
+	if err == nil {
+		e.stats.pointsPending.Store(0)
+	}
+	e.stats.exported(err)
+	return err
+}
+
+func dataPoints(rm *metricdata.ResourceMetrics) int64 {
+	var n int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				n += int64(len(data.DataPoints))
+			case metricdata.Sum[float64]:
+				n += int64(len(data.DataPoints))
+			case metricdata.Gauge[int64]:
+				n += int64(len(data.DataPoints))
+			case metricdata.Gauge[float64]:
+				n += int64(len(data.DataPoints))
+			case metricdata.Histogram[int64]:
+				n += int64(len(data.DataPoints))
+			case metricdata.Histogram[float64]:
+				n += int64(len(data.DataPoints))
+			default:
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func (a *APIServer) handleGetOTelStats(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJSON(w, http.StatusOK, a.otel.Stats.Snapshot())
+}
+
+// otelQueueWarning warns when the span queue grows past Config.OTelQueueWarningThreshold.
+func (a *APIServer) otelQueueWarning(_ context.Context) error {
+	stats := a.otel.Stats.Snapshot()
+	if stats.TracerQueueDepth > int64(a.Config.OTelQueueWarningThreshold) {
+		return fmt.Errorf("span queue depth %d exceeds %d", stats.TracerQueueDepth, a.Config.OTelQueueWarningThreshold)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// gatedExporter blocks every export until released, and records the exported spans.
+type gatedExporter struct {
+	release chan struct{}
+	err     error
+
+	mu       sync.Mutex
+	exported int
+}
+
+func (e *gatedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	select {
+	case <-e.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exported += len(spans)
+	return e.err
+}
+
+func (e *gatedExporter) Shutdown(context.Context) error { return nil }
+
+func TestOTelStatsCountQueuedAndDroppedSpans(t *testing.T) {
+	stats := &OTelStats{}
+	exporter := &gatedExporter{release: make(chan struct{}), err: errors.New("collector unavailable")}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(countingSpanProcessor{
+		SpanProcessor: sdktrace.NewBatchSpanProcessor(statsSpanExporter{SpanExporter: exporter, stats: stats}),
+		stats:         stats,
+		maxQueued:     4,
+	}))
+	defer shutdownNow(tp.Shutdown)
+
+	for range 10 {
+		_, span := tp.Tracer("test").Start(context.Background(), "request")
+		span.End()
+	}
+
+	got := stats.Snapshot()
+	if got.TracerQueueDepth != 4 || got.DroppedSpans != 6 {
+		t.Fatalf("stats = %+v, want 4 queued and 6 dropped", got)
+	}
+
+	close(exporter.release)
+	if err := tp.ForceFlush(context.Background()); !errors.Is(err, exporter.err) {
+		t.Fatalf("ForceFlush = %v, want the export error", err)
+	}
+	got = stats.Snapshot()
+	if got.TracerQueueDepth != 0 || got.DroppedSpans != 6 || got.LastExportError != "collector unavailable" {
+		t.Fatalf("stats after the export = %+v, want an empty queue and the export error", got)
+	}
+	if exporter.exported != 4 {
+		t.Fatalf("%d spans exported, want the 4 queued", exporter.exported)
+	}
+}
+
+func TestOTelQueueWarning(t *testing.T) {
+	t.Setenv("GSD_OTEL_QUEUE_WARNING_THRESHOLD", "2")
+	a := newUnstartedTestServer(t)
+	a.otel.Stats.spansPending.Store(3)
+
+	if err := a.otelQueueWarning(context.Background()); err == nil {
+		t.Fatal("no warning with the queue past the threshold")
+	}
+}
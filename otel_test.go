@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// installStubProviders installs no-op global providers, as another component of the process
+// would, and restores the previous ones when the test ends.
+func installStubProviders(t *testing.T) (noop.TracerProvider, metricnoop.MeterProvider) {
+	t.Helper()
+
+	prevTracer, prevMeter, prevPropagator := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTracer)
+		otel.SetMeterProvider(prevMeter)
+		if otel.GetTextMapPropagator() != prevPropagator {
+			otel.SetTextMapPropagator(prevPropagator)
+		}
+	})
+
+	tracer, meter := noop.NewTracerProvider(), metricnoop.NewMeterProvider()
+	otel.SetTracerProvider(tracer)
+	otel.SetMeterProvider(meter)
+	return tracer, meter
+}
+
+func TestOTelGlobalPolicies(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantErr     bool
+		wantOurs    bool // our providers end up installed globally
+		wantReused  bool // the stub providers are used by the server
+		wantWarning bool
+	}{
+		{policy: OTelGlobalOverwrite, wantOurs: true, wantWarning: true},
+		{policy: OTelGlobalReuse, wantReused: true},
+		{policy: OTelGlobalError, wantErr: true},
+		{policy: OTelGlobalLocal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			stubTracer, _ := installStubProviders(t)
+			p, _, _ := newTestOTelProvider()
+			core, logs := observer.New(zapcore.InfoLevel)
+
+			err := p.Setup(tt.policy, zap.New(core))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup = %v, want error %v", err, tt.wantErr)
+			}
+
+			if ours := otel.GetTracerProvider() == p.tracerProvider; ours != tt.wantOurs {
+				t.Errorf("our tracer provider installed = %v, want %v", ours, tt.wantOurs)
+			}
+			if reused := p.TracerProvider() == stubTracer; reused != tt.wantReused {
+				t.Errorf("stub tracer provider used = %v, want %v", reused, tt.wantReused)
+			}
+			if warned := logs.FilterLevelExact(zapcore.WarnLevel).Len() > 0; warned != tt.wantWarning {
+				t.Errorf("warning logged = %v, want %v", warned, tt.wantWarning)
+			}
+		})
+	}
+}
+
+// collector listens for the exporters, standing for an OTLP collector: it only records that
+// a connection was made.
+func collector(t *testing.T) (addr string, connected <-chan struct{}) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+		close(ch)
+	}()
+	return ln.Addr().String(), ch
+}
+
+func assertExported(t *testing.T, connected <-chan struct{}, flush func(context.Context) error) {
+	t.Helper()
+
+	// The fake collector doesn't speak gRPC: the flush only ends at its deadline, and the
+	// connection is all that matters
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go flush(ctx)
+	select {
+	case <-connected:
+	case <-ctx.Done():
+		t.Fatal("the exporter never connected to the collector")
+	}
+}
+
+// shutdownNow gives up on the pending exports right away.
+func shutdownNow(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = shutdown(ctx)
+}
+
+func TestStandardOTelEnvVarsHonored(t *testing.T) {
+	sampled := oteltrace.ContextWithSpanContext(context.Background(), oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{1},
+		SpanID:     oteltrace.SpanID{1},
+		TraceFlags: oteltrace.FlagsSampled,
+	}))
+
+	t.Run("traces", func(t *testing.T) {
+		addr, connected := collector(t)
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://"+addr)
+		tp, err := newTracerProvider(context.Background(), Config{}, &OTelStats{})
+		if err != nil {
+			t.Fatalf("newTracerProvider: %v", err)
+		}
+		defer shutdownNow(tp.Shutdown)
+
+		_, span := tp.Tracer("test").Start(sampled, "span")
+		span.End()
+		assertExported(t, connected, tp.ForceFlush)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		addr, connected := collector(t)
+		t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "http://"+addr)
+		mp, err := newMeterProvider(context.Background(), Config{}, &OTelStats{}, nil)
+		if err != nil {
+			t.Fatalf("newMeterProvider: %v", err)
+		}
+		defer shutdownNow(mp.Shutdown)
+
+		counter, _ := mp.Meter("test").Int64Counter("test.counter")
+		counter.Add(context.Background(), 1)
+		assertExported(t, connected, mp.ForceFlush)
+	})
+
+	t.Run("custom endpoint wins", func(t *testing.T) {
+		standard, standardConnected := collector(t)
+		custom, customConnected := collector(t)
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://"+standard)
+		tp, err := newTracerProvider(context.Background(), Config{TracingEndpoint: custom}, &OTelStats{})
+		if err != nil {
+			t.Fatalf("newTracerProvider: %v", err)
+		}
+		defer shutdownNow(tp.Shutdown)
+
+		_, span := tp.Tracer("test").Start(sampled, "span")
+		span.End()
+		assertExported(t, customConnected, tp.ForceFlush)
+		select {
+		case <-standardConnected:
+			t.Fatal("the exporter connected to the standard endpoint despite the custom one")
+		default:
+		}
+	})
+
+	t.Run("service name", func(t *testing.T) {
+		t.Setenv("OTEL_SERVICE_NAME", "from-env")
+		res, err := newResource(context.Background(), Config{})
+		if err != nil {
+			t.Fatalf("newResource: %v", err)
+		}
+		if name, _ := res.Set().Value(semconv.ServiceNameKey); name.AsString() != "from-env" {
+			t.Fatalf("service.name = %q, want from-env", name.AsString())
+		}
+	})
+}
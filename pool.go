@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrPoolClosed is returned by Pool.Submit once the pool is shutting down.
+	ErrPoolClosed = errors.New("worker pool is closed")
+	// ErrPoolFull is returned by Pool.Submit when the queue has no room left.
+	ErrPoolFull = errors.New("worker pool queue is full")
+)
+
+// _poolCancelGrace is how long Pool.Shutdown waits for the workers once their context is
+// cancelled.
+const _poolCancelGrace = time.Second
+
+// Drain modes of a Pool, chosen with WithDrainMode.
+const (
+	// PoolDrainAll runs every queued task on shutdown.
+	PoolDrainAll = "drain_all"
+	// PoolDropQueued only lets the running tasks complete and drops the queued ones.
+	PoolDropQueued = "drop_queued"
+)
+
+// Task is a unit of work run by a Pool. ctx is cancelled when the pool stops waiting for it.
+type Task func(ctx context.Context)
+
+// Pool runs tasks on a fixed number of workers, queuing up to a bounded number of them.
+type Pool struct {
+	logger *zap.Logger
+	tasks  chan Task
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	drainMode  string
+	dropQueued atomic.Bool
+
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+// PoolOption customizes a Pool built by NewPool.
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	queueSize int
+	drainMode string
+	logger    *zap.Logger
+}
+
+// WithQueueSize sets how many tasks may wait for a worker. It defaults to the pool size.
+func WithQueueSize(n int) PoolOption {
+	return func(c *poolConfig) {
+		c.queueSize = n
+	}
+}
+
+// WithDrainMode sets what happens to the queued tasks on shutdown: PoolDrainAll (default)
+// or PoolDropQueued. NewPool panics on any other mode.
+func WithDrainMode(mode string) PoolOption {
+	return func(c *poolConfig) {
+		c.drainMode = mode
+	}
+}
+
+// WithPoolLogger sets the logger reporting the tasks dropped on shutdown.
+func WithPoolLogger(logger *zap.Logger) PoolOption {
+	return func(c *poolConfig) {
+		c.logger = logger
+	}
+}
+
+// NewPool starts size workers.
+func NewPool(size int, opts ...PoolOption) *Pool {
+	size = max(size, 1)
+	cfg := poolConfig{queueSize: size, drainMode: PoolDrainAll, logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.drainMode != PoolDrainAll && cfg.drainMode != PoolDropQueued {
+		panic(fmt.Sprintf("worker pool: unknown drain mode %q", cfg.drainMode))
+	}
+
+	p := &Pool{
+		logger:    cfg.logger,
+		tasks:     make(chan Task, cfg.queueSize),
+		drainMode: cfg.drainMode,
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	p.wg.Add(size)
+	for range size {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for task := range p.tasks {
+		if p.ctx.Err() != nil || p.dropQueued.Load() {
+			p.dropped.Add(1)
+			continue
+		}
+		task(p.ctx)
+	}
+}
+
+// Submit queues task without blocking.
+func (p *Pool) Submit(task Task) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		return ErrPoolFull
+	}
+}
+
+// Shutdown stops accepting tasks and waits for the running ones, and for the queued ones
+// unless the pool drops them (PoolDropQueued). When ctx is done first, the workers' context
+// is cancelled and the tasks still queued are dropped.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		p.dropQueued.Store(p.drainMode == PoolDropQueued)
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		if dropped := p.dropped.Load(); dropped > 0 {
+			p.logger.Info("Worker pool dropped its queued tasks", zap.Int64("dropped_tasks", dropped))
+		}
+		return nil
+	case <-ctx.Done():
+		// Running tasks see their context cancelled, queued ones are dropped
+		p.cancel()
+		for range p.tasks {
+			p.dropped.Add(1)
+		}
+
+		// The workers count the tasks they dropped too: wait for them before reading the
+		// count, but not forever for a task ignoring the cancellation.
+		select {
+		case <-done:
+		case <-time.After(_poolCancelGrace):
+			p.logger.Warn("Worker pool tasks ignored the cancellation, the dropped count may be short")
+		}
+		dropped := p.dropped.Load()
+		p.logger.Warn("Worker pool shut down before its queue was drained", zap.Int64("dropped_tasks", dropped))
+		return fmt.Errorf("worker pool: %d tasks dropped: %w", dropped, ctx.Err())
+	}
+}
+
+// Queued returns the number of tasks waiting for a worker.
+func (p *Pool) Queued() int {
+	return len(p.tasks)
+}
+
+// _poolQueueWarnRatio is the share of a Pool queue whose use is logged by its monitor.
+const _poolQueueWarnRatio = 0.8
+
+// RegisterPool shuts p down with the other resources, once the HTTP server has stopped
+// handing it new tasks. Unless Config.PoolMonitorInterval is zero, its queue is monitored
+// until then, warning when more than 80% of it is used.
+func (a *APIServer) RegisterPool(name string, p *Pool) {
+	a.RegisterShutdownHook(name, p.Shutdown)
+	if a.Config.PoolMonitorInterval <= 0 {
+		return
+	}
+	threshold := int(float64(cap(p.tasks)) * _poolQueueWarnRatio)
+	monitor := NewPoolMonitor(name, p.Queued, threshold, a.Config.PoolMonitorInterval, a.Logger)
+	monitor.Start()
+	a.RegisterShutdownHook(name+"_monitor", monitor.Stop)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PoolMonitor samples the usage of a shared pool, e.g. the queue length of a Pool or the
+// connections in use of a client pool, and keeps its high watermark. Every new high watermark
+// above the threshold is logged, so a pool running close to its capacity shows up before it
+// starts rejecting work.
+type PoolMonitor struct {
+	name      string
+	sample    func() int
+	threshold int
+	interval  time.Duration
+	logger    *zap.Logger
+
+	highWatermark atomic.Int64
+	stop          chan struct{}
+	stopOnce      sync.Once
+}
+
+// NewPoolMonitor monitors the pool name by calling sample every interval, once started.
+func NewPoolMonitor(name string, sample func() int, threshold int, interval time.Duration, logger *zap.Logger) *PoolMonitor {
+	return &PoolMonitor{
+		name:      name,
+		sample:    sample,
+		threshold: threshold,
+		interval:  interval,
+		logger:    logger.Named("pool_monitor"),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start samples the pool from its own goroutine until Stop.
+func (m *PoolMonitor) Start() {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.observe()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *PoolMonitor) observe() {
+	usage := int64(m.sample())
+	if usage <= m.highWatermark.Load() {
+		return
+	}
+	m.highWatermark.Store(usage)
+	if usage > int64(m.threshold) {
+		m.logger.Warn("Pool usage reached a new high watermark",
+			zap.String("pool", m.name),
+			zap.Int64("high_watermark", usage),
+			zap.Int("threshold", m.threshold),
+		)
+	}
+}
+
+// HighWatermark returns the highest usage sampled so far.
+func (m *PoolMonitor) HighWatermark() int {
+	return int(m.highWatermark.Load())
+}
+
+// Stop stops sampling and logs the high watermark of the pool's lifetime. Its signature
+// makes it a shutdown hook.
+func (m *PoolMonitor) Stop(context.Context) error {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		m.logger.Info("Pool usage high watermark",
+			zap.String("pool", m.name),
+			zap.Int("high_watermark", m.HighWatermark()),
+		)
+	})
+	return nil
+}
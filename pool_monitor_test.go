@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolMonitorKeepsTheHighWatermark(t *testing.T) {
+	var usage atomic.Int64
+	core, logs := observer.New(zapcore.InfoLevel)
+	monitor := NewPoolMonitor("jobs", func() int { return int(usage.Load()) }, 5, time.Millisecond, zap.New(core))
+	monitor.Start()
+	defer monitor.Stop(context.Background())
+
+	usage.Store(3)
+	waitFor(t, "the high watermark to reach 3", func() bool { return monitor.HighWatermark() == 3 })
+	if n := logs.FilterMessage("Pool usage reached a new high watermark").Len(); n != 0 {
+		t.Fatalf("%d warnings below the threshold, want none", n)
+	}
+
+	// The usage going down keeps the high watermark
+	usage.Store(1)
+	time.Sleep(10 * time.Millisecond)
+	if got := monitor.HighWatermark(); got != 3 {
+		t.Fatalf("high watermark = %d after the usage dropped, want 3", got)
+	}
+
+	usage.Store(8)
+	waitFor(t, "the high watermark to reach 8", func() bool { return monitor.HighWatermark() == 8 })
+	warnings := logs.FilterMessage("Pool usage reached a new high watermark").All()
+	if len(warnings) != 1 || warnings[0].ContextMap()["high_watermark"] != int64(8) {
+		t.Fatalf("warnings = %+v, want one for 8", warnings)
+	}
+
+	monitor.Stop(context.Background())
+	final := logs.FilterMessage("Pool usage high watermark").All()
+	if len(final) != 1 || final[0].ContextMap()["high_watermark"] != int64(8) {
+		t.Fatalf("final entries = %+v, want the high watermark logged once at Stop", final)
+	}
+}
+
+func TestRegisteredPoolMonitoredUntilShutdown(t *testing.T) {
+	t.Setenv("GSD_POOL_MONITOR_INTERVAL", "1ms")
+	a := newUnstartedTestServer(t)
+	p := NewPool(1, WithQueueSize(10))
+	a.RegisterPool("jobs", p)
+
+	release := make(chan struct{})
+	for range 10 {
+		if err := p.Submit(func(context.Context) { <-release }); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	// 9 queued behind the running task, over 80% of the queue
+	waitFor(t, "the queue to be sampled", func() bool {
+		return a.Logs.FilterMessage("Pool usage reached a new high watermark").Len() > 0
+	})
+	close(release)
+
+	if err := a.ShutdownResources(t.Context()); err != nil {
+		t.Fatalf("ShutdownResources: %v", err)
+	}
+	final := a.Logs.FilterMessage("Pool usage high watermark").All()
+	if len(final) != 1 || final[0].ContextMap()["high_watermark"].(int64) < 8 {
+		t.Fatalf("final entries = %+v, want the high watermark of the queue", final)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPoolDrainsQueueWithinBudget(t *testing.T) {
+	p := NewPool(2, WithQueueSize(6))
+	var ran atomic.Int64
+	for range 6 {
+		if err := p.Submit(func(context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			ran.Add(1)
+		}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := ran.Load(); got != 6 {
+		t.Fatalf("%d tasks ran, want all 6", got)
+	}
+	if err := p.Submit(func(context.Context) {}); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Submit after Shutdown = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolDropsQueuedTasksPastBudget(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	p := NewPool(1, WithQueueSize(3), WithPoolLogger(zap.New(core)))
+
+	// One task holds the only worker until its context is cancelled, three wait behind it
+	started := make(chan struct{})
+	p.Submit(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+	for range 3 {
+		p.Submit(func(context.Context) { t.Error("queued task ran past the budget") })
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want the budget exceeded", err)
+	}
+
+	entries := logs.FilterField(zap.Int64("dropped_tasks", 3)).All()
+	if len(entries) != 1 {
+		t.Fatalf("logs = %v, want the 3 dropped tasks logged", logs.All())
+	}
+}
+
+func TestPoolRejectsUnknownDrainMode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewPool accepted an unknown drain mode")
+		}
+	}()
+	NewPool(1, WithDrainMode("drain_some"))
+}
+
+func TestPoolDrainModes(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantRan int64
+	}{
+		{PoolDrainAll, 4},
+		{PoolDropQueued, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			core, logs := observer.New(zapcore.InfoLevel)
+			p := NewPool(1, WithQueueSize(3), WithDrainMode(tt.mode), WithPoolLogger(zap.New(core)))
+
+			// The first task runs while the shutdown starts, three are queued behind it
+			var ran atomic.Int64
+			started, release := make(chan struct{}), make(chan struct{})
+			p.Submit(func(context.Context) {
+				close(started)
+				<-release
+				ran.Add(1)
+			})
+			<-started
+			for range 3 {
+				p.Submit(func(context.Context) { ran.Add(1) })
+			}
+
+			shutdownErr := make(chan error, 1)
+			go func() { shutdownErr <- p.Shutdown(context.Background()) }()
+			for !poolClosed(p) {
+				time.Sleep(time.Millisecond)
+			}
+			close(release)
+
+			if err := <-shutdownErr; err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+			if got := ran.Load(); got != tt.wantRan {
+				t.Fatalf("%d tasks ran, want %d", got, tt.wantRan)
+			}
+			dropped := 4 - tt.wantRan
+			if got := logs.FilterField(zap.Int64("dropped_tasks", dropped)).Len(); dropped > 0 && got != 1 {
+				t.Fatalf("logs = %v, want %d dropped tasks logged", logs.All(), dropped)
+			}
+		})
+	}
+}
+
+// poolClosed reports whether the shutdown of p started.
+func poolClosed(p *Pool) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.closed
+}
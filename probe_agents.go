@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// isProbeUserAgent reports whether r comes from an orchestrator or load balancer probe,
+// by the User-Agent prefixes of Config.ProbeUserAgents.
+func (a *APIServer) isProbeUserAgent(r *http.Request) bool {
+	ua := r.UserAgent()
+	for _, prefix := range a.Config.ProbeUserAgents {
+		if prefix != "" && strings.HasPrefix(ua, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleProbeReadiness answers the readiness of handleGetReadiness with its status only:
+// probes only look at the status, the JSON details are for humans.
+func (a *APIServer) handleProbeReadiness(w http.ResponseWriter, r *http.Request) error {
+	err := a.handleGetReadiness(statusOnlyWriter{w}, r)
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		w.WriteHeader(apiErr.Code)
+		return nil
+	}
+	return err
+}
+
+// statusOnlyWriter drops the body of the response, and its content type.
+type statusOnlyWriter struct {
+	http.ResponseWriter
+}
+
+func (w statusOnlyWriter) WriteHeader(code int) {
+	w.Header().Del("Content-Type")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w statusOnlyWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
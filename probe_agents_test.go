@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadinessBodyByUserAgent(t *testing.T) {
+	t.Setenv("GSD_HEALTH_CHECK_CACHE_TTL", "0s")
+	a := newUnstartedTestServer(t)
+	a.warmedUp.Store(true)
+
+	get := func(userAgent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("User-Agent", userAgent)
+		return a.serve(req)
+	}
+
+	for _, shuttingDown := range []bool{false, true} {
+		want := http.StatusOK
+		if shuttingDown {
+			a.InitiateShutdown()
+			want = http.StatusServiceUnavailable
+		}
+
+		probe := get("kube-probe/1.31")
+		if probe.Code != want || probe.Body.Len() != 0 || probe.Header().Get("Content-Type") != "" {
+			t.Fatalf("kube-probe got %d %q (%s), want an empty %d", probe.Code, probe.Body, probe.Header().Get("Content-Type"), want)
+		}
+
+		human := get("curl/8.5.0")
+		if human.Code != want || !json.Valid(human.Body.Bytes()) || human.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("curl got %d %q (%s), want JSON with %d", human.Code, human.Body, human.Header().Get("Content-Type"), want)
+		}
+	}
+}
+
+func TestProbeUserAgentsConfigurable(t *testing.T) {
+	t.Setenv("GSD_PROBE_USER_AGENTS", "ELB-HealthChecker")
+	a := newUnstartedTestServer(t)
+	a.warmedUp.Store(true)
+
+	for userAgent, wantEmpty := range map[string]bool{
+		"ELB-HealthChecker/2.0": true,
+		"kube-probe/1.31":       false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("User-Agent", userAgent)
+		if empty := a.serve(req).Body.Len() == 0; empty != wantEmpty {
+			t.Errorf("%s: empty body = %v, want %v", userAgent, empty, wantEmpty)
+		}
+	}
+}
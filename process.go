@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// _processKillWait bounds the wait for a killed subprocess.
+const _processKillWait = time.Second
+
+// childProcess is a subprocess reaped by the server.
+type childProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error // set before done is closed
+}
+
+// processRegistry terminates the registered subprocesses at shutdown.
+type processRegistry struct {
+	mu       sync.Mutex
+	children []*childProcess
+	closed   bool // set once the shutdown hook took the children
+}
+
+// RegisterProcess has the started cmd terminated at shutdown, so it isn't left orphaned: it
+// gets SIGTERM, then SIGKILL if it's still running after Config.ProcessGracePeriod, and is
+// waited for. The server waits for cmd from now on, so the caller must not call cmd.Wait:
+// the returned channel receives the result of the wait instead.
+//
+// Once InitiateShutdown was called it returns ErrServerShuttingDown, and cmd stays the
+// caller's to stop and wait for. A cmd that wasn't started is rejected.
+func (a *APIServer) RegisterProcess(cmd *exec.Cmd) (<-chan error, error) {
+	if cmd.Process == nil {
+		return nil, fmt.Errorf("register process %s: not started", cmd.Path)
+	}
+	r := &a.processes
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || a.isShuttingDown.Load() {
+		return nil, fmt.Errorf("register process %d: %w", cmd.Process.Pid, ErrServerShuttingDown)
+	}
+
+	child := &childProcess{cmd: cmd, done: make(chan struct{})}
+	result := make(chan error, 1)
+	go func() {
+		child.err = cmd.Wait()
+		close(child.done)
+		result <- child.err
+	}()
+	r.children = append(r.children, child)
+	return result, nil
+}
+
+// terminateProcesses terminates every registered subprocess still running, concurrently.
+// It is the "processes" shutdown hook; the registrations racing with it are rejected.
+func (a *APIServer) terminateProcesses(ctx context.Context) error {
+	r := &a.processes
+	r.mu.Lock()
+	children := r.children
+	r.closed = true
+	r.mu.Unlock()
+
+	errs := make([]error, len(children))
+	var wg sync.WaitGroup
+	for i, child := range children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = a.terminateProcess(ctx, child)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (a *APIServer) terminateProcess(ctx context.Context, child *childProcess) error {
+	select {
+	case <-child.done:
+		return nil
+	default:
+	}
+
+	pid := child.cmd.Process.Pid
+	if err := child.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		// Exited meanwhile, or a platform without SIGTERM: the kill below settles it
+		a.Logger.Debug("Failed to send SIGTERM to process", zap.Int("pid", pid), zap.Error(err))
+	}
+
+	grace := time.NewTimer(a.Config.ProcessGracePeriod)
+	defer grace.Stop()
+	select {
+	case <-child.done:
+		return nil
+	case <-grace.C:
+	case <-ctx.Done():
+	}
+
+	a.Logger.Warn("Process still running after SIGTERM, killing it",
+		zap.Int("pid", pid),
+		zap.String("path", child.cmd.Path),
+	)
+	if err := child.cmd.Process.Kill(); err != nil {
+		select {
+		case <-child.done:
+			return nil
+		default:
+			return fmt.Errorf("kill process %d: %w", pid, err)
+		}
+	}
+	// Wait also waits for the copy of the output pipes, which a grandchild may hold open
+	select {
+	case <-child.done:
+		return nil
+	case <-time.After(_processKillWait):
+		return fmt.Errorf("process %d killed but not reaped", pid)
+	}
+}
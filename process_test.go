@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// startProcess starts the shell script and returns once it ran, then execs sleep. The
+// process is killed at the end of the test if still running.
+func startProcess(t *testing.T, script string) *exec.Cmd {
+	t.Helper()
+
+	ready := filepath.Join(t.TempDir(), "ready")
+	cmd := exec.Command("sh", "-c", script+`; touch "$READY"; exec sleep 30`)
+	cmd.Env = append(os.Environ(), "READY="+ready)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("start %q: %v", script, err)
+	}
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(ready); err == nil {
+			return cmd
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("script %q not run", script)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProcessesTerminatedAtShutdown(t *testing.T) {
+	t.Setenv("GSD_PROCESS_GRACE_PERIOD", "100ms")
+	a := newUnstartedTestServer(t)
+
+	polite, err := a.RegisterProcess(startProcess(t, "true"))
+	if err != nil {
+		t.Fatalf("RegisterProcess: %v", err)
+	}
+	// SIGTERM stays ignored across the exec
+	stubborn, err := a.RegisterProcess(startProcess(t, `trap "" TERM`))
+	if err != nil {
+		t.Fatalf("RegisterProcess: %v", err)
+	}
+	_, shutdown := a.HookNames()
+	if hooks := slices.DeleteFunc(shutdown, func(name string) bool { return name != "processes" }); len(hooks) != 1 {
+		t.Fatalf("%d processes shutdown hooks, want one", len(hooks))
+	}
+
+	a.InitiateShutdown()
+	start := time.Now()
+	if err := a.ShutdownResources(t.Context()); err != nil {
+		t.Fatalf("ShutdownResources: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("processes terminated in %v, want about the 100ms grace period", elapsed)
+	}
+
+	for name, done := range map[string]<-chan error{"polite": polite, "stubborn": stubborn} {
+		select {
+		case err := <-done:
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				t.Errorf("%s process exited with %v, want it signaled", name, err)
+			}
+		default:
+			t.Errorf("%s process not reaped by the shutdown", name)
+		}
+	}
+	if n := a.Logs.FilterMessage("Process still running after SIGTERM, killing it").Len(); n != 1 {
+		t.Errorf("%d processes killed, want the one ignoring SIGTERM", n)
+	}
+}
+
+func TestRegisterProcessRejectedOnceShuttingDown(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	a.InitiateShutdown()
+
+	cmd := startProcess(t, "true")
+	if _, err := a.RegisterProcess(cmd); !errors.Is(err, ErrServerShuttingDown) {
+		t.Fatalf("RegisterProcess = %v, want ErrServerShuttingDown", err)
+	}
+	// The process is still the caller's
+	_ = cmd.Process.Kill()
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("Wait = nil, want the kill")
+	}
+}
+
+func TestRegisterProcessRejectsUnstartedCommand(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	cmd := exec.Command("sleep", "30")
+	if _, err := a.RegisterProcess(cmd); err == nil {
+		t.Fatal("RegisterProcess accepted a command that wasn't started")
+	}
+
+	// Once shutting down too, without reading the pid of the missing process
+	a.InitiateShutdown()
+	if _, err := a.RegisterProcess(cmd); err == nil {
+		t.Fatal("RegisterProcess accepted a command that wasn't started once shutting down")
+	}
+}
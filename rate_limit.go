@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// _maxRateLimitBuckets bounds the memory of TokenBucketLimiter: past it, the buckets that
+// refilled completely, which hold no state worth keeping, are dropped.
+const _maxRateLimitBuckets = 10_000
+
+// RateLimiter decides whether the request identified by key may proceed. When it may not,
+// retryAfter tells when the next one would be allowed.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration)
+}
+
+// TokenBucketLimiter is an in-process RateLimiter: every key gets a bucket of burst tokens
+// refilled at rps tokens per second. Each pod enforces its own limit.
+type TokenBucketLimiter struct {
+	rps   float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucketLimiter(rps float64, burst int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		rps:     rps,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *TokenBucketLimiter) Allow(_ context.Context, key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= _maxRateLimitBuckets {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rps)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rps * float64(time.Second))
+}
+
+func (l *TokenBucketLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rps >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// newRateLimiter builds the limiter of the rate_limit middleware: shared through Redis with
+// Config.DistributedRateLimit, in-process otherwise. The Redis client is the injected one,
+// or one connecting to Config.RedisAddr.
+func (a *APIServer) newRateLimiter() (RateLimiter, error) {
+	cfg := a.Config
+	if !cfg.DistributedRateLimit {
+		return NewTokenBucketLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst), nil
+	}
+
+	client := a.backends.Redis
+	if client == nil {
+		if cfg.RedisAddr == "" {
+			return nil, errors.New("GSD_REDIS_ADDR is required for the distributed rate limit without an injected Redis client")
+		}
+		client = redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		a.RegisterShutdownHook("redis", func(_ context.Context) error {
+			return client.Close()
+		})
+	}
+	return NewRedisRateLimiter(client, _serviceName+":ratelimit:", cfg.RateLimitRPS, cfg.RateLimitBurst), nil
+}
+
+// RateLimitMiddleware answers 429 with a Retry-After header to the requests refused by limiter.
+// Requests are limited per authenticated subject, or per client IP without authentication;
+// the auth middleware has to come first in Config.Middleware for the subject to be used.
+// Refusals are logged at debug by the "rate_limit" logger.
+func RateLimitMiddleware(limiter RateLimiter, logger *zap.Logger) func(http.Handler) http.Handler {
+	logger = logger.Named("rate_limit")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, loggedKey := rateLimitKey(r)
+			allowed, retryAfter := limiter.Allow(r.Context(), key)
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			WithTrace(r.Context(), logger).Debug("Request rate limited",
+				zap.String("key", loggedKey),
+				zap.Duration("retry_after", retryAfter),
+			)
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			WriteJSON(w, http.StatusTooManyRequests, APIError{
+				Code:    http.StatusTooManyRequests,
+				Message: "too many requests",
+			})
+		})
+	}
+}
+
+// rateLimitKey returns the key r is limited by, and the same key as it may appear in logs:
+// only the logged one has the subject redacted, the limit applies to the subject itself.
+func rateLimitKey(r *http.Request) (key, logged string) {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		return "subject:" + principal.Subject, "subject:" + principal.RedactedSubject()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, "ip:" + host
+}
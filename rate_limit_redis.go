@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// _slidingWindowScript counts the requests of the last window in a sorted set scored by time,
+// and adds the new one when it's under the limit. It returns {allowed, retry after in µs}.
+var _slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, math.ceil(window / 1000))
+	return {1, 0}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {0, tonumber(oldest[2]) + window - now}
+`)
+
+// redisRateLimiter is a RateLimiter shared by every pod through Redis, with a sliding window:
+// at most burst requests in the last burst/rps seconds.
+type redisRateLimiter struct {
+	client    *redis.Client
+	keyPrefix string
+	window    time.Duration
+	limit     int
+}
+
+// NewRedisRateLimiter limits the requests across pods using Redis sorted sets. When Redis
+// can't be reached the requests are allowed: an outage of the limiter shouldn't take the API
+// down with it.
+func NewRedisRateLimiter(client *redis.Client, keyPrefix string, rps float64, burst int) RateLimiter {
+	return &redisRateLimiter{
+		client:    client,
+		keyPrefix: keyPrefix,
+		window:    time.Duration(float64(burst) / rps * float64(time.Second)),
+		limit:     burst,
+	}
+}
+
+func (l *redisRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	now := time.Now().UnixMicro()
+	member := fmt.Sprintf("%d-%d", now, rand.Uint64())
+
+	res, err := _slidingWindowScript.Run(ctx, l.client, []string{l.keyPrefix + key},
+		now, l.window.Microseconds(), l.limit, member).Int64Slice()
+	if err != nil || len(res) != 2 {
+		return true, 0
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Microsecond
+}
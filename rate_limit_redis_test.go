@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestRedisRateLimiterSharedAcrossPods(t *testing.T) {
+	_, client := newTestRedis(t)
+	// Two pods sharing the same Redis: 3 requests per 3 seconds in total
+	pods := []RateLimiter{
+		NewRedisRateLimiter(client, "gsd:ratelimit:", 1, 3),
+		NewRedisRateLimiter(client, "gsd:ratelimit:", 1, 3),
+	}
+
+	for i := range 3 {
+		if allowed, _ := pods[i%2].Allow(t.Context(), "ip:10.0.0.1"); !allowed {
+			t.Fatalf("request %d refused, want the burst allowed", i)
+		}
+	}
+	allowed, retryAfter := pods[1].Allow(t.Context(), "ip:10.0.0.1")
+	if allowed {
+		t.Fatal("request over the shared limit allowed")
+	}
+	if retryAfter <= 0 || retryAfter > 3*time.Second {
+		t.Fatalf("retry after = %v, want within the 3s window", retryAfter)
+	}
+
+	// Keys are limited independently
+	if allowed, _ := pods[0].Allow(t.Context(), "ip:10.0.0.2"); !allowed {
+		t.Fatal("another client refused")
+	}
+}
+
+func TestRedisRateLimiterFailsOpen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+	limiter := NewRedisRateLimiter(client, "gsd:ratelimit:", 1, 1)
+	mr.Close()
+
+	for range 3 {
+		if allowed, _ := limiter.Allow(t.Context(), "ip:10.0.0.1"); !allowed {
+			t.Fatal("request refused while Redis is down, want the limiter to fail open")
+		}
+	}
+}
+
+func TestDistributedRateLimitMiddleware(t *testing.T) {
+	_, client := newTestRedis(t)
+	t.Setenv("GSD_MIDDLEWARE", "recovery,rate_limit")
+	t.Setenv("GSD_DISTRIBUTED_RATE_LIMIT", "true")
+	t.Setenv("GSD_RATE_LIMIT_RPS", "1")
+	t.Setenv("GSD_RATE_LIMIT_BURST", "2")
+	a := newUnstartedTestServer(t,
+		withBackends(func(b *Backends) { b.Redis = client }),
+		withRoute("GET /items", func(w http.ResponseWriter, r *http.Request) error { return nil }),
+	)
+
+	var codes []int
+	for range 3 {
+		codes = append(codes, a.serve(httptest.NewRequest(http.MethodGet, "/items", nil)).Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("statuses = %v, want 2 allowed then 429", codes)
+	}
+}
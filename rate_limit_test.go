@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// denyingLimiter refuses every request and remembers the keys it was asked about.
+type denyingLimiter struct {
+	keys []string
+}
+
+func (l *denyingLimiter) Allow(_ context.Context, key string) (bool, time.Duration) {
+	l.keys = append(l.keys, key)
+	return false, time.Second
+}
+
+func TestRateLimitKeyRedactedInLogsOnly(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	limiter := &denyingLimiter{}
+	handler := RateLimitMiddleware(limiter, zap.New(core))(_okHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), principalKey{}, Principal{Subject: "ada@example.com"}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("status %d, Retry-After %q: want 429 and 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if len(limiter.keys) != 1 || limiter.keys[0] != "subject:ada@example.com" {
+		t.Fatalf("limited by %v, want the subject itself", limiter.keys)
+	}
+	entries := logs.FilterMessage("Request rate limited").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d rate limit entries, want 1", len(entries))
+	}
+	if entries[0].LoggerName != "rate_limit" || entries[0].ContextMap()["key"] != "subject:"+hashIdentifier("ada@example.com") {
+		t.Fatalf("entry %s %v, want the redacted key logged by rate_limit", entries[0].LoggerName, entries[0].ContextMap())
+	}
+}
+
+func TestRateLimitKeyByClientIP(t *testing.T) {
+	limiter := &denyingLimiter{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:51234"
+	RateLimitMiddleware(limiter, zap.NewNop())(_okHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(limiter.keys) != 1 || limiter.keys[0] != "ip:10.1.2.3" {
+		t.Fatalf("limited by %v, want the client IP", limiter.keys)
+	}
+}
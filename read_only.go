@@ -0,0 +1,65 @@
+package main
+
+import "net/http"
+
+// ReadOnlyState is the body of the admin read-only endpoints.
+type ReadOnlyState struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// SetReadOnly turns the read-only mode on or off, e.g. for a maintenance window.
+func (a *APIServer) SetReadOnly(v bool) {
+	if a.readOnly.Swap(v) != v {
+		if v {
+			a.Events.Record(EventState, "read-only mode enabled")
+		} else {
+			a.Events.Record(EventState, "read-only mode disabled")
+		}
+	}
+}
+
+// ReadOnly reports whether mutating requests are currently rejected.
+func (a *APIServer) ReadOnly() bool {
+	return a.readOnly.Load()
+}
+
+// ReadOnlyMiddleware answers 503 to the requests with a non-safe method (POST, PUT, PATCH,
+// DELETE, ...) while a is in read-only mode. Reads keep being served.
+func ReadOnlyMiddleware(a *APIServer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !a.ReadOnly() || isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			WriteJSON(w, http.StatusServiceUnavailable, APIError{
+				Code:    http.StatusServiceUnavailable,
+				Message: "server in read-only mode",
+			})
+		})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *APIServer) handleGetReadOnly(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJSON(w, http.StatusOK, ReadOnlyState{ReadOnly: a.ReadOnly()})
+}
+
+func (a *APIServer) handlePutReadOnly(w http.ResponseWriter, r *http.Request) error {
+	var state ReadOnlyState
+	if err := DecodeJSON(w, r, &state); err != nil {
+		return err
+	}
+
+	a.SetReadOnly(state.ReadOnly)
+	return WriteJSON(w, http.StatusOK, state)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyModeToggledAtRuntime(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "recovery,read_only")
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSON(w, http.StatusOK, map[string]string{"method": r.Method})
+	}
+	a := newUnstartedTestServer(t, withRoute("GET /items", handler), withRoute("POST /items", handler))
+	status := func(method string) int {
+		return a.serve(httptest.NewRequest(method, "/items", strings.NewReader(`{}`))).Code
+	}
+
+	if got := status(http.MethodPost); got != http.StatusOK {
+		t.Fatalf("POST before read-only = %d, want 200", got)
+	}
+
+	a.SetReadOnly(true)
+	if got := status(http.MethodPost); got != http.StatusServiceUnavailable {
+		t.Fatalf("POST in read-only mode = %d, want 503", got)
+	}
+	if got := status(http.MethodGet); got != http.StatusOK {
+		t.Fatalf("GET in read-only mode = %d, want 200", got)
+	}
+
+	a.SetReadOnly(false)
+	if got := status(http.MethodPost); got != http.StatusOK {
+		t.Fatalf("POST after read-only = %d, want 200", got)
+	}
+}
+
+func TestReadOnlyAdminEndpoint(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	rec := httptest.NewRecorder()
+	a.adminMux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/read-only", strings.NewReader(`{"read_only":true}`)))
+	if rec.Code != http.StatusOK || !a.ReadOnly() {
+		t.Fatalf("PUT read-only = %d, read-only %v: want 200 and enabled", rec.Code, a.ReadOnly())
+	}
+}
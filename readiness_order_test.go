@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestReadinessFlipsBeforeDrainWait fires concurrent shutdown triggers while a probe polls
+// readiness: once any trigger reached its drain wait, the probe must never see "ready".
+func TestReadinessFlipsBeforeDrainWait(t *testing.T) {
+	const rounds, triggers = 20, 8
+
+	for range rounds {
+		a := newUnstartedTestServer(t)
+		a.warmedUp.Store(true)
+
+		var waitBegan, hookRan atomic.Bool
+		a.OnDrain("probe-check", func(context.Context) error {
+			hookRan.Store(true)
+			if rec := a.serve(httptest.NewRequest(http.MethodGet, "/healthz", nil)); rec.Code == http.StatusOK {
+				t.Error("drain hook ran while readiness was still ready")
+			}
+			return nil
+		})
+
+		stop := make(chan struct{})
+		probed := make(chan struct{})
+		go func() {
+			defer close(probed)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				began := waitBegan.Load()
+				rec := a.serve(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+				if began && rec.Code == http.StatusOK {
+					t.Error("probe saw ready after a drain wait began")
+					return
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for range triggers {
+			wg.Go(func() {
+				a.InitiateShutdown()
+				if err := a.RunDrainHooks(context.Background()); err != nil {
+					t.Errorf("RunDrainHooks: %v", err)
+				}
+				if !hookRan.Load() {
+					t.Error("drain wait began before the drain hooks completed")
+				}
+				waitBegan.Store(true)
+			})
+		}
+		wg.Wait()
+		close(stop)
+		<-probed
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// RecoveryMiddleware turns a panicking handler into a 500 response instead of a dropped connection.
+// Panics are logged by the "recovery" logger.
+func RecoveryMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	logger = logger.Named("recovery")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec) // Let net/http abort the response silently
+				}
+
+				logger.Error("Recovered from panic",
+					zap.Any("panic", rec),
+					zap.String("path", r.URL.Path),
+					zap.Stack("stack"),
+				)
+				WriteJSON(w, http.StatusInternalServerError, APIError{
+					Code:    http.StatusInternalServerError,
+					Message: "internal server error",
+				})
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
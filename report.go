@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ShutdownReport describes how the shutdown sequence went. It is logged as the last line
+// before exit so deploy automation can tell whether the shutdown succeeded. DrainWaitedMS is
+// the part of the drain phase the drain strategy waited, the rest being the drain hooks and
+// the deregistrations.
+type ShutdownReport struct {
+	Success         bool            `json:"success"`
+	DrainStrategy   string          `json:"drain_strategy"`
+	DrainWaitedMS   float64         `json:"drain_waited_ms"`
+	RequestsServed  int64           `json:"requests_served"`
+	ForcedCancelled int64           `json:"forced_cancelled"`
+	Goroutines      int             `json:"goroutines"`
+	Phases          []ShutdownPhase `json:"phases"`
+	Errors          []string        `json:"errors,omitempty"`
+}
+
+// Err returns the shutdown errors joined into one, or nil when the shutdown succeeded.
+func (r ShutdownReport) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, len(r.Errors))
+	for i, msg := range r.Errors {
+		errs[i] = errors.New(msg)
+	}
+	return errors.Join(errs...)
+}
+
+// ExitCode returns the exit status of the process after the shutdown: 1 when it failed in
+// strict mode, 0 otherwise.
+func (r ShutdownReport) ExitCode(strict bool) int {
+	if strict && !r.Success {
+		return 1
+	}
+	return 0
+}
+
+// logShutdownSummary logs report under the "summary" key, as one JSON object with the JSON
+// encoder.
+func logShutdownSummary(logger *zap.Logger, r ShutdownReport) {
+	logger.Info("Shutdown summary", zap.Any("summary", r))
+}
+
+// ShutdownPhase is the duration of one step of the shutdown sequence.
+type ShutdownPhase struct {
+	Name       string  `json:"name"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// phase records the phase name started at start, and its error if any.
+func (r *ShutdownReport) phase(name string, start time.Time, err error) time.Duration {
+	d := time.Since(start)
+	r.Phases = append(r.Phases, ShutdownPhase{Name: name, DurationMS: float64(d.Microseconds()) / 1000})
+	if err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", name, err))
+	}
+	return d
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestShutdownSummaryIsParseableJSON(t *testing.T) {
+	srv := NewMockAPIServer()
+	srv.ShutdownResourcesFunc = func(context.Context) error { return errors.New("close failed") }
+	report := runMockUntilCancelled(t, newTestRunner(srv), srv)
+
+	var buf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zap.InfoLevel)
+	logShutdownSummary(zap.New(core), report)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var entry struct {
+		Msg     string         `json:"msg"`
+		Summary ShutdownReport `json:"summary"`
+	}
+	if err := json.Unmarshal(lines[len(lines)-1], &entry); err != nil {
+		t.Fatalf("parse the summary line: %v", err)
+	}
+
+	got := entry.Summary
+	if entry.Msg != "Shutdown summary" || got.Success {
+		t.Fatalf("summary = %q %+v, want an unsuccessful shutdown summary", entry.Msg, got)
+	}
+	if len(got.Errors) != 1 || got.Errors[0] != "resources: close failed" {
+		t.Fatalf("summary errors = %q", got.Errors)
+	}
+	phases := map[string]bool{}
+	for _, p := range got.Phases {
+		phases[p.Name] = true
+	}
+	for _, name := range []string{"drain", "resources"} {
+		if !phases[name] {
+			t.Errorf("summary phases = %+v, want %s", got.Phases, name)
+		}
+	}
+	if got.DrainStrategy != DrainFixed {
+		t.Errorf("summary drain strategy = %q, want %q", got.DrainStrategy, DrainFixed)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	_requestIDHeader         = "X-Request-ID"
+	_requestIDReplacedHeader = "X-Request-ID-Replaced"
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID given to the request by RequestIDMiddleware.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// RequestIDMiddleware gives every request an ID, in its context and in the X-Request-ID
+// request and response headers. A client-provided ID is kept only if it is a UUID v4;
+// otherwise it is replaced, which the X-Request-ID-Replaced response header reports.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(_requestIDHeader)
+		if id != "" && !isUUIDv4(id) {
+			w.Header().Set(_requestIDReplacedHeader, "true")
+			id = ""
+		}
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		r.Header.Set(_requestIDHeader, id)
+		w.Header().Set(_requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func isUUIDv4(s string) bool {
+	id, err := uuid.Parse(s)
+	// Parse also accepts the urn:uuid: and braced forms, only the canonical one is kept
+	return err == nil && len(s) == 36 && id.Version() == 4 && id.Variant() == uuid.RFC4122
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveRequestID(t *testing.T, clientID string) (seen string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	h := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = RequestIDFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if clientID != "" {
+		req.Header.Set(_requestIDHeader, clientID)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return seen, rec
+}
+
+func TestRequestIDReplacesNonUUID(t *testing.T) {
+	seen, rec := serveRequestID(t, "'; DROP TABLE requests; --")
+
+	if !isUUIDv4(seen) || rec.Header().Get(_requestIDHeader) != seen {
+		t.Fatalf("request ID = %q, response header %q; want the same generated UUID", seen, rec.Header().Get(_requestIDHeader))
+	}
+	if rec.Header().Get(_requestIDReplacedHeader) != "true" {
+		t.Fatal("the replacement isn't reported")
+	}
+}
+
+func TestRequestIDKeepsClientUUID(t *testing.T) {
+	const clientID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	seen, rec := serveRequestID(t, clientID)
+
+	if seen != clientID || rec.Header().Get(_requestIDReplacedHeader) != "" {
+		t.Fatalf("request ID = %q, replaced = %q; want the client UUID kept", seen, rec.Header().Get(_requestIDReplacedHeader))
+	}
+}
+
+func TestRequestIDGeneratedWhenAbsent(t *testing.T) {
+	seen, rec := serveRequestID(t, "")
+
+	if !isUUIDv4(seen) || rec.Header().Get(_requestIDReplacedHeader) != "" {
+		t.Fatalf("request ID = %q, replaced = %q; want a generated UUID, not reported as replaced", seen, rec.Header().Get(_requestIDReplacedHeader))
+	}
+}
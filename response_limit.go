@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// ErrResponseTooLarge is returned by the writes past Config.MaxResponseBodyBytes. They also
+// wrap ErrPartialWrite, since the response can't be answered with an error anymore.
+var ErrResponseTooLarge = errors.New("response body exceeds the size limit")
+
+var errResponseCut = fmt.Errorf("%w: %w", ErrPartialWrite, ErrResponseTooLarge)
+
+// ResponseBodyLimitMiddleware cuts the connection of responses whose body grows past limit
+// bytes, so a runaway endpoint can't stream gigabytes to a client.
+func ResponseBodyLimitMiddleware(limit int64, logger *zap.Logger) func(http.Handler) http.Handler {
+	logger = logger.Named("response_limit")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&LimitedResponseWriter{ResponseWriter: w, limit: limit, logger: logger, r: r}, r)
+		})
+	}
+}
+
+// LimitedResponseWriter counts the body bytes written and closes the connection as soon as
+// the limit is exceeded: the client sees a truncated response rather than a complete one.
+// HTTP/2 connections can't be hijacked, so the stream is reset instead.
+type LimitedResponseWriter struct {
+	http.ResponseWriter
+	limit    int64
+	written  int64
+	exceeded bool
+	logger   *zap.Logger
+	r        *http.Request
+}
+
+func (w *LimitedResponseWriter) Write(b []byte) (int, error) {
+	if w.exceeded {
+		return 0, errResponseCut
+	}
+	if w.written+int64(len(b)) > w.limit {
+		w.exceeded = true
+		w.abort()
+		return 0, errResponseCut
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *LimitedResponseWriter) abort() {
+	w.logger.Error("Response body exceeds the size limit, closing the connection",
+		zap.String("method", w.r.Method),
+		zap.String("path", w.r.URL.Path),
+		zap.Int64("limit", w.limit),
+		zap.Int64("written", w.written),
+	)
+
+	conn, _, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	_ = conn.Close()
+}
+
+func (w *LimitedResponseWriter) Flush() {
+	if w.exceeded {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *LimitedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
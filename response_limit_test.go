@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseBodyLimitCutsTheConnection(t *testing.T) {
+	t.Setenv("GSD_MAX_RESPONSE_BODY_BYTES", "1024")
+	a := NewTestAPIServer(t, withRoute("GET /export", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		chunk := []byte(strings.Repeat("x", 512))
+		for range 8 {
+			if _, err := w.Write(chunk); err != nil {
+				return err
+			}
+			http.NewResponseController(w).Flush()
+		}
+		return nil
+	}))
+
+	resp, err := http.Get(a.URL + "/export")
+	if err == nil {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr == nil && len(body) > 1024 {
+			t.Fatalf("read %d bytes, want the response cut at the 1024 bytes limit", len(body))
+		}
+	}
+
+	if n := a.Logs.FilterMessage("Response body exceeds the size limit, closing the connection").Len(); n != 1 {
+		t.Fatalf("logs = %v, want the cut response logged once", a.Logs.All())
+	}
+
+	// The server keeps serving
+	if code := getStatus(t, a.URL+"/livez"); code != http.StatusOK {
+		t.Fatalf("request after the cut response = %d, want 200", code)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetryOptions configure RetryMiddleware.
+type RetryOptions struct {
+	// MaxRetries is how many times a failed request is retried after the first attempt.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, doubled for every retry up to MaxDelay.
+	// The actual delay is drawn at random below it, so retries of many requests spread out.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxBodyBytes is the largest request body buffered for the retries; larger requests
+	// are served once.
+	MaxBodyBytes int64
+	// MaxResponseBytes is the largest response body buffered until the attempt is known to
+	// be the last; a larger one commits its attempt, streamed and not retried.
+	MaxResponseBytes int64
+}
+
+// RetryMiddleware retries the idempotent requests whose handler answered 502, 503 or 504
+// because of a transient upstream failure, with exponential backoff and jitter. The handler
+// tells those apart with MarkUpstreamFailure, or by returning a RetryableError: the server's
+// own 503s, e.g. while draining, are never retried. Requests are idempotent by method or
+// when they carry an Idempotency-Key header. The handler sees the attempt number of a retry
+// in the Retry-Attempt request header, to forward upstream. The responses are buffered until
+// the last attempt; a handler flushing, or writing more than MaxResponseBytes, commits its
+// attempt, which is then not retried.
+func RetryMiddleware(opts RetryOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if opts.MaxRetries <= 0 || !isIdempotent(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, opts.MaxBodyBytes+1))
+			if err != nil {
+				WriteJSON(w, http.StatusBadRequest, APIError{
+					Code:    http.StatusBadRequest,
+					Message: "failed to read the request body",
+				})
+				return
+			}
+			if int64(len(body)) > opts.MaxBodyBytes {
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var resp *bufferedResponse
+			for attempt := 0; ; attempt++ {
+				// Each attempt gets its own copy of the request, headers included
+				failure := new(upstreamFailure)
+				req := r.Clone(context.WithValue(r.Context(), upstreamFailureKey{}, failure))
+				if attempt > 0 {
+					req.Header.Set("Retry-Attempt", strconv.Itoa(attempt))
+				}
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				resp = newBufferedResponse(w, opts.MaxResponseBytes)
+				next.ServeHTTP(resp, req)
+
+				if resp.committed || attempt == opts.MaxRetries || !failure.Load() ||
+					!isRetryableStatus(resp.status) || !sleepContext(r.Context(), retryDelay(opts, attempt)) {
+					break
+				}
+			}
+			if !resp.committed {
+				resp.writeTo(w)
+			}
+		})
+	}
+}
+
+// upstreamFailure is set by MarkUpstreamFailure during an attempt of RetryMiddleware.
+type upstreamFailure struct {
+	atomic.Bool
+}
+
+type upstreamFailureKey struct{}
+
+// MarkUpstreamFailure tells RetryMiddleware that the 502, 503 or 504 answered to the request
+// of ctx comes from a transient upstream failure, and may be retried. It is a no-op outside
+// of the middleware.
+func MarkUpstreamFailure(ctx context.Context) {
+	if failure, ok := ctx.Value(upstreamFailureKey{}).(*upstreamFailure); ok {
+		failure.Store(true)
+	}
+}
+
+// isIdempotent reports whether r may be sent again without changing the outcome.
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return r.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// retryDelay draws the backoff before the retry following attempt, with full jitter.
+func retryDelay(opts RetryOptions, attempt int) time.Duration {
+	delay := opts.BaseDelay << attempt
+	if delay <= 0 || delay > opts.MaxDelay {
+		delay = opts.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay)
+}
+
+// sleepContext waits for d, and reports false when ctx ended first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readCloser reads from a Reader and closes the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bufferedResponse holds a response until it is known to be the one sent, or until the
+// handler flushes it or its body grows past limit: it is then committed, and writes straight
+// to w.
+type bufferedResponse struct {
+	w         http.ResponseWriter
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	limit     int64
+	committed bool
+}
+
+func newBufferedResponse(w http.ResponseWriter, limit int64) *bufferedResponse {
+	return &bufferedResponse{w: w, header: make(http.Header), limit: limit}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	if b.committed {
+		return b.w.Header()
+	}
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.committed {
+		return b.w.Write(p)
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	if int64(b.body.Len()+len(p)) > b.limit {
+		b.commit()
+		return b.w.Write(p)
+	}
+	return b.body.Write(p)
+}
+
+// Flush commits the response, then flushes it.
+func (b *bufferedResponse) Flush() {
+	b.commit()
+	_ = http.NewResponseController(b.w).Flush()
+}
+
+// commit writes what was buffered, once; the writes that follow go straight to w.
+func (b *bufferedResponse) commit() {
+	if !b.committed {
+		b.writeTo(b.w)
+		b.committed = true
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (b *bufferedResponse) Unwrap() http.ResponseWriter {
+	return b.w
+}
+
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	w.WriteHeader(b.status)
+	_, _ = w.Write(b.body.Bytes())
+}
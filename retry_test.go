@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// upstreamError is the error of a handler whose upstream failed transiently.
+type upstreamError struct {
+	err APIError
+}
+
+func (e upstreamError) Error() string   { return "upstream: " + e.err.Error() }
+func (e upstreamError) Unwrap() error   { return e.err }
+func (e upstreamError) Retryable() bool { return true }
+
+// newRetryServer serves fn at /items behind the retry middleware, counting its attempts.
+func newRetryServer(t *testing.T, fn func(attempt int32, w http.ResponseWriter, r *http.Request) error) (*testAPIServer, *atomic.Int32) {
+	t.Helper()
+	t.Setenv("GSD_MIDDLEWARE", "retry")
+	t.Setenv("GSD_RETRY_MAX_RETRIES", "3")
+	t.Setenv("GSD_RETRY_BASE_DELAY", "1ms")
+	t.Setenv("GSD_RETRY_MAX_DELAY", "5ms")
+
+	var attempts atomic.Int32
+	a := newUnstartedTestServer(t, withRoute("/items", func(w http.ResponseWriter, r *http.Request) error {
+		return fn(attempts.Add(1), w, r)
+	}))
+	return a, &attempts
+}
+
+func TestRetryUpstreamFailures(t *testing.T) {
+	unavailable := APIError{Code: http.StatusServiceUnavailable, Message: "upstream unavailable"}
+	tests := []struct {
+		name string
+		fail func(w http.ResponseWriter, r *http.Request) error
+	}{
+		{name: "marked", fail: func(w http.ResponseWriter, r *http.Request) error {
+			MarkUpstreamFailure(r.Context())
+			return unavailable
+		}},
+		{name: "retryable error", fail: func(w http.ResponseWriter, r *http.Request) error {
+			return upstreamError{unavailable}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lastAttemptHeader string
+			a, attempts := newRetryServer(t, func(attempt int32, w http.ResponseWriter, r *http.Request) error {
+				lastAttemptHeader = r.Header.Get("Retry-Attempt")
+				if attempt <= 2 {
+					return tt.fail(w, r)
+				}
+				return WriteJSON(w, http.StatusOK, "ok")
+			})
+
+			rec := a.serve(httptest.NewRequest(http.MethodGet, "/items", nil))
+			if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `"ok"` {
+				t.Fatalf("response = %d %s, want the third attempt's", rec.Code, rec.Body)
+			}
+			if n := attempts.Load(); n != 3 {
+				t.Fatalf("%d attempts, want 3", n)
+			}
+			if lastAttemptHeader != "2" {
+				t.Fatalf("Retry-Attempt = %q, want 2", lastAttemptHeader)
+			}
+		})
+	}
+}
+
+func TestRetrySkipsTheServersOwn503(t *testing.T) {
+	a, attempts := newRetryServer(t, func(int32, http.ResponseWriter, *http.Request) error {
+		return APIError{Code: http.StatusServiceUnavailable, Message: "read only"}
+	})
+
+	if rec := a.serve(httptest.NewRequest(http.MethodGet, "/items", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("%d attempts, want the unmarked 503 served once", n)
+	}
+}
+
+func TestRetryFlushCommitsTheAttempt(t *testing.T) {
+	a, attempts := newRetryServer(t, func(_ int32, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("partial "))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			return err
+		}
+		_, _ = w.Write([]byte("stream"))
+		MarkUpstreamFailure(r.Context())
+		return nil
+	})
+
+	rec := a.serve(httptest.NewRequest(http.MethodGet, "/items", nil))
+	if !rec.Flushed {
+		t.Fatal("response not flushed through the middleware")
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "partial stream" || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("response = %d %q %v, want the streamed one", rec.Code, rec.Body, rec.Header())
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("%d attempts, want the committed one only", n)
+	}
+}
+
+func TestRetryLargeResponseCommitsTheAttempt(t *testing.T) {
+	t.Setenv("GSD_RETRY_MAX_RESPONSE_BYTES", "16")
+	body := strings.Repeat("x", 64)
+	a, attempts := newRetryServer(t, func(_ int32, w http.ResponseWriter, r *http.Request) error {
+		MarkUpstreamFailure(r.Context())
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(body))
+		return nil
+	})
+
+	rec := a.serve(httptest.NewRequest(http.MethodGet, "/items", nil))
+	if rec.Code != http.StatusBadGateway || rec.Body.String() != body {
+		t.Fatalf("response = %d %q, want the streamed 502", rec.Code, rec.Body)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("%d attempts, want the response over the buffer cap served once", n)
+	}
+}
+
+func TestRetryLeavesTheIncomingRequestHeadersAlone(t *testing.T) {
+	a, _ := newRetryServer(t, func(attempt int32, w http.ResponseWriter, r *http.Request) error {
+		if attempt == 1 {
+			MarkUpstreamFailure(r.Context())
+			return APIError{Code: http.StatusServiceUnavailable, Message: "upstream unavailable"}
+		}
+		return WriteJSON(w, http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	if rec := a.serve(req); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the retry's 200", rec.Code)
+	}
+	if got := req.Header.Get("Retry-Attempt"); got != "" {
+		t.Fatalf("incoming request Retry-Attempt = %q, want it untouched", got)
+	}
+}
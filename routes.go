@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// Route is a route of the public listener and the metadata documenting it.
+type Route struct {
+	Pattern  string
+	Handler  apiFunc
+	Summary  string
+	Request  any
+	Response any
+	Hidden   bool
+
+	RequireJSON bool
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// RouteOption annotates a route registered with Handle.
+type RouteOption func(*Route)
+
+// WithSummary sets the one-line description of the route.
+func WithSummary(summary string) RouteOption {
+	return func(r *Route) {
+		r.Summary = summary
+	}
+}
+
+// WithRequestType documents the request body with the JSON shape of v, e.g. CreateItem{}.
+func WithRequestType(v any) RouteOption {
+	return func(r *Route) {
+		r.Request = v
+	}
+}
+
+// WithResponseType documents the successful response body with the JSON shape of v.
+func WithResponseType(v any) RouteOption {
+	return func(r *Route) {
+		r.Response = v
+	}
+}
+
+// Undocumented leaves the route out of the OpenAPI document.
+func Undocumented() RouteOption {
+	return func(r *Route) {
+		r.Hidden = true
+	}
+}
+
+// Handle registers fn on the public listener for pattern, using the http.ServeMux syntax.
+// Routes have to be registered before Run.
+func (a *APIServer) Handle(pattern string, fn apiFunc, opts ...RouteOption) {
+	route := Route{Pattern: pattern, Handler: fn}
+	for _, opt := range opts {
+		opt(&route)
+	}
+	a.routes = append(a.routes, route)
+}
+
+// Routes returns the routes registered on the public listener.
+func (a *APIServer) Routes() []Route {
+	return a.routes
+}
+
+func (a *APIServer) newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, route := range a.routes {
+		var h http.Handler = a.makeHTTPHandlerFunc(route.Handler)
+		if route.RequireJSON {
+			h = JSONContentTypeMiddleware(h)
+		}
+		if read, write := a.routeTimeouts(route); read != 0 || write != 0 {
+			h = DeadlineMiddleware(read, write)(h)
+		}
+		mux.Handle(route.Pattern, recordRoute(h))
+	}
+	return mux
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Reasons of a shutdown, as recorded on the shutdown span and duration metric.
+const (
+	ShutdownRequested   = "requested"    // the root context was done, e.g. on a signal
+	ShutdownServerError = "server_error" // the server failed to serve
+)
+
+// Runner drives a Server through its lifecycle: serve until the root context is done,
+// then drain, shut down and release resources.
+type Runner struct {
+	server Server
+	logger *zap.Logger
+
+	// DrainStrategy decides how long the drain phase waits. Defaults to a fixed delay.
+	DrainStrategy DrainStrategy
+	// DrainBudget bounds the whole drain phase: hooks, deregistration and strategy.
+	// Defaults to _maxDrainBudget.
+	DrainBudget time.Duration
+	// Tracer starts the shutdown span, whose events tell the story of the shutdown.
+	// Defaults to a no-op tracer.
+	Tracer trace.Tracer
+	// DryRun simulates the shutdown on the first signal instead of shutting down, logging how
+	// long each phase takes against its budget. The server keeps running until the next
+	// signal, listened to with Signals (SIGINT and SIGTERM by default), or until
+	// DryRunTimeout, then it really shuts down.
+	DryRun        bool
+	DryRunTimeout time.Duration
+	Signals       func(parent context.Context) (context.Context, context.CancelFunc)
+	// GoroutineThreshold is how many goroutines may still run once the shutdown is done
+	// before a leak is suspected and logged (zero disables the check). With StrictGoroutines,
+	// the shutdown fails instead.
+	GoroutineThreshold int
+	StrictGoroutines   bool
+	// RecordShutdown records the duration of the shutdown, from the signal to the telemetry
+	// flush, and why it happened. Optional.
+	RecordShutdown func(ctx context.Context, d time.Duration, reason string)
+	// ShutdownPeriod bounds the HTTP shutdown, resources and telemetry phases together.
+	// Defaults to _shutdownPeriod.
+	ShutdownPeriod time.Duration
+	// HardPeriod is waited once the shutdown is done, before Run returns and the process
+	// exits. Defaults to _shutdownHardPeriod.
+	HardPeriod time.Duration
+
+	deregistrars []Deregistrar
+}
+
+func NewRunner(server Server, logger *zap.Logger) *Runner {
+	return &Runner{
+		server:         server,
+		logger:         logger,
+		DrainStrategy:  FixedDelayDrain{Delay: _readinessDrainDelay},
+		DrainBudget:    _maxDrainBudget,
+		ShutdownPeriod: _shutdownPeriod,
+		DryRunTimeout:  _dryRunTimeout,
+		Tracer:         noop.NewTracerProvider().Tracer(""),
+		HardPeriod:     _shutdownHardPeriod,
+		Signals: func(parent context.Context) (context.Context, context.CancelFunc) {
+			return signal.NotifyContext(parent, syscall.SIGINT, syscall.SIGTERM)
+		},
+	}
+}
+
+// RegisterDeregistrar adds d to the deregistrations performed during the drain phase.
+func (r *Runner) RegisterDeregistrar(d Deregistrar) {
+	r.deregistrars = append(r.deregistrars, d)
+}
+
+// Run starts the server and blocks until rootCtx is done, then walks it through the graceful shutdown sequence.
+// A server failing to serve goes through the same sequence, and is reported as an error.
+func (r *Runner) Run(rootCtx context.Context) ShutdownReport {
+	srv, logger := r.server, r.logger
+	var report ShutdownReport
+
+	// By creating a separate context for the api server, we can control their lifecycle during shutdown
+	ongoingCtx, stopOngoingGracefully := context.WithCancelCause(context.Background())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := srv.Run(ongoingCtx); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	// Block until a signal is received, or the server fails and has to be shut down anyway
+	reason := ShutdownRequested
+	select {
+	case <-rootCtx.Done():
+		if r.DryRun {
+			r.dryRunUntilSignal()
+		}
+	case err := <-serveErr:
+		logger.Error("Server failed, shutting down", zap.Error(err))
+		report.Errors = append(report.Errors, fmt.Sprintf("serve: %v", err))
+		reason = ShutdownServerError
+	}
+	shutdownStart := time.Now()
+
+	_, span := r.Tracer.Start(context.Background(), "shutdown")
+
+	srv.InitiateShutdown() // Mark the server as shutting down
+	logger.Info("Receiving shutdown signal, shutting down.")
+
+	// The whole drain phase, strategy included, is bounded by the drain budget
+	span.AddEvent("drain started")
+	drainStart := time.Now()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), r.DrainBudget)
+	if err := srv.RunDrainHooks(drainCtx); err != nil {
+		logger.Error("Failed to run drain hooks", zap.Error(err))
+		report.Errors = append(report.Errors, fmt.Sprintf("drain hooks: %v", err))
+	}
+	r.deregister(drainCtx)
+
+	// InitiateShutdown and RunDrainHooks only return once the readiness flip and the drain
+	// hooks are done, whichever caller ran them, so no probe can see "ready" past this point.
+	// Give time for readiness check to propagate
+	waitStart := time.Now()
+	if err := r.DrainStrategy.Wait(drainCtx, drainState{Server: srv, start: drainStart}); err != nil {
+		logger.Warn("Drain strategy did not complete within the drain budget", zap.Error(err))
+	}
+	cancelDrain()
+	waited := time.Since(waitStart)
+	report.DrainStrategy = drainStrategyName(r.DrainStrategy)
+	report.DrainWaitedMS = float64(waited.Microseconds()) / 1000
+	report.phase("drain", drainStart, nil)
+	span.AddEvent("drain completed", trace.WithAttributes(
+		attribute.String("drain.strategy", report.DrainStrategy),
+		attribute.Int64("drain.waited_ms", waited.Milliseconds()),
+	))
+	logger.Info("Readiness check propagated, now waiting for ongoing requests to finish.",
+		zap.String("drain_strategy", report.DrainStrategy),
+		zap.Duration("drain_waited", waited),
+	)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), r.ShutdownPeriod)
+	defer cancel()
+
+	start := time.Now()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error("Failed to wait for ongoing requests to finish, waiting for forced cancellation")
+		report.ForcedCancelled = srv.InFlightRequests()
+	}
+	report.phase("http_shutdown", start, err)
+	span.AddEvent("http closed", trace.WithAttributes(attribute.Int64("requests.forced_cancelled", report.ForcedCancelled)))
+	stopOngoingGracefully(ErrServerShuttingDown) // Cancel ongoing requests context
+
+	// Shutdown application resources
+	start = time.Now()
+	err = srv.ShutdownResources(shutdownCtx)
+	if err != nil {
+		logger.Error("Failed to shut down api server resources", zap.Error(err))
+	}
+	report.phase("resources", start, err)
+	span.AddEvent("resources closed")
+
+	// The span has to end before the telemetry is flushed to be exported
+	for _, msg := range report.Errors {
+		span.RecordError(errors.New(msg))
+	}
+	if len(report.Errors) > 0 {
+		span.SetStatus(codes.Error, "shutdown failed")
+	}
+	// Recorded before the flush, for the data point to be exported with the rest
+	duration := time.Since(shutdownStart)
+	span.SetAttributes(
+		attribute.String("shutdown.reason", reason),
+		attribute.Float64("shutdown.duration_s", duration.Seconds()),
+	)
+	if r.RecordShutdown != nil {
+		r.RecordShutdown(context.Background(), duration, reason)
+	}
+	r.logStatus(span, report, reason, duration)
+	span.End()
+
+	// Flush telemetry last so spans and metrics of the whole shutdown are exported
+	start = time.Now()
+	err = srv.ShutdownTelemetry(shutdownCtx)
+	if err != nil {
+		logger.Error("Failed to shut down telemetry", zap.Error(err))
+	}
+	report.phase("telemetry", start, err)
+
+	report.RequestsServed = srv.RequestsServed()
+	r.checkGoroutines(&report)
+	report.Success = len(report.Errors) == 0
+
+	time.Sleep(r.HardPeriod)
+
+	return report
+}
+
+// RunGroup runs the server as a member of a caller's errgroup.Group:
+//
+//	g.Go(func() error { return runner.RunGroup(ctx) })
+//
+// It returns once the group context is cancelled and the graceful shutdown is done, with the
+// shutdown failures as error. A server failing to serve returns early, cancelling the group.
+func (r *Runner) RunGroup(ctx context.Context) error {
+	report := r.Run(ctx)
+	return report.Err()
+}
+
+// checkGoroutines records the goroutines left running. A high count means something
+// started during the lifetime of the server wasn't stopped by the shutdown.
+func (r *Runner) checkGoroutines(report *ShutdownReport) {
+	report.Goroutines = runtime.NumGoroutine()
+	if r.GoroutineThreshold <= 0 || report.Goroutines <= r.GoroutineThreshold {
+		return
+	}
+
+	r.logger.Warn("Goroutines still running after shutdown, they may have leaked",
+		zap.Int("goroutines", report.Goroutines),
+		zap.Int("threshold", r.GoroutineThreshold),
+	)
+	if r.StrictGoroutines {
+		report.Errors = append(report.Errors, fmt.Sprintf("goroutines: %d still running, threshold is %d", report.Goroutines, r.GoroutineThreshold))
+	}
+}
+
+// logStatus logs the outcome of the shutdown, at info when it was clean and at error when
+// requests had to be cancelled or a step failed. Logged from within the shutdown span and
+// before the telemetry flush, it reaches the OTel logs next to the shutdown trace.
+func (r *Runner) logStatus(span trace.Span, report ShutdownReport, reason string, d time.Duration) {
+	level := zapcore.InfoLevel
+	if report.ForcedCancelled > 0 || len(report.Errors) > 0 {
+		level = zapcore.ErrorLevel
+	}
+
+	sc := span.SpanContext()
+	fields := []zap.Field{
+		zap.String("reason", reason),
+		zap.Duration("duration", d),
+		zap.Int64("forced_cancelled", report.ForcedCancelled),
+		zap.Strings("errors", report.Errors),
+	}
+	if sc.IsValid() {
+		fields = append(fields,
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()),
+		)
+	}
+	r.logger.Log(level, "Shutdown status", fields...)
+}
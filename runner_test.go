@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/sync/errgroup"
+)
+
+// newTestRunner returns a Runner for srv that neither waits for the readiness to propagate
+// nor sleeps before returning.
+func newTestRunner(srv Server) *Runner {
+	runner := NewRunner(srv, zap.NewNop())
+	runner.DrainStrategy = FixedDelayDrain{}
+	runner.HardPeriod = 0
+	return runner
+}
+
+// runUntilStarted makes the mock serve until its context is cancelled, and returns a channel
+// closed once it started serving.
+func runUntilStarted(srv *MockAPIServer) <-chan struct{} {
+	started := make(chan struct{})
+	srv.RunFunc = func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return http.ErrServerClosed
+	}
+	return started
+}
+
+func TestRunnerShutdownSequence(t *testing.T) {
+	srv := NewMockAPIServer()
+	started := runUntilStarted(srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	report := newTestRunner(srv).Run(ctx)
+
+	want := []string{"Run", "InitiateShutdown", "RunDrainHooks", "Shutdown", "ShutdownResources", "ShutdownTelemetry"}
+	if got := srv.Calls(); !slices.Equal(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	if !report.Success {
+		t.Fatalf("report not successful: %v", report.Errors)
+	}
+}
+
+func TestRunnerReportsFailingSteps(t *testing.T) {
+	srv := NewMockAPIServer()
+	started := runUntilStarted(srv)
+	srv.RunDrainHooksFunc = func(context.Context) error { return errors.New("drain hook failed") }
+	srv.ShutdownResourcesFunc = func(context.Context) error { return errors.New("close failed") }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	report := newTestRunner(srv).Run(ctx)
+
+	// A failing step doesn't stop the sequence
+	if got := srv.Calls(); got[len(got)-1] != "ShutdownTelemetry" {
+		t.Fatalf("calls = %v, want the sequence to reach ShutdownTelemetry", got)
+	}
+	want := []string{"drain hooks: drain hook failed", "resources: close failed"}
+	if report.Success || !slices.Equal(report.Errors, want) {
+		t.Fatalf("report errors = %q, want %q", report.Errors, want)
+	}
+}
+
+func TestRunnerShutsDownWhenServerFails(t *testing.T) {
+	srv := NewMockAPIServer()
+	srv.RunFunc = func(context.Context) error { return errors.New("address already in use") }
+
+	report := newTestRunner(srv).Run(context.Background())
+
+	if report.Success || len(report.Errors) != 1 {
+		t.Fatalf("report = %+v, want the serve error", report)
+	}
+	if got := srv.Calls(); !slices.Contains(got, "ShutdownTelemetry") {
+		t.Fatalf("calls = %v, want the full shutdown sequence", got)
+	}
+}
+
+func TestRunnerRecordsShutdownSpanEvents(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	srv := NewMockAPIServer()
+	runner := newTestRunner(srv)
+	runner.Tracer = provider.Tracer("test")
+
+	runMockUntilCancelled(t, runner, srv)
+
+	var events []string
+	for _, span := range recorder.Ended() {
+		if span.Name() != "shutdown" {
+			continue
+		}
+		for _, event := range span.Events() {
+			events = append(events, event.Name)
+		}
+	}
+	want := []string{"drain started", "drain completed", "http closed", "resources closed"}
+	if !slices.Equal(events, want) {
+		t.Fatalf("shutdown span events = %v, want %v", events, want)
+	}
+}
+
+func TestRunGroupShutsDownWithTheGroup(t *testing.T) {
+	srv := NewMockAPIServer()
+	started := runUntilStarted(srv)
+	runner := newTestRunner(srv)
+
+	errSibling := errors.New("sibling server failed")
+	g, ctx := errgroup.WithContext(context.Background())
+	g.Go(func() error { return runner.RunGroup(ctx) })
+	g.Go(func() error {
+		<-started
+		return errSibling // cancels the group context
+	})
+
+	if err := g.Wait(); !errors.Is(err, errSibling) {
+		t.Fatalf("group error = %v, want the sibling failure", err)
+	}
+	if got := srv.Calls(); !slices.Contains(got, "Shutdown") || got[len(got)-1] != "ShutdownTelemetry" {
+		t.Fatalf("calls = %v, want the graceful shutdown sequence", got)
+	}
+}
+
+func TestRunGroupFailureCancelsTheGroup(t *testing.T) {
+	srv := NewMockAPIServer()
+	srv.RunFunc = func(context.Context) error { return errors.New("address already in use") }
+
+	g, ctx := errgroup.WithContext(context.Background())
+	g.Go(func() error { return newTestRunner(srv).RunGroup(ctx) })
+	g.Go(func() error {
+		<-ctx.Done() // a sibling serving until the group stops
+		return nil
+	})
+
+	if err := g.Wait(); err == nil || !strings.Contains(err.Error(), "address already in use") {
+		t.Fatalf("group error = %v, want the serve failure", err)
+	}
+}
+
+func TestRunnerChecksGoroutinesLeftRunning(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		strict    bool
+		warned    bool
+		failed    bool
+	}{
+		{name: "disabled"},
+		{name: "under threshold", threshold: 1 << 20, strict: true},
+		{name: "above threshold", threshold: 1, warned: true},
+		{name: "above threshold strict", threshold: 1, strict: true, warned: true, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewMockAPIServer()
+			srv.RunFunc = func(ctx context.Context) error {
+				<-ctx.Done()
+				return http.ErrServerClosed
+			}
+			core, logs := observer.New(zapcore.WarnLevel)
+			runner := newTestRunner(srv)
+			runner.logger = zap.New(core)
+			runner.GoroutineThreshold, runner.StrictGoroutines = tt.threshold, tt.strict
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			report := runner.Run(ctx)
+
+			if report.Goroutines <= 0 {
+				t.Fatalf("report goroutines = %d, want the count left running", report.Goroutines)
+			}
+			warnings := logs.FilterMessage("Goroutines still running after shutdown, they may have leaked").All()
+			if (len(warnings) == 1) != tt.warned {
+				t.Fatalf("leak warnings = %d, want warned %v", len(warnings), tt.warned)
+			}
+			if tt.warned && warnings[0].ContextMap()["goroutines"] != int64(report.Goroutines) {
+				t.Fatalf("warning fields = %v, want goroutines %d", warnings[0].ContextMap(), report.Goroutines)
+			}
+			if report.Success == tt.failed {
+				t.Fatalf("report success = %v, errors %q: want failed %v", report.Success, report.Errors, tt.failed)
+			}
+		})
+	}
+}
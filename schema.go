@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// _maxValidatedBodyBytes bounds the bodies buffered for validation.
+const _maxValidatedBodyBytes = 1 << 20
+
+// FieldError is a violation of the request schema at Path, a JSON pointer into the body.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the violations of a request body.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// NewValidationError returns a ValidationError with fields. Handlers return it as is (see
+// Add) and the client receives a 422 listing every field error.
+func NewValidationError(fields ...FieldError) *ValidationError {
+	return &ValidationError{Fields: fields}
+}
+
+// Add records a field error at path, a JSON pointer into the body such as "/items/0/name".
+func (e *ValidationError) Add(path, message string) {
+	e.Fields = append(e.Fields, FieldError{Path: path, Message: message})
+}
+
+// HasErrors reports whether any field error was recorded.
+func (e *ValidationError) HasErrors() bool {
+	return len(e.Fields) > 0
+}
+
+// APIError is the 422 response carrying the field errors in its details.
+func (e *ValidationError) APIError() APIError {
+	return APIError{
+		Code:    http.StatusUnprocessableEntity,
+		Message: "the request is invalid",
+		Details: e,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = fmt.Sprintf("%s: %s", f.Path, f.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// SchemaValidatorMiddleware validates the JSON bodies of the requests matching the keys of
+// schemas, such as "POST /items", against the JSON schema they map to. Schemas are compiled
+// here, an invalid one panics. Invalid bodies are rejected with a ValidationError in the
+// details of a 422 response; the handler reads the body as if it was never validated.
+func SchemaValidatorMiddleware(schemas map[string][]byte) func(http.Handler) http.Handler {
+	compiled := make(map[string]*jsonschema.Schema, len(schemas))
+	compiler := jsonschema.NewCompiler()
+	for route, raw := range schemas {
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+		if err != nil {
+			panic(fmt.Errorf("schema for %q: %w", route, err))
+		}
+		url := "mem://schemas/" + strings.ReplaceAll(route, " ", "")
+		if err := compiler.AddResource(url, doc); err != nil {
+			panic(fmt.Errorf("schema for %q: %w", route, err))
+		}
+		if compiled[route], err = compiler.Compile(url); err != nil {
+			panic(fmt.Errorf("schema for %q: %w", route, err))
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			schema, ok := compiled[r.Method+" "+r.URL.Path]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, _maxValidatedBodyBytes))
+			if err != nil {
+				writeSchemaError(w, r, APIError{
+					Code:    http.StatusBadRequest,
+					Message: "failed to read the request body",
+				})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+			if err != nil {
+				writeSchemaError(w, r, APIError{
+					Code:    http.StatusBadRequest,
+					Message: "the request body is not valid JSON",
+				})
+				return
+			}
+
+			if err := schema.Validate(instance); err != nil {
+				var schemaErr *jsonschema.ValidationError
+				if !errors.As(err, &schemaErr) {
+					writeSchemaError(w, r, APIError{
+						Code:    http.StatusInternalServerError,
+						Message: "failed to validate the request body",
+					})
+					return
+				}
+				writeSchemaError(w, r, validationErrorOf(schemaErr).APIError())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeSchemaError writes apiErr through the server handling r, if any, so it's counted and
+// rendered like the errors returned by handlers.
+func writeSchemaError(w http.ResponseWriter, r *http.Request, apiErr APIError) {
+	if a, ok := ServerFromContext(r.Context()); ok {
+		a.writeError(w, r, apiErr)
+		return
+	}
+	WriteJSON(w, apiErr.Code, apiErr)
+}
+
+func validationErrorOf(err *jsonschema.ValidationError) *ValidationError {
+	out := err.BasicOutput()
+	units := out.Errors
+	if len(units) == 0 {
+		units = []jsonschema.OutputUnit{*out}
+	}
+
+	verr := &ValidationError{}
+	for _, unit := range units {
+		if unit.Error == nil {
+			continue
+		}
+		path := unit.InstanceLocation
+		if path == "" {
+			path = "/"
+		}
+		verr.Fields = append(verr.Fields, FieldError{Path: path, Message: unit.Error.String()})
+	}
+	return verr
+}
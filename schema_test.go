@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const _itemSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"properties": {"name": {"type": "string"}, "qty": {"type": "integer", "minimum": 1}}
+}`
+
+func newSchemaHandler(t *testing.T) http.Handler {
+	t.Helper()
+	return SchemaValidatorMiddleware(map[string][]byte{"POST /items": []byte(_itemSchema)})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		}))
+}
+
+func TestSchemaValidatorRejectsInvalidBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newSchemaHandler(t).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":5,"qty":0}`)))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	var resp struct {
+		Details ValidationError `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string]bool{}
+	for _, f := range resp.Details.Fields {
+		paths[f.Path] = true
+	}
+	if !paths["/name"] || !paths["/qty"] {
+		t.Fatalf("field errors = %+v, want /name and /qty", resp.Details.Fields)
+	}
+}
+
+func TestSchemaValidatorPassesValidBody(t *testing.T) {
+	const body = `{"name":"bolt","qty":3}`
+	rec := httptest.NewRecorder()
+	newSchemaHandler(t).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("response = %d %q, want the body echoed by the handler", rec.Code, rec.Body)
+	}
+}
+
+func TestHandlerValidationErrorIs422(t *testing.T) {
+	a := newUnstartedTestServer(t, withRoute("POST /users", func(w http.ResponseWriter, r *http.Request) error {
+		verr := NewValidationError(FieldError{Path: "/email", Message: "must be an email address"})
+		verr.Add("/age", "must be positive")
+		return verr
+	}))
+
+	rec := a.serve(httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`)))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	var resp struct {
+		Code    int             `json:"code"`
+		Details ValidationError `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldError{{Path: "/email", Message: "must be an email address"}, {Path: "/age", Message: "must be positive"}}
+	if resp.Code != http.StatusUnprocessableEntity || !slices.Equal(resp.Details.Fields, want) {
+		t.Fatalf("response = %+v, want the two field errors", resp)
+	}
+}
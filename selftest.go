@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	_selfTestHeader  = "X-GSD-Selftest"
+	_selfTestPath    = "/_selftest/echo"
+	_selfTestTimeout = 5 * time.Second
+)
+
+type SelfTestStage struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+type SelfTestReport struct {
+	Passed    bool            `json:"passed"`
+	LatencyMS float64         `json:"latency_ms"`
+	Stages    []SelfTestStage `json:"stages"`
+}
+
+type selfTestEchoResponse struct {
+	Marker string `json:"marker"`
+}
+
+func (r *SelfTestReport) add(name string, err error) bool {
+	stage := SelfTestStage{Name: name, Passed: err == nil}
+	if err != nil {
+		stage.Error = err.Error()
+	}
+	r.Stages = append(r.Stages, stage)
+	return err == nil
+}
+
+// handleSelfTest sends a request through the public handler chain in process, so the
+// instrumentation, the middlewares and the routing are exercised whatever the listener
+// requires from clients (TLS, client certificates), and reports each stage.
+// Pass ?readiness=true to also run the registered health checks.
+func (a *APIServer) handleSelfTest(w http.ResponseWriter, r *http.Request) error {
+	if r.Header.Get(_selfTestHeader) != "" {
+		return APIError{
+			Code:    http.StatusLoopDetected,
+			Message: "self-test requests cannot trigger a self-test",
+		}
+	}
+
+	report := a.runSelfTest(r)
+	status := http.StatusOK
+	if !report.Passed {
+		status = http.StatusServiceUnavailable
+	}
+	return WriteJSON(w, status, report)
+}
+
+func (a *APIServer) runSelfTest(r *http.Request) SelfTestReport {
+	var report SelfTestReport
+
+	marker, err := newSelfTestMarker()
+	if !report.add("marker", err) {
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), _selfTestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, _selfTestPath, nil)
+	if !report.add("request", err) {
+		return report
+	}
+	req.Host = fmt.Sprintf("127.0.0.1:%d", a.Config.Port)
+	req.Header.Set(_selfTestHeader, marker)
+
+	start := time.Now()
+	resp := a.serveInternal(req)
+	report.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if !report.add("round_trip", ctx.Err()) {
+		return report
+	}
+
+	if resp.status != http.StatusOK {
+		err = fmt.Errorf("unexpected status %d", resp.status)
+	}
+	if !report.add("status", err) {
+		return report
+	}
+
+	var echo selfTestEchoResponse
+	err = json.NewDecoder(&resp.body).Decode(&echo)
+	if err == nil && echo.Marker != marker {
+		err = fmt.Errorf("marker was not preserved through the handler chain")
+	}
+	if !report.add("echo", err) {
+		return report
+	}
+
+	if r.URL.Query().Get("readiness") == "true" {
+		if name, err := a.runHealthChecks(ctx); err != nil {
+			report.add("readiness", fmt.Errorf("%s: %w", name, err))
+			return report
+		}
+		report.add("readiness", nil)
+	}
+
+	report.Passed = true
+	return report
+}
+
+// handleSelfTestEcho echoes the self-test marker back. Without the marker the route doesn't exist.
+func (a *APIServer) handleSelfTestEcho(w http.ResponseWriter, r *http.Request) error {
+	marker := r.Header.Get(_selfTestHeader)
+	if marker == "" {
+		return a.handleNotFound(w, r)
+	}
+	return WriteJSON(w, http.StatusOK, selfTestEchoResponse{Marker: marker})
+}
+
+func newSelfTestMarker() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
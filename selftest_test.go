@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func runAdminSelfTest(t *testing.T, a *testAPIServer, target string) (int, SelfTestReport) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	a.adminMux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var report SelfTestReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	return rec.Code, report
+}
+
+func TestSelfTestGoesThroughThePublicChain(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "request_id,recovery,logging,metrics")
+	a := NewTestAPIServer(t)
+
+	status, report := runAdminSelfTest(t, a, "/admin/selftest?readiness=true")
+	if status != http.StatusOK || !report.Passed {
+		t.Fatalf("self-test = %d %+v, want it to pass", status, report)
+	}
+
+	var names []string
+	for _, stage := range report.Stages {
+		names = append(names, stage.Name)
+	}
+	want := []string{"marker", "request", "round_trip", "status", "echo", "readiness"}
+	if len(names) != len(want) {
+		t.Fatalf("stages = %v, want %v", names, want)
+	}
+	if a.RequestsServed() == 0 {
+		t.Fatal("the self-test request was not served by the public handler chain")
+	}
+}
+
+func TestSelfTestEchoHiddenWithoutMarker(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	rec := a.serve(httptest.NewRequest(http.MethodGet, _selfTestPath, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("echo without marker = %d, want 404", rec.Code)
+	}
+}
+
+func TestSelfTestRefusesLoops(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/selftest", nil)
+	req.Header.Set(_selfTestHeader, "marker")
+	rec := httptest.NewRecorder()
+	a.adminMux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusLoopDetected {
+		t.Fatalf("self-test from a self-test = %d, want 508", rec.Code)
+	}
+}
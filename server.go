@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Server is the lifecycle surface driven by main: start serving, flip readiness,
+// drain ongoing requests, release resources and finally flush telemetry.
+type Server interface {
+	Run(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+	InitiateShutdown()
+	RunDrainHooks(ctx context.Context) error
+	ShutdownResources(ctx context.Context) error
+	ShutdownTelemetry(ctx context.Context) error
+
+	InFlightRequests() int64
+	ActiveWorkerSpans() int
+	RequestsServed() int64
+	HookNames() (drain, shutdown []string)
+	LastProbe() (ProbeInfo, bool)
+	LastScrape() (time.Time, bool)
+}
+
+var _ Server = (*APIServer)(nil)
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// registerNotReadyGauge exposes when the readiness probe started failing for the shutdown,
+// so a metric-driven load balancer or controller can act on the transition itself.
+// Nothing is reported while the server is ready.
+func (a *APIServer) registerNotReadyGauge() {
+	_, err := a.meter().Float64ObservableGauge(
+		"readiness.not_ready.timestamp",
+		metric.WithDescription("Unix time at which the readiness probe flipped to not ready for the shutdown."),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			if at := a.notReadyAt.Load(); at != 0 {
+				o.Observe(float64(at) / float64(time.Second))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+// registerDrainProgressGauge exposes the share of the requests in flight at the readiness
+// flip that completed since, from 0 to 1, so dashboards follow the drain as it happens.
+// Requests arriving after the flip hold it back; nothing is reported before the shutdown.
+func (a *APIServer) registerDrainProgressGauge() {
+	_, err := a.meter().Float64ObservableGauge(
+		"shutdown.drain.progress",
+		metric.WithDescription("Fraction of the requests in flight at the start of the drain that completed."),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			if a.notReadyAt.Load() != 0 {
+				o.Observe(drainProgress(a.drainInFlight.Load(), a.inFlight.Load()))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+// drainProgress is the completed share of the start requests, with current still in flight.
+func drainProgress(start, current int64) float64 {
+	if start == 0 {
+		return 1
+	}
+	return float64(start-min(current, start)) / float64(start)
+}
+
+// registerShutdownDuration creates the histogram of the shutdown durations, exposed to
+// Prometheus as shutdown_duration_seconds.
+func (a *APIServer) registerShutdownDuration() {
+	hist, err := a.meter().Float64Histogram(
+		"shutdown.duration",
+		metric.WithDescription("Duration of the graceful shutdown, from the signal to the telemetry flush."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(1, 2.5, 5, 7.5, 10, 15, 20, 30, 60),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	a.shutdownDuration = hist
+}
+
+// RecordShutdownDuration records one shutdown that took d, for reason.
+func (a *APIServer) RecordShutdownDuration(ctx context.Context, d time.Duration, reason string) {
+	a.shutdownDuration.Record(ctx, d.Seconds(), metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// LastScrape returns when /metrics was last served, if ever.
+func (a *APIServer) LastScrape() (time.Time, bool) {
+	at := a.lastScrape.Load()
+	if at == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, at), true
+}
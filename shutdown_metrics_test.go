@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestNotReadyTimestampRecordedAtDrainStart(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	// Nothing is reported while the server is ready
+	if data, ok := findMetric(t, a.Metrics, "readiness.not_ready.timestamp"); ok && len(data.(metricdata.Gauge[float64]).DataPoints) > 0 {
+		t.Fatalf("not ready timestamp reported before the shutdown: %+v", data)
+	}
+
+	before := time.Now()
+	a.InitiateShutdown()
+	after := time.Now()
+
+	points := a.metric(t, "readiness.not_ready.timestamp").(metricdata.Gauge[float64]).DataPoints
+	if len(points) != 1 {
+		t.Fatalf("got %d data points, want 1", len(points))
+	}
+	at := time.Unix(0, int64(points[0].Value*float64(time.Second)))
+	// The float64 seconds keep the timestamp to the microsecond
+	if at.Before(before.Add(-time.Millisecond)) || at.After(after.Add(time.Millisecond)) {
+		t.Fatalf("not ready at %v, want between %v and %v", at, before, after)
+	}
+}
+
+func TestShutdownDurationRecordedOnce(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	srv := NewMockAPIServer()
+	srv.RunFunc = func(ctx context.Context) error {
+		<-ctx.Done()
+		return http.ErrServerClosed
+	}
+	runner := newTestRunner(srv)
+	runner.RecordShutdown = a.RecordShutdownDuration
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner.Run(ctx)
+
+	points := a.metric(t, "shutdown.duration").(metricdata.Histogram[float64]).DataPoints
+	if len(points) != 1 || points[0].Count != 1 {
+		t.Fatalf("data points = %+v, want one shutdown recorded", points)
+	}
+	if reason, _ := points[0].Attributes.Value("reason"); reason.AsString() != ShutdownRequested {
+		t.Fatalf("reason = %q, want %q", reason.AsString(), ShutdownRequested)
+	}
+}
+
+func TestDrainProgressFollowsCompletedRequests(t *testing.T) {
+	release := make(chan struct{})
+	a := newUnstartedTestServer(t, withRoute("GET /slow", func(w http.ResponseWriter, r *http.Request) error {
+		<-release
+		return nil
+	}))
+	progress := func() []metricdata.DataPoint[float64] {
+		data, ok := findMetric(t, a.Metrics, "shutdown.drain.progress")
+		if !ok {
+			return nil
+		}
+		return data.(metricdata.Gauge[float64]).DataPoints
+	}
+	waitInFlight := func(n int64) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for a.InFlightRequests() != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("in-flight requests = %d, want %d", a.InFlightRequests(), n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() { a.serve(httptest.NewRequest(http.MethodGet, "/slow", nil)) })
+	}
+	defer wg.Wait()
+	waitInFlight(4)
+	if points := progress(); len(points) != 0 {
+		t.Fatalf("drain progress reported before the shutdown: %+v", points)
+	}
+
+	a.InitiateShutdown()
+	for _, step := range []struct {
+		release int
+		want    float64
+	}{{0, 0}, {2, 0.5}, {2, 1}} {
+		for range step.release {
+			release <- struct{}{}
+		}
+		waitInFlight(int64(4 - 4*step.want))
+		points := progress()
+		if len(points) != 1 || points[0].Value != step.want {
+			t.Fatalf("drain progress = %+v, want %v", points, step.want)
+		}
+	}
+}
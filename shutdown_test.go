@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOnShutdownCompleteFiresOnceAfterShutdown(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	var closed bool
+	a.RegisterShutdownHook("resource", func(context.Context) error {
+		closed = true
+		return nil
+	})
+	var fired int
+	a.OnShutdownComplete(func() {
+		if !closed {
+			t.Error("hook fired before the resources were closed")
+		}
+		fired++
+	})
+
+	if err := a.ShutdownResources(context.Background()); err != nil {
+		t.Fatalf("ShutdownResources: %v", err)
+	}
+	if fired != 0 {
+		t.Fatalf("hook fired %d times before shutdownComplete", fired)
+	}
+	a.shutdownComplete()
+	a.shutdownComplete()
+	if fired != 1 {
+		t.Fatalf("hook fired %d times, want 1", fired)
+	}
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporarily unavailable" }
+func (temporaryError) Retryable() bool { return true }
+
+func TestShutdownHookRetriedUntilItSucceeds(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	var attempts int
+	a.RegisterShutdownHook("flaky", func(context.Context) error {
+		attempts++
+		if attempts <= 2 {
+			return temporaryError{}
+		}
+		return nil
+	})
+
+	if err := a.ShutdownResources(context.Background()); err != nil {
+		t.Fatalf("ShutdownResources: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("hook attempted %d times, want 3", attempts)
+	}
+}
+
+func TestShutdownHookNotRetriedOnCancellation(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	var attempts int
+	a.RegisterShutdownHook("cancelled", func(context.Context) error {
+		attempts++
+		return context.Canceled
+	})
+
+	if err := a.ShutdownResources(context.Background()); err == nil {
+		t.Fatal("ShutdownResources succeeded with a failing hook")
+	}
+	if attempts != 1 {
+		t.Fatalf("hook attempted %d times, want 1", attempts)
+	}
+}
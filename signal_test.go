@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// cancelledSignalContext simulates a signal received right away.
+func cancelledSignalContext(parent context.Context, _ ...os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	cancel()
+	return ctx, cancel
+}
+
+func TestInjectedSignalRunsFullShutdown(t *testing.T) {
+	t.Setenv("GSD_PORT", strconv.Itoa(freePort(t)))
+	a := newUnstartedTestServer(t, WithSignalContext(cancelledSignalContext))
+	var closed bool
+	a.RegisterShutdownHook("db", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	rootCtx, stop := a.SignalContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	runner := newTestRunner(a.APIServer)
+	report := runner.Run(rootCtx)
+
+	if !report.Success {
+		t.Fatalf("shutdown failed: %v", report.Errors)
+	}
+	var phases []string
+	for _, p := range report.Phases {
+		phases = append(phases, p.Name)
+	}
+	if want := []string{"drain", "http_shutdown", "resources", "telemetry"}; !slices.Equal(phases, want) {
+		t.Fatalf("phases = %v, want %v", phases, want)
+	}
+	if !closed || !a.isShuttingDown.Load() {
+		t.Fatal("the shutdown didn't go through the server")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// routeLatencies sums the request durations per route since the last snapshot. The server
+// aggregates the durations observed by its access log middleware in one of them.
+type routeLatencies struct {
+	mu     sync.Mutex
+	routes map[string]*routeLatency
+}
+
+type routeLatency struct {
+	count int64
+	total time.Duration
+	max   time.Duration
+}
+
+func newRouteLatencies() *routeLatencies {
+	return &routeLatencies{routes: make(map[string]*routeLatency)}
+}
+
+func (l *routeLatencies) observe(route string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rl, ok := l.routes[route]
+	if !ok {
+		rl = &routeLatency{}
+		l.routes[route] = rl
+	}
+	rl.count++
+	rl.total += d
+	rl.max = max(rl.max, d)
+}
+
+// SlowRoute is the latency of a route over a reporting interval.
+type SlowRoute struct {
+	Route    string        `json:"route"`
+	Requests int64         `json:"requests"`
+	Average  time.Duration `json:"average"`
+	Max      time.Duration `json:"max"`
+}
+
+// slowest returns the n routes with the highest average latency and starts a new interval.
+func (l *routeLatencies) slowest(n int) []SlowRoute {
+	l.mu.Lock()
+	routes := l.routes
+	l.routes = make(map[string]*routeLatency)
+	l.mu.Unlock()
+
+	slow := make([]SlowRoute, 0, len(routes))
+	for route, rl := range routes {
+		slow = append(slow, SlowRoute{
+			Route:    route,
+			Requests: rl.count,
+			Average:  rl.total / time.Duration(rl.count),
+			Max:      rl.max,
+		})
+	}
+	slices.SortFunc(slow, func(a, b SlowRoute) int {
+		return cmp.Compare(b.Average, a.Average)
+	})
+	return slow[:min(n, len(slow))]
+}
+
+// TopSlowRoutesReporter logs the n routes served by a with the highest average latency every
+// interval. start begins the reporting goroutine and stop ends it; both are meant to be
+// called once.
+func (a *APIServer) TopSlowRoutesReporter(n int, interval time.Duration, logger *zap.Logger) (start func(), stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	start = func() {
+		go func() {
+			defer close(done)
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					if slow := a.routeLatencies.slowest(n); len(slow) > 0 {
+						logger.Info("Slowest routes", zap.Duration("interval", interval), zap.Any("routes", slow))
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	stop = func() {
+		cancel()
+		<-done
+	}
+	return start, stop
+}
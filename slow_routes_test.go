@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowRoutesObservedPerServer(t *testing.T) {
+	t.Setenv("GSD_MIDDLEWARE", "logging")
+	slow := withRoute("GET /slow", func(http.ResponseWriter, *http.Request) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	fast := withRoute("GET /fast", func(http.ResponseWriter, *http.Request) error { return nil })
+	a := newUnstartedTestServer(t, slow, fast)
+	other := newUnstartedTestServer(t, slow, fast)
+
+	a.serve(httptest.NewRequest(http.MethodGet, "/fast", nil))
+	a.serve(httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	got := a.routeLatencies.slowest(1)
+	if len(got) != 1 || got[0].Route != "GET /slow" || got[0].Requests != 1 {
+		t.Fatalf("slowest routes = %+v, want GET /slow", got)
+	}
+	if len(a.routeLatencies.slowest(1)) != 0 {
+		t.Fatal("slowest didn't start a new interval")
+	}
+	// Each server aggregates its own requests
+	if got := other.routeLatencies.slowest(2); len(got) != 0 {
+		t.Fatalf("another server observed %+v", got)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// ErrStartupTimeout cancels the initialization steps still running after Config.StartupTimeout.
+var ErrStartupTimeout = errors.New("startup timed out")
+
+// startupContext is cancelled by SIGINT or SIGTERM, or once Config.StartupTimeout has elapsed,
+// so a process stuck dialing a dependency can still be stopped.
+func (a *APIServer) startupContext() (context.Context, context.CancelFunc) {
+	parent := a.startupParent
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, stopSignals := a.SignalContext(parent, syscall.SIGINT, syscall.SIGTERM)
+	if a.Config.StartupTimeout <= 0 {
+		return ctx, stopSignals
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, a.Config.StartupTimeout, ErrStartupTimeout)
+	return ctx, func() {
+		cancel()
+		stopSignals()
+	}
+}
+
+// initStep runs one blocking initialization step with the startup context. A step that
+// ignores ctx is abandoned when the context ends instead of hanging NewAPIServer.
+func initStep[T any](ctx context.Context, name string, fn func(context.Context) (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn(ctx)
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.value, fmt.Errorf("init %s: %w", name, r.err)
+		}
+		return r.value, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, fmt.Errorf("init %s: %w", name, context.Cause(ctx))
+	}
+}
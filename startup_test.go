@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInitStepAbortedByCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	errCancelled := errors.New("shutdown signal")
+	time.AfterFunc(20*time.Millisecond, func() { cancel(errCancelled) })
+
+	start := time.Now()
+	_, err := initStep(ctx, "slow_dial", func(context.Context) (int, error) {
+		<-stuck // a dial ignoring its context
+		return 0, nil
+	})
+	if !errors.Is(err, errCancelled) {
+		t.Fatalf("initStep = %v, want the cancellation cause", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("initStep returned after %v, want it aborted on cancellation", elapsed)
+	}
+}
+
+func TestStartupTimeoutAbortsSlowInitStep(t *testing.T) {
+	t.Setenv("GSD_STARTUP_TIMEOUT", "20ms")
+	a := newUnstartedTestServer(t)
+
+	ctx, cancel := a.startupContext()
+	defer cancel()
+	_, err := initStep(ctx, "otel", func(ctx context.Context) (struct{}, error) {
+		<-ctx.Done()
+		return struct{}{}, ctx.Err()
+	})
+	if !errors.Is(err, ErrStartupTimeout) {
+		t.Fatalf("initStep = %v, want ErrStartupTimeout", err)
+	}
+}
+
+func TestInitStepReturnsTheStepResult(t *testing.T) {
+	errDial := errors.New("connection refused")
+	if _, err := initStep(t.Context(), "otel", func(context.Context) (int, error) { return 0, errDial }); !errors.Is(err, errDial) {
+		t.Fatalf("initStep = %v, want the step error", err)
+	}
+	if v, err := initStep(t.Context(), "otel", func(context.Context) (int, error) { return 42, nil }); err != nil || v != 42 {
+		t.Fatalf("initStep = %d, %v, want 42", v, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+//go:embed templates/status.html
+var _templatesFS embed.FS
+
+type statusPageData struct {
+	Status     int
+	StatusText string
+	Message    string
+	RetryAfter int
+	RequestID  string
+	Version    string
+}
+
+// loadStatusPageTemplate parses the template at path, or the embedded default when path is empty.
+func loadStatusPageTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.ParseFS(_templatesFS, "templates/status.html")
+	}
+	return template.ParseFiles(path)
+}
+
+// wantsStatusPage reports whether an error with the given status should be rendered as HTML.
+// Only the pages a human may hit during a deploy (drain, maintenance, not found) are rendered.
+func (a *APIServer) wantsStatusPage(r *http.Request, status int) bool {
+	if a.statusPage == nil {
+		return false
+	}
+	if status != http.StatusServiceUnavailable && status != http.StatusNotFound {
+		return false
+	}
+	return prefersHTML(r.Header.Get("Accept"))
+}
+
+// writeStatusPage renders apiErr as an HTML status page. The template is executed into a
+// buffer so a failing template leaves the response untouched and the caller can fall back to JSON.
+func (a *APIServer) writeStatusPage(w http.ResponseWriter, r *http.Request, apiErr APIError) error {
+	data := statusPageData{
+		Status:     apiErr.Code,
+		StatusText: http.StatusText(apiErr.Code),
+		Message:    apiErr.Message,
+		RequestID:  r.Header.Get(_requestIDHeader),
+		Version:    _serviceVersion,
+	}
+	if apiErr.Code == http.StatusServiceUnavailable {
+		data.RetryAfter = int(_readinessDrainDelay.Seconds())
+	}
+
+	var buf bytes.Buffer
+	if err := a.statusPage.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if data.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(data.RetryAfter))
+	}
+	w.WriteHeader(apiErr.Code)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	_browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	_apiAccept     = "application/json"
+)
+
+func TestStatusPageFormatFollowsAccept(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		accept     string
+		wantStatus int
+		wantType   string
+	}{
+		// Readiness is 503 until the warmup ran, which Run does
+		{"503 browser", "/healthz", _browserAccept, http.StatusServiceUnavailable, "text/html"},
+		{"503 api", "/healthz", _apiAccept, http.StatusServiceUnavailable, "application/json"},
+		{"404 browser", "/missing", _browserAccept, http.StatusNotFound, "text/html"},
+		{"404 api", "/missing", _apiAccept, http.StatusNotFound, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := a.serve(req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Fatalf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestStatusPageTemplateErrorFallsBackToJSON(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	a.statusPage = template.Must(template.New("status").Parse("{{.Unknown}}"))
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set("Accept", _browserAccept)
+	rec := a.serve(req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Fatalf("Content-Type = %q, want the JSON fallback", got)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	_tenantBaggageKey = "tenant.id"
+	// _tenantBuckets bounds the metric label cardinality when no allowlist is configured.
+	_tenantBuckets = 32
+	// _tenantOther labels tenants that are not in the allowlist.
+	_tenantOther = "other"
+)
+
+type tenantKey struct{}
+
+// Tenant identifies the tenant a request is served for. Label is the bounded value used
+// on metrics: the ID itself when allowlisted, "other" or a hash bucket otherwise.
+type Tenant struct {
+	ID    string
+	Label string
+}
+
+// TenantFromContext returns the tenant of the request, if the tenant middleware found one.
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(Tenant)
+	return t, ok
+}
+
+// TenantMiddleware extracts the tenant from header, or from the first label of the host
+// when fromSubdomain is set, and stores it in the request context and baggage. The span
+// and the otelhttp metrics are labeled with it. It has to come before the logging
+// middleware in Config.Middleware for the access log to see the tenant.
+func TenantMiddleware(header string, fromSubdomain bool, allowlist []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := tenantOf(r, header, fromSubdomain)
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tenant := Tenant{ID: id, Label: tenantLabel(id, allowlist)}
+			ctx := context.WithValue(r.Context(), tenantKey{}, tenant)
+			if member, err := baggage.NewMember(_tenantBaggageKey, id); err == nil {
+				if bag, err := baggage.FromContext(ctx).SetMember(member); err == nil {
+					ctx = baggage.ContextWithBaggage(ctx, bag)
+				}
+			}
+
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", id))
+			if labeler, ok := otelhttp.LabelerFromContext(ctx); ok {
+				labeler.Add(attribute.String("tenant", tenant.Label))
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func tenantOf(r *http.Request, header string, fromSubdomain bool) string {
+	if id := r.Header.Get(header); id != "" {
+		return id
+	}
+	if !fromSubdomain {
+		return ""
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	label, rest, ok := strings.Cut(host, ".")
+	if !ok || !strings.Contains(rest, ".") {
+		return "" // no subdomain
+	}
+	return label
+}
+
+func tenantLabel(id string, allowlist []string) string {
+	if len(allowlist) > 0 {
+		if slices.Contains(allowlist, id) {
+			return id
+		}
+		return _tenantOther
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("bucket-%02d", h.Sum32()%_tenantBuckets)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestTenantMiddlewareFromHeader(t *testing.T) {
+	var got Tenant
+	var member string
+	h := TenantMiddleware("X-Tenant-ID", false, []string{"acme"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		if got, ok = TenantFromContext(r.Context()); !ok {
+			t.Error("no tenant in the request context")
+		}
+		member = baggage.FromContext(r.Context()).Member(_tenantBaggageKey).Value()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != (Tenant{ID: "acme", Label: "acme"}) {
+		t.Fatalf("tenant = %+v, want acme labeled by name", got)
+	}
+	if member != "acme" {
+		t.Fatalf("baggage %s = %q, want acme", _tenantBaggageKey, member)
+	}
+}
+
+func TestTenantMiddlewareFromSubdomain(t *testing.T) {
+	var got Tenant
+	h := TenantMiddleware("X-Tenant-ID", true, []string{"acme"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = TenantFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://globex.api.example.com/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Tenants outside the allowlist share one label to bound the metric cardinality
+	if got != (Tenant{ID: "globex", Label: _tenantOther}) {
+		t.Fatalf("tenant = %+v, want globex labeled %q", got, _tenantOther)
+	}
+}
+
+func TestTenantLabelHashesWithoutAllowlist(t *testing.T) {
+	label := tenantLabel("globex", nil)
+	if label != tenantLabel("globex", nil) || label == "globex" {
+		t.Fatalf("tenantLabel = %q, want a stable hash bucket", label)
+	}
+}
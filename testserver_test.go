@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const _testServerStartTimeout = 5 * time.Second
+
+// testAPIServer is an APIServer built by the tests, with its telemetry kept in memory.
+type testAPIServer struct {
+	*APIServer
+
+	// URL is the base URL of the public listener, empty until the server is started.
+	URL     string
+	Logs    *observer.ObservedLogs
+	Metrics *sdkmetric.ManualReader
+	Traces  *tracetest.SpanRecorder
+}
+
+// newTestOTelProvider keeps the spans and the metrics in memory and never touches the globals.
+func newTestOTelProvider() (*OTelProvider, *sdkmetric.ManualReader, *tracetest.SpanRecorder) {
+	reader := sdkmetric.NewManualReader()
+	spans := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	return &OTelProvider{
+		propagator:     newPropagator(),
+		tracerProvider: tracerProvider,
+		meterProvider:  meterProvider,
+		Stats:          &OTelStats{},
+		shutdownFuncs:  []func(context.Context) error{tracerProvider.Shutdown, meterProvider.Shutdown},
+	}, reader, spans
+}
+
+// newUnstartedTestServer builds an APIServer from the GSD_* variables set by the test, with
+// an observed logger and in-memory telemetry. opts run after the test backends are injected,
+// so they can replace them.
+func newUnstartedTestServer(t testing.TB, opts ...Option) *testAPIServer {
+	t.Helper()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	provider, metrics, spans := newTestOTelProvider()
+	opts = append([]Option{InjectBackends(Backends{Logger: zap.New(core), OTel: provider})}, opts...)
+
+	a, err := NewAPIServer(opts...)
+	if err != nil {
+		t.Fatalf("NewAPIServer: %v", err)
+	}
+	return &testAPIServer{APIServer: a, Logs: logs, Metrics: metrics, Traces: spans}
+}
+
+// NewTestAPIServer starts an APIServer on a free port and returns once the port accepts
+// connections. The server is shut down, as the Runner would, when the test ends.
+func NewTestAPIServer(t testing.TB, opts ...Option) *testAPIServer {
+	t.Helper()
+	return startTestAPIServer(t, context.Background(), opts...)
+}
+
+// startTestAPIServer is NewTestAPIServer running the server with ongoingCtx, the context
+// the Runner cancels once the shutdown period is over.
+func startTestAPIServer(t testing.TB, ongoingCtx context.Context, opts ...Option) *testAPIServer {
+	t.Helper()
+
+	port := freePort(t)
+	t.Setenv("GSD_PORT", strconv.Itoa(port))
+	a := newUnstartedTestServer(t, opts...)
+	a.URL = fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	ctx, cancel := context.WithCancel(ongoingCtx)
+	served := make(chan error, 1)
+	go func() { served <- a.Run(ctx) }()
+
+	if err := WaitForPort("127.0.0.1", port, _testServerStartTimeout); err != nil {
+		cancel()
+		t.Fatalf("server did not start: %v", err)
+	}
+
+	t.Cleanup(func() {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), _testServerStartTimeout)
+		defer cancelShutdown()
+
+		// The default transport may hold a connection it dialed but never used: the server
+		// sees it as new, and Shutdown would wait 5s before treating it as idle
+		http.DefaultClient.CloseIdleConnections()
+
+		a.InitiateShutdown()
+		if err := a.Shutdown(shutdownCtx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		cancel()
+		if err := <-served; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Run: %v", err)
+		}
+		if err := a.ShutdownResources(shutdownCtx); err != nil {
+			t.Errorf("ShutdownResources: %v", err)
+		}
+		if err := a.ShutdownTelemetry(shutdownCtx); err != nil {
+			t.Errorf("ShutdownTelemetry: %v", err)
+		}
+	})
+	return a
+}
+
+// freePort returns a port nothing listens on, as chosen by the kernel.
+func freePort(t testing.TB) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// withBackends edits the backends injected by the test server, e.g. to add a Cache.
+func withBackends(fn func(*Backends)) Option {
+	return func(a *APIServer) {
+		fn(&a.backends)
+	}
+}
+
+// ownOTel makes the server shut down the injected OTel provider, as one it created.
+func ownOTel() Option {
+	return func(a *APIServer) {
+		a.ownsOTel = true
+	}
+}
+
+// serve sends req through the public handler chain of an unstarted server.
+func (a *testAPIServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	a.buildHandler(a.newMux()).ServeHTTP(rec, req.WithContext(contextWithServer(req.Context(), a.APIServer)))
+	return rec
+}
+
+// metric collects the metrics recorded so far and returns the data of the one called name.
+func (a *testAPIServer) metric(t testing.TB, name string) metricdata.Aggregation {
+	t.Helper()
+	return collectMetric(t, a.Metrics, name)
+}
+
+// collectMetric collects reader and returns the data of the metric called name.
+func collectMetric(t testing.TB, reader *sdkmetric.ManualReader, name string) metricdata.Aggregation {
+	t.Helper()
+
+	data, ok := findMetric(t, reader, name)
+	if !ok {
+		t.Fatalf("metric %s not recorded", name)
+	}
+	return data
+}
+
+// findMetric collects reader and returns the data of the metric called name, if recorded.
+func findMetric(t testing.TB, reader *sdkmetric.ManualReader, name string) (metricdata.Aggregation, bool) {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// withRoute registers fn on the public listener, before the built-in routes.
+func withRoute(pattern string, fn apiFunc) Option {
+	return func(a *APIServer) {
+		a.Handle(pattern, fn)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// tlsEnabled reports whether the public listener serves HTTPS.
+func (c Config) tlsEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// newTLSConfig returns the complete config of the public listener, certificate and ALPN
+// protocols included, as http.Server.ServeTLS would build it: the handshake timer clones it
+// for every client.
+func newTLSConfig(config Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("server certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if config.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(config.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA bundle %s: no PEM certificate", config.TLSClientCAFile)
+		}
+		// An untrusted certificate fails the handshake; a missing one is rejected by
+		// clientIdentityHandler, except on the probe paths the kubelet calls without one
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// ClientIdentity is the client of an mTLS connection, as stated by its verified certificate.
+type ClientIdentity struct {
+	Subject  string
+	DNSNames []string
+	URIs     []string
+}
+
+type clientIdentityKey struct{}
+
+// ClientIdentityFromContext returns the identity of the client certificate of the request,
+// set when Config.TLSClientCAFile requires one.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	id, ok := ctx.Value(clientIdentityKey{}).(ClientIdentity)
+	return id, ok
+}
+
+// clientIdentityHandler stores the identity of the verified client certificate in the
+// request context. The handshake already rejected the untrusted certificates; requests
+// without one are rejected here, unless their path is exempt.
+func clientIdentityHandler(next http.Handler, exempt ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			if slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			WriteJSON(w, http.StatusUnauthorized, APIError{
+				Code:    http.StatusUnauthorized,
+				Message: "client certificate required",
+			})
+			return
+		}
+		cert := r.TLS.VerifiedChains[0][0]
+		id := ClientIdentity{Subject: cert.Subject.CommonName, DNSNames: cert.DNSNames}
+		for _, uri := range cert.URIs {
+			id.URIs = append(id.URIs, uri.String())
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, id)))
+	})
+}
+
+// handshakeTracker remembers the connections that haven't sent a request yet, TLS handshake
+// included. http.Server.Shutdown only closes them once they're 5 seconds old, so a client
+// stalling its handshake could hold the shutdown until the deadline: closeAll cuts them then.
+type handshakeTracker struct {
+	conns sync.Map // net.Conn -> struct{}
+}
+
+// track is an http.Server.ConnState callback, chained before next.
+func (t *handshakeTracker) track(next func(net.Conn, http.ConnState)) func(net.Conn, http.ConnState) {
+	return func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			t.conns.Store(conn, struct{}{})
+		} else {
+			t.conns.Delete(conn)
+		}
+		next(conn, state)
+	}
+}
+
+func (t *handshakeTracker) closeAll() {
+	t.conns.Range(func(conn, _ any) bool {
+		_ = conn.(net.Conn).Close()
+		return true
+	})
+}
+
+// closeNewConnsAtDeadline force-closes the connections still in the new state once ctx, the
+// shutdown context, is done. It returns a func to call when the shutdown finished in time.
+func (a *APIServer) closeNewConnsAtDeadline(ctx context.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, a.handshakes.closeAll)
+}
+
+// _slowTLSHandshake is the handshake duration past which the connection is logged.
+const _slowTLSHandshake = 100 * time.Millisecond
+
+// tlsHandshakeTimer measures the TLS handshakes into the tls.handshake.duration histogram:
+// from the ClientHello, seen by GetConfigForClient, to VerifyConnection, the last step of a
+// full or resumed handshake. The first request is still to be read then, so a slow client
+// doesn't count as a slow handshake.
+type tlsHandshakeTimer struct {
+	histogram metric.Float64Histogram
+	logger    *zap.Logger
+}
+
+func newTLSHandshakeTimer(meter metric.Meter, logger *zap.Logger) *tlsHandshakeTimer {
+	hist, err := meter.Float64Histogram(
+		"tls.handshake.duration",
+		metric.WithDescription("Duration of the TLS handshakes of the public listener."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &tlsHandshakeTimer{histogram: hist, logger: logger}
+}
+
+// instrument makes every handshake of cfg use a clone of it, whose VerifyConnection records
+// the time elapsed since the ClientHello.
+func (t *tlsHandshakeTimer) instrument(cfg *tls.Config) {
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		start := time.Now()
+		clone := cfg.Clone()
+		clone.GetConfigForClient = nil
+		verify := cfg.VerifyConnection
+		clone.VerifyConnection = func(state tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(state); err != nil {
+					return err
+				}
+			}
+			t.record(hello.Conn, time.Since(start))
+			return nil
+		}
+		return clone, nil
+	}
+}
+
+func (t *tlsHandshakeTimer) record(conn net.Conn, d time.Duration) {
+	t.histogram.Record(context.Background(), d.Seconds())
+	if d > _slowTLSHandshake {
+		t.logger.Warn("Slow TLS handshake",
+			zap.String("remote_addr", conn.RemoteAddr().String()),
+			zap.Duration("duration", d),
+		)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// testCA issues the certificates of the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	// File is the PEM file of the CA certificate.
+	File string
+	Pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &testCA{cert: cert, key: key, Pool: x509.NewCertPool()}
+	ca.Pool.AddCert(cert)
+	ca.File = writePEM(t, "ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+// issue returns a certificate for cn signed by the CA, valid for 127.0.0.1, along with its
+// PEM certificate and key files.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) (cert tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{cn},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = writePEM(t, cn+".pem", "CERTIFICATE", der)
+	keyFile = writePEM(t, cn+"-key.pem", "EC PRIVATE KEY", keyDER)
+	cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serveTLS makes the test server serve HTTPS with a certificate issued by a new CA,
+// which is returned.
+func serveTLS(t *testing.T) *testCA {
+	t.Helper()
+
+	ca := newTestCA(t)
+	_, certFile, keyFile := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	t.Setenv("GSD_TLS_CERT_FILE", certFile)
+	t.Setenv("GSD_TLS_KEY_FILE", keyFile)
+	return ca
+}
+
+func TestStalledTLSHandshakeClosedAtShutdownDeadline(t *testing.T) {
+	serveTLS(t)
+	t.Setenv("GSD_TLS_HANDSHAKE_TIMEOUT", "1m") // only the shutdown deadline may cut it
+	a := NewTestAPIServer(t)
+
+	// A client that connects and never sends its ClientHello
+	conn, err := net.Dial("tcp", strings.TrimPrefix(a.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	time.Sleep(50 * time.Millisecond) // let the server accept it
+
+	const deadline = 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	a.InitiateShutdown()
+	start := time.Now()
+	if err := a.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > deadline+time.Second {
+		t.Fatalf("Shutdown took %v, want it bounded by the %v deadline", elapsed, deadline)
+	}
+
+	// The stalled connection was cut at the deadline, not left to the handshake timeout
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatalf("read on the stalled connection = %v, want it closed by the server", err)
+	}
+}
+
+func TestChaosRequestsOverTLS(t *testing.T) {
+	serveTLS(t)
+	t.Setenv("GSD_CHAOS_ENABLED", "true")
+	t.Setenv("GSD_CHAOS_FAULTS", "requests:2:hang:300ms")
+	a := NewTestAPIServer(t)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for a.InFlightRequests() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("in-flight requests = %d, want the 2 chaos requests to reach the TLS listener", a.InFlightRequests())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTLSHandshakeTimedUntilVerifyConnection(t *testing.T) {
+	ca := newTestCA(t)
+	cert, _, _ := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	reader := sdkmetric.NewManualReader()
+	core, logs := observer.New(zapcore.WarnLevel)
+	timer := newTLSHandshakeTimer(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), zap.New(core))
+
+	ts := httptest.NewUnstartedServer(_okHandler)
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: []string{"http/1.1"}}
+	timer.instrument(ts.TLS)
+	ts.StartTLS()
+	defer ts.Close()
+
+	conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), &tls.Config{RootCAs: ca.Pool, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// A client slow to send its first request doesn't make the handshake slow
+	time.Sleep(2 * _slowTLSHandshake)
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	hist := collectMetric(t, reader, "tls.handshake.duration").(metricdata.Histogram[float64])
+	if len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+		t.Fatalf("handshake data points = %+v, want one handshake", hist.DataPoints)
+	}
+	if got := hist.DataPoints[0].Sum; got >= _slowTLSHandshake.Seconds() {
+		t.Fatalf("handshake duration = %vs, want the request wait left out", got)
+	}
+	if n := logs.FilterMessage("Slow TLS handshake").Len(); n != 0 {
+		t.Fatalf("%d slow handshake warnings, want none", n)
+	}
+}
+
+func TestTLSListenerNegotiatesHTTP2(t *testing.T) {
+	ca := serveTLS(t)
+	a := NewTestAPIServer(t)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: ca.Pool, ServerName: "localhost"},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get(strings.Replace(a.URL, "http://", "https://", 1) + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Proto != "HTTP/2.0" {
+		t.Fatalf("protocol = %s, want HTTP/2.0", resp.Proto)
+	}
+	hist := a.metric(t, "tls.handshake.duration").(metricdata.Histogram[float64])
+	if len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+		t.Fatalf("handshake data points = %+v, want one handshake", hist.DataPoints)
+	}
+}
+
+func TestMTLSRequiresAClientCertificate(t *testing.T) {
+	ca := serveTLS(t)
+	t.Setenv("GSD_TLS_CLIENT_CA_FILE", ca.File)
+	a := NewTestAPIServer(t, withRoute("GET /whoami", func(w http.ResponseWriter, r *http.Request) error {
+		id, ok := ClientIdentityFromContext(r.Context())
+		if !ok {
+			return WriteJSON(w, http.StatusInternalServerError, "no client identity")
+		}
+		return WriteJSON(w, http.StatusOK, id)
+	}))
+	base := strings.Replace(a.URL, "http://", "https://", 1)
+	url := base + "/whoami"
+
+	clientFor := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      ca.Pool,
+			ServerName:   "localhost",
+			Certificates: certs,
+		}}}
+	}
+
+	t.Run("without certificate", func(t *testing.T) {
+		resp, err := clientFor().Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("request without a client certificate got %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("probes without certificate", func(t *testing.T) {
+		for _, path := range []string{"/livez", "/healthz"} {
+			resp, err := clientFor().Get(base + path)
+			if err != nil {
+				t.Fatalf("probe of %s without a client certificate: %v", path, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("probe of %s without a client certificate got %d, want %d", path, resp.StatusCode, http.StatusOK)
+			}
+		}
+	})
+
+	t.Run("with a certificate of another CA", func(t *testing.T) {
+		other, _, _ := newTestCA(t).issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+		if resp, err := clientFor(other).Get(url); err == nil {
+			resp.Body.Close()
+			t.Fatalf("request with an untrusted client certificate got %d, want the handshake rejected", resp.StatusCode)
+		}
+	})
+
+	t.Run("with a valid certificate", func(t *testing.T) {
+		cert, _, _ := ca.issue(t, "billing", x509.ExtKeyUsageClientAuth)
+		resp, err := clientFor(cert).Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		var id ClientIdentity
+		if err := json.NewDecoder(resp.Body).Decode(&id); err != nil {
+			t.Fatal(err)
+		}
+		if id.Subject != "billing" || !slices.Equal(id.DNSNames, []string{"billing"}) {
+			t.Fatalf("identity = %+v, want the billing certificate", id)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Trailing slash policies of Config.TrailingSlash.
+const (
+	TrailingSlashStrip  = "strip"
+	TrailingSlashAppend = "append"
+)
+
+// TrailingSlashMiddleware makes /path/ and /path the same route by stripping the trailing
+// slash. With redirect the client is sent to the canonical path, otherwise the path is
+// rewritten before routing.
+func TrailingSlashMiddleware(redirect bool) func(http.Handler) http.Handler {
+	return trailingSlashMiddleware(TrailingSlashStrip, redirect)
+}
+
+func trailingSlashMiddleware(policy string, redirect bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := canonicalPath(r.URL.Path, policy)
+			if path == r.URL.Path {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if redirect {
+				target := *r.URL
+				target.Path, target.RawPath = path, ""
+				// 301 may turn the method into GET, 308 keeps it and the body
+				status := http.StatusMovedPermanently
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					status = http.StatusPermanentRedirect
+				}
+				http.Redirect(w, r, target.RequestURI(), status)
+				return
+			}
+
+			r2 := r.Clone(r.Context())
+			r2.URL.Path, r2.URL.RawPath = path, ""
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// canonicalPath applies policy to path. The root path is always left as is.
+func canonicalPath(path, policy string) string {
+	if path == "/" {
+		return path
+	}
+	if policy == TrailingSlashAppend {
+		if !strings.HasSuffix(path, "/") {
+			return path + "/"
+		}
+		return path
+	}
+	return strings.TrimRight(path, "/")
+}
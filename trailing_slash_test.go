@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func pathEcho() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+}
+
+func TestTrailingSlashRedirectsToCanonicalPath(t *testing.T) {
+	h := TrailingSlashMiddleware(true)(pathEcho())
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz/?verbose=1", nil))
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("status = %d, want 301", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/healthz?verbose=1" {
+		t.Fatalf("Location = %q, want /healthz?verbose=1", loc)
+	}
+
+	// Redirecting a POST keeps its method and body
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items/", nil))
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Fatalf("POST status = %d, want 308", rec.Code)
+	}
+}
+
+func TestTrailingSlashRewritesWithoutRedirect(t *testing.T) {
+	rec := httptest.NewRecorder()
+	TrailingSlashMiddleware(false)(pathEcho()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz/", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "/healthz" {
+		t.Fatalf("response = %d %q, want the handler to see /healthz", rec.Code, rec.Body)
+	}
+}
+
+func TestTrailingSlashAppendPolicy(t *testing.T) {
+	rec := httptest.NewRecorder()
+	trailingSlashMiddleware(TrailingSlashAppend, true)(pathEcho()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/docs/" {
+		t.Fatalf("response = %d to %q, want a 301 to /docs/", rec.Code, rec.Header().Get("Location"))
+	}
+}
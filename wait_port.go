@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const _waitForPortInterval = 10 * time.Millisecond
+
+// WaitForPort blocks until host:port accepts TCP connections, dialing every 10ms, or fails
+// once timeout has elapsed. Callers starting a server in a goroutine use it to avoid racing
+// the listener; in-process, Ready tells the same without dialing.
+func WaitForPort(host string, port int, timeout time.Duration) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	deadline := time.Now().Add(timeout)
+
+	for {
+		conn, err := net.DialTimeout("tcp", addr, _waitForPortInterval)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s not accepting connections after %s: %w", addr, timeout, err)
+		}
+		time.Sleep(_waitForPortInterval)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWaitForPortReturnsImmediatelyWhenOpen(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	start := time.Now()
+	if err := WaitForPort("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, time.Second); err != nil {
+		t.Fatalf("WaitForPort: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= _waitForPortInterval {
+		t.Fatalf("WaitForPort took %s on an open port, want a single dial", elapsed)
+	}
+}
+
+func TestWaitForPortTimesOut(t *testing.T) {
+	port := freePort(t)
+
+	timeout := 5 * _waitForPortInterval
+	start := time.Now()
+	if err := WaitForPort("127.0.0.1", port, timeout); err == nil {
+		t.Fatal("WaitForPort succeeded on a closed port")
+	}
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Fatalf("WaitForPort gave up after %s, before the %s timeout", elapsed, timeout)
+	}
+}
+
+func TestNewTestAPIServerServesRequests(t *testing.T) {
+	a := NewTestAPIServer(t)
+
+	resp, err := http.Get(a.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /healthz = %d, want 200", resp.StatusCode)
+	}
+}
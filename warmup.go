@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	_warmupHeader  = "X-GSD-Warmup"
+	_warmupTimeout = 10 * time.Second
+)
+
+// WarmupRequest is a request sent through the public handler chain before the server reports ready.
+type WarmupRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body"`
+}
+
+// WarmupRequests is decoded by envconfig from a JSON array, e.g.
+// GSD_WARMUP_REQUESTS='[{"method":"GET","path":"/"}]'.
+type WarmupRequests []WarmupRequest
+
+func (w *WarmupRequests) Decode(value string) error {
+	return json.Unmarshal([]byte(value), w)
+}
+
+// Ready is closed once the public listener accepts connections.
+func (a *APIServer) Ready() <-chan struct{} {
+	return a.ready
+}
+
+// runWarmup sends the warmup requests once the listener is ready, then lets the readiness
+// probe succeed. A failing warmup request is logged, it doesn't keep the server unready.
+func (a *APIServer) runWarmup(ctx context.Context) {
+	select {
+	case <-a.ready:
+	case <-ctx.Done():
+		return
+	}
+
+	for _, wr := range a.Config.WarmupRequests {
+		if ctx.Err() != nil {
+			return
+		}
+
+		start := time.Now()
+		status, err := a.sendWarmupRequest(ctx, wr)
+		logger := a.Logger.With(
+			zap.String("method", wr.Method),
+			zap.String("path", wr.Path),
+			zap.Duration("duration", time.Since(start)),
+		)
+		if err != nil {
+			logger.Warn("Warmup request failed", zap.Error(err))
+			continue
+		}
+		logger.Info("Warmup request sent", zap.Int("status", status))
+	}
+
+	a.warmedUp.Store(true)
+	a.Events.Record(EventState, "warmup completed", "requests", fmt.Sprint(len(a.Config.WarmupRequests)))
+}
+
+// sendWarmupRequest sends wr through the public handler chain in process. It's marked as
+// internal, so it isn't asked for credentials it doesn't have, whatever the listener (TLS,
+// client certificates) would require from a client.
+func (a *APIServer) sendWarmupRequest(ctx context.Context, wr WarmupRequest) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, _warmupTimeout)
+	defer cancel()
+
+	method := wr.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, wr.Path, strings.NewReader(wr.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Host = fmt.Sprintf("127.0.0.1:%d", a.Config.Port)
+	req.Header.Set(_warmupHeader, "true")
+
+	resp := a.serveInternal(req)
+	return resp.status, ctx.Err()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReadinessUnavailableUntilWarmupCompletes(t *testing.T) {
+	t.Setenv("GSD_WARMUP_REQUESTS", `[{"method":"GET","path":"/warm"}]`)
+	warming := make(chan struct{})
+	release := make(chan struct{})
+	a := NewTestAPIServer(t, withRoute("GET /warm", func(w http.ResponseWriter, r *http.Request) error {
+		if r.Header.Get(_warmupHeader) == "" {
+			t.Error("warmup request without the warmup header")
+		}
+		close(warming)
+		<-release
+		return nil
+	}))
+
+	<-warming
+	if code := getStatus(t, a.URL+"/healthz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness during warmup = %d, want 503", code)
+	}
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for getStatus(t, a.URL+"/healthz") != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("readiness never succeeded after the warmup completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func getStatus(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
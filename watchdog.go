@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Watchdog calls action when Beat hasn't been called for maxMissed heartbeat intervals while
+// requests are in flight: served requests stopped completing, e.g. because every handler is
+// stuck on the same lock. An idle server isn't stuck, it misses no heartbeat.
+// Unlike the liveness probe, it doesn't rely on the orchestrator to restart the process.
+type Watchdog struct {
+	heartbeat time.Duration
+	maxMissed int
+	action    func()
+
+	last     atomic.Int64 // unix nanoseconds
+	inFlight atomic.Int64 // requests seen by WatchdogMiddleware that haven't completed
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewWatchdog(heartbeat time.Duration, maxMissed int, action func()) *Watchdog {
+	return &Watchdog{
+		heartbeat: heartbeat,
+		maxMissed: maxMissed,
+		action:    action,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Beat tells the watchdog the process is making progress.
+func (w *Watchdog) Beat() {
+	w.last.Store(time.Now().UnixNano())
+}
+
+// Start watches the beats from its own goroutine until Stop. The action is called at most once.
+func (w *Watchdog) Start() {
+	w.Beat()
+	go func() {
+		ticker := time.NewTicker(w.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if w.inFlight.Load() == 0 {
+					w.Beat()
+					continue
+				}
+				missed := time.Since(time.Unix(0, w.last.Load())) / w.heartbeat
+				if int(missed) >= w.maxMissed {
+					w.action()
+					return
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops watching, e.g. once the drain starts and requests legitimately dry up.
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// WatchdogMiddleware beats w every time a request completes, and tells it which requests
+// are in flight.
+func WatchdogMiddleware(w *Watchdog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			w.inFlight.Add(1)
+			defer func() {
+				w.inFlight.Add(-1)
+				w.Beat()
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// watchdogExit dumps the stacks of every goroutine, to find out what they're blocked on,
+// then exits so the process gets restarted.
+func watchdogExit(logger *zap.Logger) func() {
+	logger = logger.Named("watchdog")
+	return func() {
+		buf := make([]byte, 1<<20)
+		n := runtime.Stack(buf, true)
+		logger.Error("Watchdog missed too many heartbeats, exiting",
+			zap.ByteString("goroutines", buf[:n]),
+		)
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const _testHeartbeat = 10 * time.Millisecond
+
+func startTestWatchdog(t *testing.T) (*Watchdog, *atomic.Bool) {
+	t.Helper()
+
+	var fired atomic.Bool
+	w := NewWatchdog(_testHeartbeat, 3, func() { fired.Store(true) })
+	w.Start()
+	t.Cleanup(w.Stop)
+	return w, &fired
+}
+
+func TestWatchdogLeavesAnIdleServerAlone(t *testing.T) {
+	_, fired := startTestWatchdog(t)
+
+	time.Sleep(20 * _testHeartbeat)
+	if fired.Load() {
+		t.Fatal("the watchdog fired on a server without requests")
+	}
+}
+
+func TestWatchdogFiresOnStuckRequests(t *testing.T) {
+	w, fired := startTestWatchdog(t)
+	release := make(chan struct{})
+	handler := WatchdogMiddleware(w)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+
+	time.Sleep(10 * _testHeartbeat) // idle first: the stale beat must not count
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	defer func() {
+		close(release)
+		<-done
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !fired.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("the watchdog never fired while the request was stuck")
+		}
+		time.Sleep(_testHeartbeat)
+	}
+}
+
+func TestWatchdogSatisfiedByCompletingRequests(t *testing.T) {
+	w, fired := startTestWatchdog(t)
+	handler := WatchdogMiddleware(w)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		time.Sleep(_testHeartbeat / 2)
+	}))
+
+	for range 40 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if fired.Load() {
+		t.Fatal("the watchdog fired while requests kept completing")
+	}
+}
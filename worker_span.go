@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const _workerSpanGracePeriod = 2 * time.Second
+
+// SpanTracker keeps count of the spans started by background workers, so the telemetry
+// phase can wait for them to end (and be queued for export) before the tracer provider shuts down.
+// Once Wait was called, the spans started are no longer recorded: they would end after the
+// provider shut down.
+type SpanTracker struct {
+	tracer trace.Tracer
+
+	mu     sync.Mutex
+	active int
+	idle   chan struct{} // closed when active drops to zero, created by Wait
+	closed bool          // set by Wait
+}
+
+func NewSpanTracker(tracer trace.Tracer) *SpanTracker {
+	return &SpanTracker{tracer: tracer}
+}
+
+// WorkerSpan is a span tracked by a SpanTracker. End is safe to call more than once.
+type WorkerSpan struct {
+	trace.Span
+
+	once sync.Once
+	done func()
+}
+
+// StartSpan starts a span that is tracked until End is called on it, or a non-recording
+// one once Wait was called. Workers should defer End right away so the span is released on
+// every return path.
+func (t *SpanTracker) StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, *WorkerSpan) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		ctx, span := noop.NewTracerProvider().Tracer("").Start(ctx, name, opts...)
+		return ctx, &WorkerSpan{Span: span, done: func() {}}
+	}
+	t.active++
+	t.mu.Unlock()
+
+	ctx, span := t.tracer.Start(ctx, name, opts...)
+	return ctx, &WorkerSpan{Span: span, done: t.release}
+}
+
+func (t *SpanTracker) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	if t.active == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+func (s *WorkerSpan) End(opts ...trace.SpanEndOption) {
+	s.once.Do(func() {
+		s.Span.End(opts...)
+		s.done()
+	})
+}
+
+// Active returns the number of tracked spans not ended yet.
+func (t *SpanTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+// Wait stops tracking new spans, then blocks until every tracked span has ended or ctx is
+// done.
+func (t *SpanTracker) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	if t.active == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
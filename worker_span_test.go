@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerSpanStraddlingShutdownIsExported(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	_, span := a.Spans.StartSpan(context.Background(), "worker")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		span.End()
+	}()
+
+	if err := a.ShutdownTelemetry(context.Background()); err != nil {
+		t.Fatalf("ShutdownTelemetry: %v", err)
+	}
+
+	var found bool
+	for _, s := range a.Traces.Ended() {
+		found = found || s.Name() == "worker"
+	}
+	if !found {
+		t.Fatal("worker span not exported by the telemetry shutdown")
+	}
+}
+
+func TestSpanTrackerWaitIsBounded(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	_, span := a.Spans.StartSpan(context.Background(), "stuck")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.Spans.Wait(ctx); err == nil {
+		t.Fatal("Wait returned nil with a span still running")
+	}
+
+	span.End()
+	span.End() // ending twice must not release the tracker twice
+	if err := a.Spans.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait after End: %v", err)
+	}
+}
+
+func TestSpanTrackerRefusesSpansOnceWaiting(t *testing.T) {
+	a := newUnstartedTestServer(t)
+	if err := a.Spans.Wait(t.Context()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	_, span := a.Spans.StartSpan(t.Context(), "late")
+	if span.IsRecording() {
+		t.Fatal("span started after Wait is recording")
+	}
+	span.End()
+	for _, s := range a.Traces.Ended() {
+		if s.Name() == "late" {
+			t.Fatal("span started after Wait was exported")
+		}
+	}
+}
+
+func TestSpanTrackerConcurrentStartAndWait(t *testing.T) {
+	a := newUnstartedTestServer(t)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			_, span := a.Spans.StartSpan(t.Context(), "worker")
+			span.End()
+		})
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	if err := a.Spans.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	wg.Wait()
+}